@@ -0,0 +1,567 @@
+// Package admin serves an HTTP API for managing campaigns, their budgets and their pacing.
+//
+//	GET    /campaigns                  lists campaigns
+//	POST   /campaigns                  creates a campaign from an rtb.CampaignSpec
+//	GET    /campaigns/{id}             reads a campaign
+//	PUT    /campaigns/{id}             replaces a campaign's settings with an rtb.CampaignSpec
+//	DELETE /campaigns/{id}             archives a campaign
+//	POST   /campaigns/{id}/pause       pauses a campaign
+//	POST   /campaigns/{id}/resume      resumes a paused campaign
+//	GET    /campaigns/{id}/creatives   reads a campaign's creatives
+//	PUT    /campaigns/{id}/creatives   replaces a campaign's creatives
+//	GET    /campaigns/{id}/budget      reads a campaign's remaining daily budget
+//	PUT    /campaigns/{id}/budget      sets the remaining daily budget, {"remainingDailyBudgetInMicroCents": n}
+//	POST   /campaigns/{id}/budget      tops up the remaining daily budget, {"amountInMicroCents": n}
+//	GET    /campaigns/{id}/pacing      reads what the pacer knows about a campaign
+//	PUT    /campaigns/{id}/pacing      sets a campaign's pacing mode, {"mode": "even"}
+//
+// Every change is recorded in the audit log with the user that made it.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/evandigby/rtb"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Largest request body accepted
+const maxBodyBytes = 1 << 20
+
+// Authenticator returns the user making a request, or false if the request isn't allowed
+type Authenticator func(r *http.Request) (user string, ok bool)
+
+// BasicAuthenticator allows requests with HTTP basic auth credentials in users, a map of user name to password
+func BasicAuthenticator(users map[string]string) Authenticator {
+	return func(r *http.Request) (string, bool) {
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return "", false
+		}
+
+		expected, ok := users[user]
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 {
+			return "", false
+		}
+
+		return user, true
+	}
+}
+
+// CampaignView is how the API returns a campaign
+type CampaignView struct {
+	ID                      int64            `json:"id"`
+	Status                  string           `json:"status"`
+	BidCpmInMicroCents      int64            `json:"bidCpmInMicroCents"`
+	DailyBudgetInMicroCents int64            `json:"dailyBudgetInMicroCents"`
+	Goal                    string           `json:"goal"`
+	GoalInMicroCents        int64            `json:"goalInMicroCents,omitempty"`
+	Priority                string           `json:"priority"`
+	Targets                 []rtb.TargetSpec `json:"targets"`
+	Creatives               []rtb.Creative   `json:"creatives"`
+}
+
+// BudgetView is how the API returns a campaign's budget
+type BudgetView struct {
+	DailyBudgetInMicroCents          int64 `json:"dailyBudgetInMicroCents"`
+	RemainingDailyBudgetInMicroCents int64 `json:"remainingDailyBudgetInMicroCents"`
+}
+
+// PacingView is how the API returns what the pacer knows about a campaign. Fields the pacer doesn't track are left out.
+type PacingView struct {
+	Segment                 string   `json:"segment,omitempty"`
+	TargetSpendInMicroCents *int64   `json:"targetSpendInMicroCents,omitempty"`
+	SpendErrorInMicroCents  *int64   `json:"spendErrorInMicroCents,omitempty"`
+	Mode                    string   `json:"mode,omitempty"`
+	Probability             *float64 `json:"probability,omitempty"`
+}
+
+type budgetSet struct {
+	RemainingDailyBudgetInMicroCents int64 `json:"remainingDailyBudgetInMicroCents"`
+}
+
+type budgetTopUp struct {
+	AmountInMicroCents int64 `json:"amountInMicroCents"`
+}
+
+type pacingSet struct {
+	Mode string `json:"mode"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// AdminServer serves the admin API. Pacer and AuditLogger are optional.
+type AdminServer struct {
+	CampaignProvider rtb.CampaignProvider
+	Banker           rtb.Banker
+	Pacer            rtb.Pacer
+	AuditLogger      rtb.AuditLogger
+	Authenticate     Authenticator
+
+	now func() time.Time
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if value != nil {
+		json.NewEncoder(w).Encode(value)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// decode reads a JSON body, rejecting unknown fields
+func decode(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return false
+	}
+
+	return true
+}
+
+func (s *AdminServer) audit(user string, action string, campaignId int64, before interface{}, after interface{}) {
+	if s.AuditLogger == nil {
+		return
+	}
+
+	s.AuditLogger.LogChange(&rtb.AuditEntry{
+		TimestampInNanoseconds: s.now().UnixNano(),
+		User:                   user,
+		Action:                 action,
+		CampaignId:             campaignId,
+		Before:                 before,
+		After:                  after,
+	})
+}
+
+// dailyBudgetExpiration is when budgets set now expire. Budgets expire on calendar days, the same as the bidder's.
+func (s *AdminServer) dailyBudgetExpiration() time.Time {
+	now := s.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// campaign returns the campaign if it exists. Archived campaigns exist, but aren't listed.
+func (s *AdminServer) campaign(id int64) rtb.Campaign {
+	campaign := s.CampaignProvider.ReadCampaign(id)
+	if campaign == nil {
+		return nil
+	}
+
+	if campaign.Status() == rtb.CampaignArchived || s.CampaignProvider.IsCampaignListed(id) {
+		return campaign
+	}
+
+	return nil
+}
+
+func view(campaign rtb.Campaign) *CampaignView {
+	v := new(CampaignView)
+
+	v.ID = campaign.Id()
+	v.Status = campaign.Status().String()
+	v.BidCpmInMicroCents = campaign.BidCpmInMicroCents()
+	v.DailyBudgetInMicroCents = campaign.DailyBudgetInMicroCents()
+	v.Goal = campaign.Goal().Type.String()
+	v.GoalInMicroCents = campaign.Goal().AmountInMicroCents
+	v.Priority = campaign.Priority().String()
+
+	v.Targets = make([]rtb.TargetSpec, 0)
+	for _, target := range campaign.TargetList() {
+		name, ok := rtb.TargetTypeNames[target.Type]
+		if !ok {
+			name = strconv.Itoa(int(target.Type))
+		}
+
+		v.Targets = append(v.Targets, rtb.TargetSpec{Type: name, Value: target.Value})
+	}
+
+	sort.Slice(v.Targets, func(i, j int) bool {
+		if v.Targets[i].Type != v.Targets[j].Type {
+			return v.Targets[i].Type < v.Targets[j].Type
+		}
+		return v.Targets[i].Value < v.Targets[j].Value
+	})
+
+	v.Creatives = campaign.Creatives()
+	if v.Creatives == nil {
+		v.Creatives = []rtb.Creative{}
+	}
+
+	return v
+}
+
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.Authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="rtb admin"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "campaigns" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listCampaigns(w)
+		case http.MethodPost:
+			s.createCampaign(w, r, user)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid campaign id %q", parts[1])
+		return
+	}
+
+	campaign := s.campaign(id)
+	if campaign == nil {
+		writeError(w, http.StatusNotFound, "campaign %d not found", id)
+		return
+	}
+
+	if r.Method != http.MethodGet && campaign.Status() == rtb.CampaignArchived {
+		writeError(w, http.StatusConflict, "campaign %d is archived", id)
+		return
+	}
+
+	if len(parts) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, view(campaign))
+		case http.MethodPut:
+			s.updateCampaign(w, r, user, campaign)
+		case http.MethodDelete:
+			s.deleteCampaign(w, user, campaign)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+		}
+		return
+	}
+
+	switch parts[2] {
+	case "pause", "resume":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		s.setStatus(w, user, campaign, parts[2])
+	case "creatives":
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, view(campaign).Creatives)
+		case http.MethodPut:
+			s.setCreatives(w, r, user, campaign)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut)
+		}
+	case "budget":
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.budget(campaign))
+		case http.MethodPut:
+			s.setBudget(w, r, user, campaign)
+		case http.MethodPost:
+			s.topUpBudget(w, r, user, campaign)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPost)
+		}
+	case "pacing":
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.pacing(campaign))
+		case http.MethodPut:
+			s.setPacing(w, r, user, campaign)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *AdminServer) listCampaigns(w http.ResponseWriter) {
+	ids := s.CampaignProvider.ListCampaigns()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	views := make([]*CampaignView, 0, len(ids))
+	for _, id := range ids {
+		if campaign := s.CampaignProvider.ReadCampaign(id); campaign != nil {
+			views = append(views, view(campaign))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+// applySpec makes the campaign provider match the spec, and returns the campaign
+func (s *AdminServer) applySpec(w http.ResponseWriter, spec rtb.CampaignSpec) rtb.Campaign {
+	changes, err := rtb.DiffCampaigns(s.CampaignProvider, []rtb.CampaignSpec{spec}, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return nil
+	}
+
+	rtb.ApplyCampaignChanges(s.CampaignProvider, changes)
+
+	return s.CampaignProvider.ReadCampaign(spec.ID)
+}
+
+func (s *AdminServer) createCampaign(w http.ResponseWriter, r *http.Request, user string) {
+	var spec rtb.CampaignSpec
+	if !decode(w, r, &spec) {
+		return
+	}
+
+	if err := spec.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	if s.campaign(spec.ID) != nil {
+		writeError(w, http.StatusConflict, "campaign %d already exists", spec.ID)
+		return
+	}
+
+	campaign := s.applySpec(w, spec)
+	if campaign == nil {
+		return
+	}
+
+	after := view(campaign)
+	s.audit(user, "create", spec.ID, nil, after)
+
+	writeJSON(w, http.StatusCreated, after)
+}
+
+func (s *AdminServer) updateCampaign(w http.ResponseWriter, r *http.Request, user string, campaign rtb.Campaign) {
+	var spec rtb.CampaignSpec
+	if !decode(w, r, &spec) {
+		return
+	}
+
+	if spec.ID == 0 {
+		spec.ID = campaign.Id()
+	}
+
+	if spec.ID != campaign.Id() {
+		writeError(w, http.StatusBadRequest, "spec is for campaign %d, not %d", spec.ID, campaign.Id())
+		return
+	}
+
+	if err := spec.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	before := view(campaign)
+
+	updated := s.applySpec(w, spec)
+	if updated == nil {
+		return
+	}
+
+	after := view(updated)
+	s.audit(user, "update", spec.ID, before, after)
+
+	writeJSON(w, http.StatusOK, after)
+}
+
+func (s *AdminServer) deleteCampaign(w http.ResponseWriter, user string, campaign rtb.Campaign) {
+	s.CampaignProvider.DeleteCampaign(campaign.Id())
+	s.audit(user, "delete", campaign.Id(), view(campaign), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *AdminServer) setStatus(w http.ResponseWriter, user string, campaign rtb.Campaign, action string) {
+	before := campaign.Status()
+
+	if action == "pause" {
+		s.CampaignProvider.PauseCampaign(campaign.Id())
+	} else {
+		s.CampaignProvider.ResumeCampaign(campaign.Id())
+	}
+
+	updated := s.CampaignProvider.ReadCampaign(campaign.Id())
+
+	if updated.Status() != before {
+		s.audit(user, action, campaign.Id(), before.String(), updated.Status().String())
+	}
+
+	writeJSON(w, http.StatusOK, view(updated))
+}
+
+func (s *AdminServer) setCreatives(w http.ResponseWriter, r *http.Request, user string, campaign rtb.Campaign) {
+	var creatives []rtb.Creative
+	if !decode(w, r, &creatives) {
+		return
+	}
+
+	ids := make(map[string]bool, len(creatives))
+	for _, creative := range creatives {
+		if creative.ID == "" || ids[creative.ID] {
+			writeError(w, http.StatusBadRequest, "creatives need a unique id")
+			return
+		}
+
+		ids[creative.ID] = true
+	}
+
+	before := view(campaign).Creatives
+
+	s.CampaignProvider.SetCampaignCreatives(campaign.Id(), creatives)
+	s.audit(user, "setCreatives", campaign.Id(), before, creatives)
+
+	writeJSON(w, http.StatusOK, creatives)
+}
+
+func (s *AdminServer) budget(campaign rtb.Campaign) *BudgetView {
+	return &BudgetView{
+		DailyBudgetInMicroCents:          campaign.DailyBudgetInMicroCents(),
+		RemainingDailyBudgetInMicroCents: s.Banker.RemainingDailyBudgetInMicroCents(campaign.Id()),
+	}
+}
+
+func (s *AdminServer) setBudget(w http.ResponseWriter, r *http.Request, user string, campaign rtb.Campaign) {
+	var set budgetSet
+	if !decode(w, r, &set) {
+		return
+	}
+
+	if set.RemainingDailyBudgetInMicroCents < 0 {
+		writeError(w, http.StatusBadRequest, "remaining daily budget can't be negative")
+		return
+	}
+
+	before := s.budget(campaign)
+
+	s.Banker.SetRemainingDailyBudgetInMicroCents(campaign.Id(), set.RemainingDailyBudgetInMicroCents, s.dailyBudgetExpiration())
+
+	after := s.budget(campaign)
+	s.audit(user, "setBudget", campaign.Id(), before, after)
+
+	writeJSON(w, http.StatusOK, after)
+}
+
+// topUpBudget adds to what's left of today's budget
+func (s *AdminServer) topUpBudget(w http.ResponseWriter, r *http.Request, user string, campaign rtb.Campaign) {
+	var topUp budgetTopUp
+	if !decode(w, r, &topUp) {
+		return
+	}
+
+	if topUp.AmountInMicroCents <= 0 {
+		writeError(w, http.StatusBadRequest, "top up amount must be positive")
+		return
+	}
+
+	before := s.budget(campaign)
+
+	// Debiting a negative amount credits the account atomically, so bids won meanwhile are still counted.
+	// It starts today's budget if the campaign hasn't bid yet, so the top up adds to the daily budget.
+	s.Banker.DebitAccount(campaign.Id(), -topUp.AmountInMicroCents, campaign.DailyBudgetInMicroCents(), s.dailyBudgetExpiration())
+
+	after := s.budget(campaign)
+	s.audit(user, "topUpBudget", campaign.Id(), before, after)
+
+	writeJSON(w, http.StatusOK, after)
+}
+
+func (s *AdminServer) pacing(campaign rtb.Campaign) *PacingView {
+	v := new(PacingView)
+
+	if pacer, ok := s.Pacer.(rtb.TimeSegmentedPacer); ok {
+		v.Segment = pacer.Segment().String()
+	}
+
+	if pacer, ok := s.Pacer.(rtb.SpendTrackingPacer); ok {
+		target := pacer.TargetSpendInMicroCents(campaign)
+		spendError := pacer.SpendErrorInMicroCents(campaign)
+
+		v.TargetSpendInMicroCents = &target
+		v.SpendErrorInMicroCents = &spendError
+	}
+
+	if pacer, ok := s.Pacer.(rtb.ThrottlingPacer); ok {
+		probability := pacer.Probability(campaign.Id())
+
+		v.Mode = pacer.PacingMode(campaign.Id()).String()
+		v.Probability = &probability
+	}
+
+	return v
+}
+
+func (s *AdminServer) setPacing(w http.ResponseWriter, r *http.Request, user string, campaign rtb.Campaign) {
+	pacer, ok := s.Pacer.(rtb.ThrottlingPacer)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "the pacer doesn't have pacing modes")
+		return
+	}
+
+	var set pacingSet
+	if !decode(w, r, &set) {
+		return
+	}
+
+	var mode rtb.PacingMode
+	switch strings.ToLower(set.Mode) {
+	case rtb.PacingEven.String():
+		mode = rtb.PacingEven
+	case rtb.PacingASAP.String():
+		mode = rtb.PacingASAP
+	default:
+		writeError(w, http.StatusBadRequest, "pacing mode must be even or asap, not %q", set.Mode)
+		return
+	}
+
+	before := pacer.PacingMode(campaign.Id())
+
+	pacer.SetPacingMode(campaign.Id(), mode)
+	s.audit(user, "setPacingMode", campaign.Id(), before.String(), mode.String())
+
+	writeJSON(w, http.StatusOK, s.pacing(campaign))
+}
+
+// NewAdminServer creates the admin API. pacer and auditLogger are optional, but changes are only audited with an audit logger.
+func NewAdminServer(cp rtb.CampaignProvider, banker rtb.Banker, pacer rtb.Pacer, auditLogger rtb.AuditLogger, authenticate Authenticator) http.Handler {
+	s := new(AdminServer)
+
+	s.CampaignProvider = cp
+	s.Banker = banker
+	s.Pacer = pacer
+	s.AuditLogger = auditLogger
+	s.Authenticate = authenticate
+	s.now = time.Now
+
+	return s
+}
@@ -0,0 +1,201 @@
+package admin
+
+import (
+	"encoding/json"
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/inmemory"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryAuditLogger struct {
+	entries []*rtb.AuditEntry
+}
+
+func (l *memoryAuditLogger) LogChange(entry *rtb.AuditEntry) {
+	l.entries = append(l.entries, entry)
+}
+
+func newTestServer() (http.Handler, rtb.CampaignProvider, *memoryAuditLogger) {
+	banker := inmemory.NewInMemoryBanker()
+	cp := inmemory.NewInMemoryCampaignProvider(banker)
+	pacer := inmemory.NewProbabilisticPacer(banker, nil, time.Second, 1, rtb.PacingEven)
+	audit := new(memoryAuditLogger)
+
+	return NewAdminServer(cp, banker, pacer, audit, BasicAuthenticator(map[string]string{"alice": "secret"})), cp, audit
+}
+
+func do(s http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.SetBasicAuth("alice", "secret")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	return w
+}
+
+// TestAdminCampaignLifecycle tests creating, updating, pausing and deleting a campaign through the API
+// Expected result is each change is made in the campaign provider and recorded in the audit log with the user
+func TestAdminCampaignLifecycle(t *testing.T) {
+	s, cp, audit := newTestServer()
+
+	spec := `{"id": 500, "bidCpm": 2, "dailyBudget": 10, "targets": [{"type": "country", "value": "CAN"}]}`
+
+	if w := do(s, http.MethodPost, "/campaigns", spec); w.Code != http.StatusCreated {
+		t.FailNow()
+	}
+
+	if w := do(s, http.MethodPost, "/campaigns", spec); w.Code != http.StatusConflict {
+		t.Fail()
+	}
+
+	w := do(s, http.MethodPut, "/campaigns/500", `{"bidCpm": 3, "dailyBudget": 10, "targets": [{"type": "country", "value": "USA"}]}`)
+	if w.Code != http.StatusOK {
+		t.FailNow()
+	}
+
+	var v CampaignView
+	if json.Unmarshal(w.Body.Bytes(), &v) != nil || v.BidCpmInMicroCents != rtb.CpmToMicroCents(3) || len(v.Targets) != 1 || v.Targets[0].Value != "USA" {
+		t.Fail()
+	}
+
+	if do(s, http.MethodPost, "/campaigns/500/pause", "").Code != http.StatusOK || cp.ReadCampaign(500).Status() != rtb.CampaignPaused {
+		t.Fail()
+	}
+
+	if do(s, http.MethodPost, "/campaigns/500/resume", "").Code != http.StatusOK || cp.ReadCampaign(500).Status() != rtb.CampaignActive {
+		t.Fail()
+	}
+
+	if do(s, http.MethodDelete, "/campaigns/500", "").Code != http.StatusNoContent || cp.ReadCampaign(500).Status() != rtb.CampaignArchived {
+		t.Fail()
+	}
+
+	// Archived campaigns can be read, but not changed
+	if do(s, http.MethodGet, "/campaigns/500", "").Code != http.StatusOK || do(s, http.MethodPost, "/campaigns/500/resume", "").Code != http.StatusConflict {
+		t.Fail()
+	}
+
+	actions := make([]string, 0, len(audit.entries))
+	for _, entry := range audit.entries {
+		if entry.User != "alice" || entry.CampaignId != 500 {
+			t.Fail()
+		}
+
+		actions = append(actions, entry.Action)
+	}
+
+	if strings.Join(actions, ",") != "create,update,pause,resume,delete" {
+		t.Fail()
+	}
+}
+
+// TestAdminBudget tests reading, setting and topping up a campaign's remaining daily budget
+// Expected result is a top up adds to the daily budget of a campaign that hasn't bid today, and to what's left after setting it
+func TestAdminBudget(t *testing.T) {
+	s, _, audit := newTestServer()
+
+	do(s, http.MethodPost, "/campaigns", `{"id": 501, "bidCpm": 2, "dailyBudget": 10, "targets": [{"type": "allRequests", "value": "*"}]}`)
+
+	var budget BudgetView
+
+	w := do(s, http.MethodPost, "/campaigns/501/budget", `{"amountInMicroCents": 100}`)
+	if json.Unmarshal(w.Body.Bytes(), &budget) != nil || budget.RemainingDailyBudgetInMicroCents != rtb.DollarsToMicroCents(10)+100 {
+		t.Fail()
+	}
+
+	do(s, http.MethodPut, "/campaigns/501/budget", `{"remainingDailyBudgetInMicroCents": 50}`)
+	do(s, http.MethodPost, "/campaigns/501/budget", `{"amountInMicroCents": 25}`)
+
+	w = do(s, http.MethodGet, "/campaigns/501/budget", "")
+	if json.Unmarshal(w.Body.Bytes(), &budget) != nil || budget.RemainingDailyBudgetInMicroCents != 75 {
+		t.Fail()
+	}
+
+	if len(audit.entries) != 4 || audit.entries[3].Action != "topUpBudget" {
+		t.Fail()
+	}
+}
+
+// biddingBanker debits a bid from an account every time it's debited or credited, as if the campaign won a bid
+// right after the balance was returned
+type biddingBanker struct {
+	rtb.Banker
+	bidInMicroCents int64
+}
+
+func (b *biddingBanker) DebitAccount(account int64, amount int64, dailyBudget int64, dailyBudgetExpiration time.Time) (int64, error) {
+	remaining, err := b.Banker.DebitAccount(account, amount, dailyBudget, dailyBudgetExpiration)
+
+	b.Banker.DebitAccount(account, b.bidInMicroCents, dailyBudget, dailyBudgetExpiration)
+
+	return remaining, err
+}
+
+// TestAdminTopUpKeepsBids tests that a top up doesn't overwrite bids debited while it's made
+// Expected result is the remaining budget is the daily budget plus the top up, less the bid
+func TestAdminTopUpKeepsBids(t *testing.T) {
+	banker := &biddingBanker{Banker: inmemory.NewInMemoryBanker(), bidInMicroCents: 10}
+	cp := inmemory.NewInMemoryCampaignProvider(banker.Banker)
+	s := NewAdminServer(cp, banker, nil, nil, BasicAuthenticator(map[string]string{"alice": "secret"}))
+
+	do(s, http.MethodPost, "/campaigns", `{"id": 504, "bidCpm": 2, "dailyBudget": 10, "targets": [{"type": "allRequests", "value": "*"}]}`)
+
+	var budget BudgetView
+
+	w := do(s, http.MethodPost, "/campaigns/504/budget", `{"amountInMicroCents": 100}`)
+	if json.Unmarshal(w.Body.Bytes(), &budget) != nil || budget.RemainingDailyBudgetInMicroCents != rtb.DollarsToMicroCents(10)+100-10 {
+		t.Fail()
+	}
+}
+
+// TestAdminValidation tests that bad requests are rejected without changing anything
+// Expected result is an error status for each request, and nothing in the audit log
+func TestAdminValidation(t *testing.T) {
+	s, _, audit := newTestServer()
+
+	do(s, http.MethodPost, "/campaigns", `{"id": 502, "bidCpm": 2, "dailyBudget": 10, "targets": [{"type": "allRequests", "value": "*"}]}`)
+	audit.entries = nil
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodPost, "/campaigns", `{"id": 503, "bidCpm": 2, "dailyBudget": 0}`, http.StatusBadRequest},
+		{http.MethodPost, "/campaigns", `{"id": 503, "unknown": true}`, http.StatusBadRequest},
+		{http.MethodPut, "/campaigns/502", `{"id": 503, "bidCpm": 2, "dailyBudget": 10, "targets": [{"type": "allRequests", "value": "*"}]}`, http.StatusBadRequest},
+		{http.MethodGet, "/campaigns/503", "", http.StatusNotFound},
+		{http.MethodGet, "/campaigns/abc", "", http.StatusBadRequest},
+		{http.MethodPut, "/campaigns/502/budget", `{"remainingDailyBudgetInMicroCents": -1}`, http.StatusBadRequest},
+		{http.MethodPost, "/campaigns/502/budget", `{"amountInMicroCents": 0}`, http.StatusBadRequest},
+		{http.MethodPut, "/campaigns/502/creatives", `[{"id": ""}]`, http.StatusBadRequest},
+		{http.MethodPut, "/campaigns/502/pacing", `{"mode": "fast"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/campaigns/502", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, request := range requests {
+		if w := do(s, request.method, request.path, request.body); w.Code != request.code {
+			t.Errorf("%v %v returned %v, expected %v", request.method, request.path, w.Code, request.code)
+		}
+	}
+
+	if len(audit.entries) != 0 {
+		t.Fail()
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/campaigns", nil)
+	r.SetBasicAuth("alice", "wrong")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fail()
+	}
+}
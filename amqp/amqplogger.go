@@ -2,13 +2,130 @@ package amqp
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/evandigby/rtb"
 	"github.com/streadway/amqp"
+	"log"
+	"sync"
+	"time"
 )
 
+// The number of channels each connection publishes on
+const publishChannels = 8
+
+// The number of messages kept while disconnected from the broker. Messages logged while it's full are dropped.
+const maxPendingMessages = 10000
+
+// How long to wait before reconnecting after a failed attempt. The delay doubles after each failure, up to the maximum.
+const minReconnectDelay = 100 * time.Millisecond
+const maxReconnectDelay = 30 * time.Second
+
+var errConnectionLost = errors.New("Connection to the broker was lost.")
+
+// amqpConnection is the part of *amqp.Connection the logger uses, so tests can stand in for the broker
+type amqpConnection interface {
+	Channel() (amqpChannel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// amqpChannel is the part of *amqp.Channel the logger uses
+type amqpChannel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+type dialedConnection struct {
+	*amqp.Connection
+}
+
+func (c dialedConnection) Channel() (amqpChannel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+func dialBroker(addr string) (amqpConnection, error) {
+	conn, err := amqp.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return dialedConnection{conn}, nil
+}
+
+// amqpSession is one connection to the broker, with a pool of channels to publish on
+type amqpSession struct {
+	conn     amqpConnection
+	channels chan amqpChannel
+	// Closed once the connection closes
+	lost chan struct{}
+}
+
+func (s *amqpSession) publish(exchange string, body []byte) error {
+	var ch amqpChannel
+
+	select {
+	case ch = <-s.channels:
+	case <-s.lost:
+		return errConnectionLost
+	}
+
+	err := ch.Publish(
+		exchange, // exchange
+		"",       // routing key
+		true,     // mandatory
+		false,    // immediate
+		amqp.Publishing{
+			ContentType: "applications/json",
+			Body:        body,
+		})
+
+	if err != nil {
+		// A failed publish closes the channel, so it's replaced to keep the pool full
+		ch.Close()
+
+		replacement, channelErr := s.conn.Channel()
+		if channelErr != nil {
+			// Without a replacement the pool shrinks until publishing blocks, so the connection is closed to be reopened
+			s.conn.Close()
+			return err
+		}
+
+		ch = replacement
+	}
+
+	s.channels <- ch
+
+	return err
+}
+
+// AmqpBidLogger publishes log items on one long lived connection, reconnecting with backoff when it's lost.
+// Items that can't be published are kept, up to a limit, and published in order on the next connection.
+// Channels returned by LogChannel consume on every connection, and are closed when the logger is closed.
 type AmqpBidLogger struct {
 	addr      string
 	appDomain string
+	dial      func(addr string) (amqpConnection, error)
+
+	mutex     sync.Mutex
+	session   *amqpSession
+	pending   [][]byte
+	dropped   int64
+	consumers []chan *rtb.BidLogItem
+	// Forwards deliveries to consumers
+	forwarders sync.WaitGroup
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
 }
 
 func (l *AmqpBidLogger) logName() string {
@@ -19,113 +136,304 @@ func (l *AmqpBidLogger) queueName() string {
 	return l.appDomain + ":" + "logQueue"
 }
 
-func (l *AmqpBidLogger) LogItem(logItem *rtb.BidLogItem) {
-	conn, err := amqp.Dial(l.addr)
+// connect opens a connection and its channels, and declares the exchange
+func (l *AmqpBidLogger) connect() (*amqpSession, error) {
+	conn, err := l.dial(l.addr)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	defer conn.Close()
-
-	ch, err := conn.Channel()
-	if err != nil {
-		panic(err)
-	}
+	s := new(amqpSession)
+	s.conn = conn
+	s.channels = make(chan amqpChannel, publishChannels)
+	s.lost = make(chan struct{})
 
-	defer ch.Close()
+	for i := 0; i < publishChannels; i++ {
+		ch, err := conn.Channel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
 
-	name := l.logName()
+		s.channels <- ch
+	}
 
+	ch := <-s.channels
 	err = ch.ExchangeDeclare(
-		name,     // name
-		"direct", // type
-		true,     // durable
-		false,    // auto-deleted
-		false,    // internal
-		false,    // no-wait
-		nil,      // arguments
+		l.logName(), // name
+		"direct",    // type
+		true,        // durable
+		false,       // auto-deleted
+		false,       // internal
+		false,       // no-wait
+		nil,         // arguments
 	)
-
-	js, err := json.Marshal(logItem)
+	s.channels <- ch
 
 	if err != nil {
-		return
+		conn.Close()
+		return nil, err
 	}
 
-	err = ch.Publish(
-		name,  // exchange
-		"",    // routing key
-		true,  // mandatory
-		false, // immediate
-		amqp.Publishing{
-			ContentType: "applications/json",
-			Body:        js,
-		})
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
 
-	if err != nil {
-		panic(err)
+	go func() {
+		<-closed
+		close(s.lost)
+	}()
+
+	return s, nil
+}
+
+// keep buffers a message until the broker is reachable again
+func (l *AmqpBidLogger) keep(body []byte) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.pending) >= maxPendingMessages {
+		l.dropped++
+		return
 	}
 
+	l.pending = append(l.pending, body)
 }
 
-func (l *AmqpBidLogger) LogChannel() chan *rtb.BidLogItem {
-	conn, err := amqp.Dial(l.addr)
-	if err != nil {
-		panic(err)
+// resume publishes the messages kept while disconnected, and then lets new items be published on the session.
+// Returns the consumers to start on the session; consumers added later start themselves.
+func (l *AmqpBidLogger) resume(s *amqpSession) ([]chan *rtb.BidLogItem, bool) {
+	for {
+		l.mutex.Lock()
+		pending := l.pending
+		l.pending = nil
+
+		if len(pending) == 0 {
+			l.session = s
+			consumers := append([]chan *rtb.BidLogItem{}, l.consumers...)
+			l.mutex.Unlock()
+			return consumers, true
+		}
+		l.mutex.Unlock()
+
+		for i, body := range pending {
+			if err := s.publish(l.logName(), body); err != nil {
+				l.mutex.Lock()
+				l.pending = append(pending[i:], l.pending...)
+				l.mutex.Unlock()
+				return nil, false
+			}
+		}
 	}
+}
 
-	ch, err := conn.Channel()
+// consume delivers the items logged on the exchange to logItems, until the session's connection closes
+func (l *AmqpBidLogger) consume(s *amqpSession, logItems chan *rtb.BidLogItem) error {
+	ch, err := s.conn.Channel()
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	name := l.logName()
-	queueName := l.queueName()
-
 	q, err := ch.QueueDeclare(
-		queueName, // name
-		false,     // durable
-		false,     // delete when usused
-		true,      // exclusive
-		false,     // no-wait
-		nil,       // arguments
+		l.queueName(), // name
+		false,         // durable
+		false,         // delete when usused
+		true,          // exclusive
+		false,         // no-wait
+		nil,           // arguments
 	)
 
-	err = ch.QueueBind(
-		queueName, // queue name
-		"",        // routing key
-		name,      // exchange
-		false,
-		nil)
-
-	msgs, err := ch.Consume(
-		q.Name, // queue
-		"",     // consumer
-		true,   // auto ack
-		false,  // exclusive
-		false,  // no local
-		false,  // no wait
-		nil,    // args
-	)
+	if err == nil {
+		err = ch.QueueBind(
+			q.Name,      // queue name
+			"",          // routing key
+			l.logName(), // exchange
+			false,
+			nil)
+	}
 
-	logItems := make(chan *rtb.BidLogItem)
+	var msgs <-chan amqp.Delivery
+
+	if err == nil {
+		msgs, err = ch.Consume(
+			q.Name, // queue
+			"",     // consumer
+			true,   // auto ack
+			false,  // exclusive
+			false,  // no local
+			false,  // no wait
+			nil,    // args
+		)
+	}
+
+	if err != nil {
+		ch.Close()
+		return err
+	}
+
+	l.forwarders.Add(1)
 
 	go func() {
+		defer l.forwarders.Done()
+
 		for d := range msgs {
 			var logItem rtb.BidLogItem
 
-			err = json.Unmarshal(d.Body, &logItem)
+			if err := json.Unmarshal(d.Body, &logItem); err != nil {
+				log.Println("Bid logger received an item it could not read:", err)
+				continue
+			}
 
-			logItems <- &logItem
+			select {
+			case logItems <- &logItem:
+			case <-l.closing:
+				return
+			}
 		}
 	}()
 
+	return nil
+}
+
+// startConsumers consumes on a new session. If a consumer can't start, the session is closed, so it's retried on the next one.
+func (l *AmqpBidLogger) startConsumers(s *amqpSession, consumers []chan *rtb.BidLogItem) {
+	for _, logItems := range consumers {
+		if err := l.consume(s, logItems); err != nil {
+			log.Println("Bid logger could not consume from the broker:", err)
+			s.conn.Close()
+			return
+		}
+	}
+}
+
+func (l *AmqpBidLogger) wait(d time.Duration) {
+	select {
+	case <-l.closing:
+	case <-time.After(d):
+	}
+}
+
+func (l *AmqpBidLogger) isClosing() bool {
+	select {
+	case <-l.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// maintain keeps a connection open until the logger is closed
+func (l *AmqpBidLogger) maintain() {
+	defer close(l.done)
+
+	delay := minReconnectDelay
+
+	for !l.isClosing() {
+		s, err := l.connect()
+
+		if err != nil {
+			log.Println("Bid logger could not connect to the broker:", err)
+
+			l.wait(delay)
+
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+
+			continue
+		}
+
+		delay = minReconnectDelay
+
+		if consumers, ok := l.resume(s); ok {
+			l.startConsumers(s, consumers)
+
+			select {
+			case <-s.lost:
+				log.Println("Bid logger lost its connection to the broker.")
+			case <-l.closing:
+			}
+		}
+
+		l.mutex.Lock()
+		l.session = nil
+		l.mutex.Unlock()
+
+		s.conn.Close()
+	}
+}
+
+func (l *AmqpBidLogger) LogItem(logItem *rtb.BidLogItem) {
+	js, err := json.Marshal(logItem)
+
+	if err != nil {
+		return
+	}
+
+	l.mutex.Lock()
+	s := l.session
+	l.mutex.Unlock()
+
+	if s == nil || s.publish(l.logName(), js) != nil {
+		l.keep(js)
+	}
+}
+
+// Dropped returns how many items were dropped because too many were logged while disconnected
+func (l *AmqpBidLogger) Dropped() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.dropped
+}
+
+// Close closes the connection, and the channels returned by LogChannel. Items that haven't been published yet are lost.
+func (l *AmqpBidLogger) Close() {
+	l.closeOnce.Do(func() { close(l.closing) })
+	<-l.done
+
+	l.forwarders.Wait()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, logItems := range l.consumers {
+		close(logItems)
+	}
+
+	l.consumers = nil
+}
+
+// LogChannel returns a channel receiving every item logged on the broker, by any bidder of the app domain.
+// It consumes on the logger's connection, and again on each new connection after the broker is lost.
+func (l *AmqpBidLogger) LogChannel() chan *rtb.BidLogItem {
+	logItems := make(chan *rtb.BidLogItem)
+
+	l.mutex.Lock()
+	l.consumers = append(l.consumers, logItems)
+	s := l.session
+	l.mutex.Unlock()
+
+	if s != nil {
+		l.startConsumers(s, []chan *rtb.BidLogItem{logItems})
+	}
+
 	return logItems
 }
 
-func NewAmqpBidLogger(addr string, appDomain string) rtb.BidLogger {
+func newAmqpBidLogger(addr string, appDomain string, dial func(addr string) (amqpConnection, error)) *AmqpBidLogger {
 	l := new(AmqpBidLogger)
 	l.addr = addr
 	l.appDomain = appDomain
+	l.dial = dial
+	l.closing = make(chan struct{})
+	l.done = make(chan struct{})
+
+	go l.maintain()
+
 	return l
 }
+
+// NewAmqpBidLogger logs to the broker at addr. The connection is opened in the background, so items can be logged right away.
+// It returns the AmqpBidLogger rather than a BidLogger, so callers can Close it and watch Dropped.
+func NewAmqpBidLogger(addr string, appDomain string) *AmqpBidLogger {
+	return newAmqpBidLogger(addr, appDomain, dialBroker)
+}
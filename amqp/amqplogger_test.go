@@ -0,0 +1,466 @@
+package amqp
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/evandigby/rtb"
+	"github.com/streadway/amqp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker stands in for the broker: it accepts or refuses connections, and delivers what's published to consumers
+type fakeBroker struct {
+	mutex sync.Mutex
+	// The number of dials to refuse before accepting one
+	refuse  int
+	dials   []time.Time
+	conns   []*fakeConnection
+	opened  int
+	failing int
+	// The number of queue binds to fail
+	failBinds int
+	// The number of channels to refuse to open
+	failChannels int
+
+	published []string
+	consumers []chan amqp.Delivery
+}
+
+type fakeConnection struct {
+	broker *fakeBroker
+
+	mutex     sync.Mutex
+	closed    bool
+	notify    []chan *amqp.Error
+	consumers []chan amqp.Delivery
+}
+
+type fakeChannel struct {
+	conn *fakeConnection
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+func (b *fakeBroker) dial(addr string) (amqpConnection, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.dials = append(b.dials, time.Now())
+
+	if b.refuse > 0 {
+		b.refuse--
+		return nil, errors.New("Connection refused.")
+	}
+
+	c := &fakeConnection{broker: b}
+	b.conns = append(b.conns, c)
+
+	return c, nil
+}
+
+func (b *fakeBroker) setRefuse(refuse int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.refuse = refuse
+}
+
+// drop closes every connection, like a broker restart
+func (b *fakeBroker) drop() {
+	b.mutex.Lock()
+	conns := b.conns
+	b.conns = nil
+	b.mutex.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// failPublishes makes the next publishes fail, and close the channel they were published on
+func (b *fakeBroker) failPublishes(failing int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failing = failing
+}
+
+func (b *fakeBroker) connected() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.conns) > 0
+}
+
+func (b *fakeBroker) messages() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return append([]string{}, b.published...)
+}
+
+func (b *fakeBroker) channelsOpened() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.opened
+}
+
+func (c *fakeConnection) Channel() (amqpChannel, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	c.broker.mutex.Lock()
+	defer c.broker.mutex.Unlock()
+
+	if c.broker.failChannels > 0 {
+		c.broker.failChannels--
+		return nil, errors.New("Channel limit reached.")
+	}
+
+	c.broker.opened++
+
+	return &fakeChannel{conn: c}, nil
+}
+
+func (c *fakeConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Like amqp.Connection, a receiver registered after the close is closed right away
+	if c.closed {
+		close(receiver)
+		return receiver
+	}
+
+	c.notify = append(c.notify, receiver)
+
+	return receiver
+}
+
+func (c *fakeConnection) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return amqp.ErrClosed
+	}
+
+	c.closed = true
+
+	for _, receiver := range c.notify {
+		close(receiver)
+	}
+
+	c.broker.mutex.Lock()
+	for _, consumer := range c.consumers {
+		for i, registered := range c.broker.consumers {
+			if registered == consumer {
+				c.broker.consumers = append(c.broker.consumers[:i], c.broker.consumers[i+1:]...)
+				break
+			}
+		}
+
+		close(consumer)
+	}
+	c.broker.mutex.Unlock()
+
+	return nil
+}
+
+func (ch *fakeChannel) isClosed() bool {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+
+	ch.conn.mutex.Lock()
+	defer ch.conn.mutex.Unlock()
+
+	return ch.closed || ch.conn.closed
+}
+
+func (ch *fakeChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	return nil
+}
+
+func (ch *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+
+func (ch *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	b := ch.conn.broker
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failBinds > 0 {
+		b.failBinds--
+		return errors.New("Exchange not found.")
+	}
+
+	return nil
+}
+
+func (ch *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	ch.conn.mutex.Lock()
+	defer ch.conn.mutex.Unlock()
+
+	if ch.conn.closed {
+		return nil, amqp.ErrClosed
+	}
+
+	deliveries := make(chan amqp.Delivery, 100)
+	ch.conn.consumers = append(ch.conn.consumers, deliveries)
+
+	ch.conn.broker.mutex.Lock()
+	ch.conn.broker.consumers = append(ch.conn.broker.consumers, deliveries)
+	ch.conn.broker.mutex.Unlock()
+
+	return deliveries, nil
+}
+
+func (ch *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if ch.isClosed() {
+		return amqp.ErrClosed
+	}
+
+	b := ch.conn.broker
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failing > 0 {
+		b.failing--
+
+		ch.mutex.Lock()
+		ch.closed = true
+		ch.mutex.Unlock()
+
+		return amqp.ErrClosed
+	}
+
+	b.published = append(b.published, string(msg.Body))
+
+	for _, consumer := range b.consumers {
+		consumer <- amqp.Delivery{Body: msg.Body}
+	}
+
+	return nil
+}
+
+func (ch *fakeChannel) Close() error {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+
+	ch.closed = true
+
+	return nil
+}
+
+// waitFor waits up to 5 seconds for a condition
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func logItem(id string) *rtb.BidLogItem {
+	return &rtb.BidLogItem{Domain: id}
+}
+
+func itemId(message string) string {
+	var item rtb.BidLogItem
+	json.Unmarshal([]byte(message), &item)
+
+	return item.Domain
+}
+
+// Test connecting to a broker that refuses the first connections
+// Expected result is the logger keeps trying, waiting twice as long after each failure, and connects once it's accepted
+func TestAmqpBidLoggerReconnectBackoff(t *testing.T) {
+	b := &fakeBroker{refuse: 3}
+
+	l := newAmqpBidLogger("", "test", b.dial)
+	defer l.Close()
+
+	if !waitFor(b.connected) {
+		t.FailNow()
+	}
+
+	b.mutex.Lock()
+	dials := b.dials
+	b.mutex.Unlock()
+
+	if len(dials) != 4 {
+		t.FailNow()
+	}
+
+	for i := 1; i < len(dials); i++ {
+		if dials[i].Sub(dials[i-1]) < minReconnectDelay<<uint(i-1) {
+			t.Fail()
+		}
+	}
+}
+
+// Test logging through a broker outage
+// Expected result is items logged while disconnected are published once the broker is back, in the order they were logged
+func TestAmqpBidLoggerReplaysPendingInOrder(t *testing.T) {
+	b := &fakeBroker{}
+
+	l := newAmqpBidLogger("", "test", b.dial)
+	defer l.Close()
+
+	if !waitFor(b.connected) {
+		t.FailNow()
+	}
+
+	l.LogItem(logItem("1"))
+
+	b.setRefuse(1000)
+	b.drop()
+
+	for _, id := range []string{"2", "3", "4"} {
+		l.LogItem(logItem(id))
+	}
+
+	b.setRefuse(0)
+
+	if !waitFor(func() bool { return len(b.messages()) == 4 }) {
+		t.FailNow()
+	}
+
+	for i, message := range b.messages() {
+		if itemId(message) != []string{"1", "2", "3", "4"}[i] {
+			t.Fail()
+		}
+	}
+
+	if l.Dropped() != 0 {
+		t.Fail()
+	}
+}
+
+// Test logging more items than can be kept while disconnected
+// Expected result is the items over the limit are dropped and counted
+func TestAmqpBidLoggerDropsWhenFull(t *testing.T) {
+	b := &fakeBroker{refuse: 1000}
+
+	l := newAmqpBidLogger("", "test", b.dial)
+	defer l.Close()
+
+	for i := 0; i < maxPendingMessages+5; i++ {
+		l.LogItem(logItem("dropped"))
+	}
+
+	if l.Dropped() != 5 {
+		t.Fail()
+	}
+}
+
+// Test a publish failing on one of the pooled channels, which closes it
+// Expected result is the channel is replaced, so every later item is published
+func TestAmqpBidLoggerReplacesFailedChannel(t *testing.T) {
+	b := &fakeBroker{}
+
+	l := newAmqpBidLogger("", "test", b.dial)
+	defer l.Close()
+
+	if !waitFor(b.connected) || !waitFor(func() bool { return b.channelsOpened() == publishChannels }) {
+		t.FailNow()
+	}
+
+	b.failPublishes(1)
+	l.LogItem(logItem("failed"))
+
+	for i := 0; i < publishChannels*2; i++ {
+		l.LogItem(logItem("published"))
+	}
+
+	if len(b.messages()) != publishChannels*2 || b.channelsOpened() != publishChannels+1 {
+		t.Fail()
+	}
+}
+
+// Test a publish failing when no replacement channel can be opened
+// Expected result is the connection is reopened with a full pool, and the item is published on it instead of logging blocking
+// once the pool runs out
+func TestAmqpBidLoggerReconnectsWithoutReplacementChannel(t *testing.T) {
+	b := &fakeBroker{}
+
+	l := newAmqpBidLogger("", "test", b.dial)
+	defer l.Close()
+
+	if !waitFor(b.connected) || !waitFor(func() bool { return b.channelsOpened() == publishChannels }) {
+		t.FailNow()
+	}
+
+	b.mutex.Lock()
+	b.failChannels = 1
+	b.failing = 1
+	b.mutex.Unlock()
+
+	l.LogItem(logItem("failed"))
+
+	if !waitFor(func() bool { return len(b.messages()) == 1 }) {
+		t.FailNow()
+	}
+
+	for i := 0; i < publishChannels*2; i++ {
+		l.LogItem(logItem("published"))
+	}
+
+	if !waitFor(func() bool { return len(b.messages()) == publishChannels*2+1 }) {
+		t.FailNow()
+	}
+
+	if itemId(b.messages()[0]) != "failed" || b.channelsOpened() != publishChannels*2 {
+		t.Fail()
+	}
+}
+
+// Test consuming the log across a failed queue bind and a broker outage
+// Expected result is consuming is retried on a new connection instead of panicking, items are received after each
+// reconnect, and the channel is closed with the logger
+func TestAmqpBidLoggerLogChannel(t *testing.T) {
+	b := &fakeBroker{failBinds: 1}
+
+	l := newAmqpBidLogger("", "test", b.dial)
+
+	logItems := l.LogChannel()
+
+	receive := func(id string) bool {
+		l.LogItem(logItem(id))
+
+		select {
+		case item := <-logItems:
+			return item.Domain == id
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+
+	if !waitFor(func() bool { return receive("first") }) {
+		t.FailNow()
+	}
+
+	b.drop()
+
+	if !waitFor(func() bool { return receive("after outage") }) {
+		t.Fail()
+	}
+
+	l.Close()
+
+	for range logItems {
+	}
+}
@@ -0,0 +1,105 @@
+package rtb
+
+// PriorityTier decides which of our campaigns bid first. Campaigns in a higher tier always beat campaigns in a lower tier,
+// whatever they bid.
+type PriorityTier int
+
+const (
+	// PriorityOpen campaigns bid in the open auction
+	PriorityOpen PriorityTier = 0
+	// PriorityPrivateMarketplace campaigns bid on a private marketplace deal. They only get this tier on impressions
+	// that carry the deal they target, and bid as open campaigns otherwise.
+	PriorityPrivateMarketplace PriorityTier = 1
+	// PriorityGuaranteed campaigns have guaranteed delivery, and beat every other campaign
+	PriorityGuaranteed PriorityTier = 2
+)
+
+func (p PriorityTier) String() string {
+	switch p {
+	case PriorityOpen:
+		return "open"
+	case PriorityPrivateMarketplace:
+		return "pmp"
+	case PriorityGuaranteed:
+		return "guaranteed"
+	}
+
+	return "unknown"
+}
+
+// AuctionBid defines a campaign taking part in an internal auction for an impression
+type AuctionBid struct {
+	Campaign             Campaign
+	Priority             PriorityTier
+	DealID               string
+	BidCpmInMicroCents   int64
+	BidFloorInMicroCents int64
+}
+
+// AuctionOutcome defines the result of an internal auction. Winner and RunnerUp index the bids, and are -1 when there isn't one.
+type AuctionOutcome struct {
+	Winner               int
+	RunnerUp             int
+	PriceCpmInMicroCents int64
+}
+
+// InternalAuction picks which of our campaigns bids on an impression, and the price it bids
+type InternalAuction interface {
+	Run(bids []AuctionBid) AuctionOutcome
+}
+
+// InternalAuctionResult records an internal auction won by one of our campaigns, for the bid log
+type InternalAuctionResult struct {
+	ImpID                   string       `json:"imp,omitempty"`
+	CampaignId              int64        `json:"c,omitempty"`
+	Priority                PriorityTier `json:"p,omitempty"`
+	DealID                  string       `json:"deal,omitempty"`
+	BidCpmInMicroCents      int64        `json:"b,omitempty"`
+	PriceCpmInMicroCents    int64        `json:"pr,omitempty"`
+	RunnerUpCampaignId      int64        `json:"rc,omitempty"`
+	RunnerUpCpmInMicroCents int64        `json:"rb,omitempty"`
+	Bidders                 int          `json:"n,omitempty"`
+}
+
+// RankAuctionBids returns the bid in the highest priority tier with the highest CPM, and the next highest bid in the same tier.
+// Ties go to the bid that comes first. Either is -1 if there isn't one.
+func RankAuctionBids(bids []AuctionBid) (winner int, runnerUp int) {
+	winner, runnerUp = -1, -1
+
+	better := func(i int, j int) bool {
+		if bids[i].Priority != bids[j].Priority {
+			return bids[i].Priority > bids[j].Priority
+		}
+		return bids[i].BidCpmInMicroCents > bids[j].BidCpmInMicroCents
+	}
+
+	for i := range bids {
+		if winner < 0 || better(i, winner) {
+			if winner >= 0 && bids[winner].Priority == bids[i].Priority {
+				runnerUp = winner
+			} else {
+				runnerUp = -1
+			}
+			winner = i
+		} else if bids[i].Priority == bids[winner].Priority && (runnerUp < 0 || better(i, runnerUp)) {
+			runnerUp = i
+		}
+	}
+
+	return winner, runnerUp
+}
+
+// ImpDeal returns the impression's private marketplace deal with the id, if it has one
+func ImpDeal(imp *Imp, dealId string) (Deal, bool) {
+	if imp.Pmp == nil || dealId == "" {
+		return Deal{}, false
+	}
+
+	for _, deal := range imp.Pmp.Deals {
+		if deal.ID == dealId {
+			return deal, true
+		}
+	}
+
+	return Deal{}, false
+}
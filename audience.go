@@ -0,0 +1,55 @@
+package rtb
+
+import (
+	"strings"
+)
+
+// SegmentTarget returns the target for a third party audience segment, identified by the data provider's id and the segment's id
+func SegmentTarget(dataProviderId string, segmentId string) Target {
+	return Target{Type: UserSegment, Value: dataProviderId + ":" + segmentId}
+}
+
+// AudienceTarget returns the constraint limiting a campaign to devices in any of the first party audience lists
+func AudienceTarget(lists ...string) Target {
+	return Target{Type: Audience, Value: strings.Join(lists, ",")}
+}
+
+// Targeting creates a list of targets from the audience segments the user is in
+func (u *User) Targeting() []Target {
+	targets := make([]Target, 0)
+
+	for _, data := range u.Data {
+		if data.ID == "" {
+			continue
+		}
+
+		for _, segment := range data.Segment {
+			if segment.ID != "" {
+				targets = append(targets, SegmentTarget(data.ID, segment.ID))
+			}
+		}
+	}
+
+	return targets
+}
+
+// audienceSatisfied checks an Audience value: list names separated by ",". Any of the request's device ids must be in any of the lists.
+func audienceSatisfied(value string, targets []Target, lists ListProvider) bool {
+	if lists == nil {
+		return false
+	}
+
+	for _, target := range targets {
+		if target.Type != DeviceID {
+			continue
+		}
+
+		for _, name := range strings.Split(value, ",") {
+			if lists.ListContains(name, target.Value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
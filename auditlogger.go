@@ -0,0 +1,16 @@
+package rtb
+
+// AuditEntry records a change made to a campaign, its budget or its pacing, and who made it
+type AuditEntry struct {
+	TimestampInNanoseconds int64       `json:"ts"`
+	User                   string      `json:"user"`
+	Action                 string      `json:"action"`
+	CampaignId             int64       `json:"campaignId"`
+	Before                 interface{} `json:"before,omitempty"`
+	After                  interface{} `json:"after,omitempty"`
+}
+
+// AuditLogger records changes made through the admin API
+type AuditLogger interface {
+	LogChange(entry *AuditEntry)
+}
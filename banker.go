@@ -11,7 +11,7 @@ import (
 // Although this should be close to 100% accurate, users of this interface
 // should not depended on implementations for a true transaction log and accounting purposes.
 type Banker interface {
-	// DebitAccount subtracts an amount from an account
+	// DebitAccount subtracts an amount from an account. A negative amount credits it.
 	// Returns the remaining remainingDailyBudgetInMicroCents after the transaction, and an error if the transaction was unsuccessful
 	DebitAccount(account int64, amount int64, dailyBudget int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCentsInMicroCents int64, err error)
 	// Returns the remainingDailyBudgetInMicroCents for the account, or zero for a non-existant account
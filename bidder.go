@@ -3,4 +3,10 @@ package rtb
 // Bidder defines a type which can bid on bid requests
 type Bidder interface {
 	Bid() (response *BidResponse, campaignRemainingDailyBudget map[string]int64, err error)
+
+	// NoBidReason explains why Bid returned no response
+	NoBidReason() NoBidReason
+
+	// AuctionResults returns the internal auctions won by the bids Bid returned
+	AuctionResults() []InternalAuctionResult
 }
@@ -2,12 +2,14 @@ package rtb
 
 // BidLogItem defines the structure of a bidder log
 type BidLogItem struct {
-	Domain                            string           `json:"d,omitempty"`
-	BidRequest                        *BidRequest      `json:"rq,omitempty"`
-	BidResponse                       *BidResponse     `json:"rp,omitempty"`
-	RemainingDailyBudgetsInMicroCents map[string]int64 `json:"b,omitempty"`
-	StartTimestampInNanoseconds       int64            `json:"sts,omitempty"`
-	EndTimestampInNanoseconds         int64            `json:"ets,omitempty"`
+	Domain                            string                  `json:"d,omitempty"`
+	BidRequest                        *BidRequest             `json:"rq,omitempty"`
+	BidResponse                       *BidResponse            `json:"rp,omitempty"`
+	RemainingDailyBudgetsInMicroCents map[string]int64        `json:"b,omitempty"`
+	StartTimestampInNanoseconds       int64                   `json:"sts,omitempty"`
+	EndTimestampInNanoseconds         int64                   `json:"ets,omitempty"`
+	NoBidReason                       NoBidReason             `json:"nbr,omitempty"`
+	InternalAuctions                  []InternalAuctionResult `json:"ia,omitempty"`
 }
 
 // BidLogProducer defines a type that can log bid requests
@@ -26,3 +28,37 @@ type BidLogger interface {
 	BidLogProducer
 	BidLogConsumer
 }
+
+// BatchBidLogProducer defines a producer that can log several items at once, e.g. in a single write
+type BatchBidLogProducer interface {
+	BidLogProducer
+	LogItems(logItems []*BidLogItem)
+}
+
+// BufferFullPolicy defines what an asynchronous producer does with an item when its buffer is full
+type BufferFullPolicy int
+
+const (
+	// DropWhenFull drops the item, so logging never slows down bidding
+	DropWhenFull BufferFullPolicy = 0
+	// BlockWhenFull waits for room in the buffer, so no item is lost
+	BlockWhenFull BufferFullPolicy = 1
+)
+
+// BidLogStats counts what happened to the items given to an asynchronous producer
+type BidLogStats struct {
+	// Logged items were passed to the wrapped producer
+	Logged int64
+	// Dropped items didn't fit in the buffer, or were logged after the producer was closed
+	Dropped int64
+	// Failed items were in a batch the wrapped producer panicked on
+	Failed int64
+}
+
+// AsyncBidLogProducer defines a producer that buffers items and logs them in the background, off the bidding path
+type AsyncBidLogProducer interface {
+	BidLogProducer
+	Stats() BidLogStats
+	// Close logs every buffered item, and waits for them to be logged. Items logged after Close are dropped.
+	Close()
+}
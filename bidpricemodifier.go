@@ -0,0 +1,18 @@
+package rtb
+
+// BidPriceModifier defines a type that can adjust the price a campaign bids on an impression
+type BidPriceModifier interface {
+	// ModifyBidCpmInMicroCents returns the CPM to bid, given the CPM the campaign would otherwise bid
+	ModifyBidCpmInMicroCents(request *BidRequest, imp *Imp, campaign Campaign, bidCpmInMicroCents int64) int64
+}
+
+// BidPriceModifiers applies each modifier in order, passing the result of one to the next
+type BidPriceModifiers []BidPriceModifier
+
+func (m BidPriceModifiers) ModifyBidCpmInMicroCents(request *BidRequest, imp *Imp, campaign Campaign, bidCpmInMicroCents int64) int64 {
+	for _, modifier := range m {
+		bidCpmInMicroCents = modifier.ModifyBidCpmInMicroCents(request, imp, campaign, bidCpmInMicroCents)
+	}
+
+	return bidCpmInMicroCents
+}
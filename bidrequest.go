@@ -2,6 +2,7 @@ package rtb
 
 import (
 	"fmt"
+	"strconv"
 )
 
 type Publisher struct {
@@ -20,24 +21,28 @@ type App struct {
 }
 
 type Geo struct {
-	City    string  `json:"city,omitempty"`
-	Country string  `json:"country,omitempty"`
-	Lat     float64 `json:"lat,omitempty"`
-	Lon     float64 `json:"lon,omitempty"`
-	Metro   string  `json:"metro,omitempty"`
-	Region  string  `json:"region,omitempty"`
-	Zip     string  `json:"zip,omitempty"`
+	City      string  `json:"city,omitempty"`
+	Country   string  `json:"country,omitempty"`
+	Lat       float64 `json:"lat,omitempty"`
+	Lon       float64 `json:"lon,omitempty"`
+	Metro     string  `json:"metro,omitempty"`
+	Region    string  `json:"region,omitempty"`
+	Utcoffset float64 `json:"utcoffset,omitempty"`
+	Zip       string  `json:"zip,omitempty"`
 }
 
 type Device struct {
 	Carrier        string      `json:"carrier,omitempty"`
 	Connectiontype float64     `json:"connectiontype,omitempty"`
 	Devicetype     float64     `json:"devicetype,omitempty"`
+	Didmd5         string      `json:"didmd5,omitempty"`
+	Didsha1        string      `json:"didsha1,omitempty"`
 	Dnt            float64     `json:"dnt,omitempty"`
 	Dpidmd5        string      `json:"dpidmd5,omitempty"`
 	Dpidsha1       string      `json:"dpidsha1,omitempty"`
 	Ext            interface{} `json:"ext,omitempty"`
 	Geo            *Geo        `json:"geo,omitempty"`
+	Ifa            string      `json:"ifa,omitempty"`
 	Ip             string      `json:"ip,omitempty"`
 	Js             float64     `json:"js,omitempty"`
 	Language       string      `json:"language,omitempty"`
@@ -88,6 +93,7 @@ type Imp struct {
 	Displaymanagerver string  `json:"displaymanagerver,omitempty"`
 	ID                string  `json:"id,omitempty"`
 	Instl             float64 `json:"instl,omitempty"`
+	Pmp               *Pmp    `json:"pmp,omitempty"`
 	Tagid             string  `json:"tagid,omitempty"`
 }
 
@@ -119,32 +125,34 @@ type User struct {
 
 // BidRequest defines a bid request object and how to parse it from JSON
 type BidRequest struct {
-	App    *App        `json:"app,omitempty"`
-	At     float64     `json:"at,omitempty"`
-	Badv   []string    `json:"badv,omitempty"`
-	Bcat   []string    `json:"bcat,omitempty"`
-	Device *Device     `json:"device,omitempty"`
-	Ext    interface{} `json:"ext,omitempty"`
-	ID     string      `json:"id,omitempty"`
-	Imp    []Imp       `json:"imp,omitempty"`
-	Site   *Site       `json:"site,omitempty"`
-	User   *User       `json:"user,omitempty"`
+	Allimps float64     `json:"allimps,omitempty"`
+	App     *App        `json:"app,omitempty"`
+	At      float64     `json:"at,omitempty"`
+	Bapp    []string    `json:"bapp,omitempty"`
+	Badv    []string    `json:"badv,omitempty"`
+	Bcat    []string    `json:"bcat,omitempty"`
+	Device  *Device     `json:"device,omitempty"`
+	Ext     interface{} `json:"ext,omitempty"`
+	ID      string      `json:"id,omitempty"`
+	Imp     []Imp       `json:"imp,omitempty"`
+	Site    *Site       `json:"site,omitempty"`
+	User    *User       `json:"user,omitempty"`
 }
 
 // Targeting creates a list of targets from a bid request
 func (r *BidRequest) Targeting() []Target {
-	targets := make([]Target, 0, 3)
+	targets := []Target{AllRequestsTarget()}
 
 	if r.Device != nil {
-		if r.Device.Geo != nil && r.Device.Geo.Country != "" {
-			country := Target{Type: Country, Value: r.Device.Geo.Country}
-			targets = append(targets, country)
+		if r.Device.Geo != nil {
+			targets = append(targets, r.Device.Geo.Targeting()...)
 		}
 
-		if r.Device.Os != "" {
-			os := Target{Type: OS, Value: r.Device.Os}
-			targets = append(targets, os)
-		}
+		targets = append(targets, r.Device.Targeting()...)
+	}
+
+	if r.User != nil {
+		targets = append(targets, r.User.Targeting()...)
 	}
 
 	if r.App != nil && r.App.Name != "" {
@@ -155,6 +163,88 @@ func (r *BidRequest) Targeting() []Target {
 		targets = append(targets, placement)
 	}
 
+	var publisher *Publisher
+	var categories []string
+
+	if r.App != nil {
+		if r.App.Bundle != "" {
+			targets = append(targets, Target{Type: AppBundle, Value: r.App.Bundle})
+		}
+
+		if r.App.ID != "" {
+			targets = append(targets, Target{Type: AppID, Value: r.App.ID})
+		}
+
+		publisher = r.App.Publisher
+		categories = r.App.Cat
+	} else if r.Site != nil {
+		if r.Site.Domain != "" {
+			targets = append(targets, Target{Type: SiteDomain, Value: r.Site.Domain})
+		}
+
+		publisher = r.Site.Publisher
+		categories = r.Site.Cat
+	}
+
+	for _, category := range Categories.Expand(categories) {
+		targets = append(targets, Target{Type: ContentCategory, Value: category})
+	}
+
+	if publisher != nil && publisher.ID != "" {
+		targets = append(targets, Target{Type: PublisherID, Value: publisher.ID})
+	}
+
+	return targets
+}
+
+// InCountry qualifies a region, city or zip target value with its country, e.g. "USA:CA", so the same code in two countries
+// doesn't match both
+func InCountry(country string, value string) string {
+	return country + ":" + value
+}
+
+// Targeting creates a list of targets from a user's location
+func (g *Geo) Targeting() []Target {
+	targets := make([]Target, 0, 5)
+
+	if g.Country != "" {
+		country := Target{Type: Country, Value: g.Country}
+		targets = append(targets, country)
+	}
+
+	// Regions, cities and zips are only unique within a country, so they can't be targeted without one
+	if g.Region != "" && g.Country != "" {
+		targets = append(targets, Target{Type: Region, Value: InCountry(g.Country, g.Region)})
+	}
+
+	if g.Metro != "" {
+		targets = append(targets, Target{Type: Metro, Value: g.Metro})
+	}
+
+	if g.City != "" && g.Country != "" {
+		targets = append(targets, Target{Type: City, Value: InCountry(g.Country, g.City)})
+	}
+
+	if g.Zip != "" && g.Country != "" {
+		targets = append(targets, Target{Type: Zip, Value: InCountry(g.Country, g.Zip)})
+	}
+
+	if g.Utcoffset != 0 {
+		targets = append(targets, Target{Type: UTCOffset, Value: strconv.Itoa(int(g.Utcoffset))})
+	}
+
+	// 0,0 is what we get when the exchange doesn't send a location
+	if g.Lat != 0 || g.Lon != 0 {
+		point := LatLon{Lat: g.Lat, Lon: g.Lon}
+
+		hash := EncodeGeoHash(point, MaxGeoHashPrecision)
+		for precision := 1; precision <= MaxGeoHashPrecision; precision++ {
+			targets = append(targets, Target{Type: GeoHash, Value: hash[:precision]})
+		}
+
+		targets = append(targets, Target{Type: GeoPoint, Value: formatLatLon(point)})
+	}
+
 	return targets
 }
 
@@ -167,5 +257,15 @@ func (i *Imp) Targeting() []Target {
 		targets = append(targets, creative)
 	}
 
+	if i.Tagid != "" {
+		targets = append(targets, Target{Type: TagID, Value: i.Tagid})
+	}
+
+	if i.Pmp != nil {
+		for _, deal := range i.Pmp.Deals {
+			targets = append(targets, Target{Type: DealID, Value: deal.ID})
+		}
+	}
+
 	return targets
 }
@@ -8,6 +8,7 @@ type Bid struct {
 	Cid     string                 `json:"cid,omitempty"`
 	Crid    string                 `json:"crid,omitempty"`
 	Crtype  string                 `json:"crtype,omitempty"`
+	Dealid  string                 `json:"dealid,omitempty"`
 	Ext     map[string]interface{} `json:"ext,omitempty"`
 	ID      string                 `json:"id,omitempty"`
 	Impid   string                 `json:"impid,omitempty"`
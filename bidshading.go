@@ -0,0 +1,38 @@
+package rtb
+
+import (
+	"fmt"
+)
+
+// Auction types for BidRequest.At
+const (
+	FirstPriceAuction  = 1
+	SecondPriceAuction = 2
+)
+
+// WinRateEstimator defines a type that learns how likely a bid is to win from past auction outcomes
+type WinRateEstimator interface {
+	// RecordOutcome records whether a bid at a price won an impression
+	// Safe inside goroutine
+	RecordOutcome(key string, bidCpmInMicroCents int64, won bool)
+	// WinRate returns the estimated probability a bid at a price wins, and how many outcomes the estimate is based on
+	WinRate(key string, bidCpmInMicroCents int64) (winRate float64, observations int64)
+}
+
+// AuctionOutcomeKey returns the key win rates are estimated by for an impression: its placement and size.
+// Placements are identified by app bundle or site domain, since names aren't stable.
+func AuctionOutcomeKey(r *BidRequest, imp *Imp) string {
+	placement := ""
+	if r.App != nil && r.App.Bundle != "" {
+		placement = r.App.Bundle
+	} else if r.Site != nil && r.Site.Domain != "" {
+		placement = r.Site.Domain
+	}
+
+	size := ""
+	if imp.Banner != nil && imp.Banner.W != 0 && imp.Banner.H != 0 {
+		size = fmt.Sprintf("%dx%d", imp.Banner.W, imp.Banner.H)
+	}
+
+	return placement + "|" + size
+}
@@ -0,0 +1,697 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	stringsBucket     = []byte("strings")
+	hashesBucket      = []byte("hashes")
+	setsBucket        = []byte("sets")
+	sortedSetsBucket  = []byte("sortedsets")
+	expirationsBucket = []byte("expirations")
+)
+
+// Buckets holding a nested bucket per key
+var collectionBuckets = [][]byte{hashesBucket, setsBucket, sortedSetsBucket}
+
+// The value of every set member, so members are never stored with an empty value
+var setMemberValue = []byte{1}
+
+// The number of expired keys removed per transaction by SweepExpired, so writers aren't held up by a long sweep
+var sweepBatchSize = 1000
+
+// BoltDataAccess stores the same data as the redis data access in a bolt database file, so a single bidder can keep durable
+// budgets and campaigns on local disk. Each app domain has a bucket of its own, so domains sharing a database never see each
+// other's keys. In it, strings live in one bucket, and each hash, set and sorted set in a nested bucket of its own.
+// Keys expire lazily: an expired key reads as missing, and is removed the next time it's written, or by SweepExpired.
+type BoltDataAccess struct {
+	appDomain string
+
+	db  *bolt.DB
+	now func() time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// bucket returns one of the app domain's buckets
+func (da *BoltDataAccess) bucket(tx *bolt.Tx, name []byte) *bolt.Bucket {
+	return tx.Bucket([]byte(da.appDomain)).Bucket(name)
+}
+
+// update runs fn in a read-write transaction. Like the redis data access, failures panic.
+func (da *BoltDataAccess) update(fn func(tx *bolt.Tx) error) {
+	if err := da.db.Update(fn); err != nil {
+		panic(err)
+	}
+}
+
+func (da *BoltDataAccess) view(fn func(tx *bolt.Tx) error) {
+	if err := da.db.View(fn); err != nil {
+		panic(err)
+	}
+}
+
+// formatMember formats a member the way redis would store it
+func formatMember(member interface{}) []byte {
+	switch m := member.(type) {
+	case string:
+		return []byte(m)
+	case []byte:
+		return m
+	case int:
+		return []byte(strconv.Itoa(m))
+	case int64:
+		return []byte(strconv.FormatInt(m, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(m, 'f', -1, 64))
+	case bool:
+		if m {
+			return []byte("1")
+		}
+		return []byte("0")
+	default:
+		panic("Unsupported member type.")
+	}
+}
+
+func encodeInt64(value int64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(value))
+	return encoded
+}
+
+func decodeInt64(encoded []byte) int64 {
+	return int64(binary.BigEndian.Uint64(encoded))
+}
+
+func parseInt64(value []byte) int64 {
+	val, err := strconv.ParseInt(string(value), 10, 64)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return val
+}
+
+func (da *BoltDataAccess) expired(tx *bolt.Tx, key []byte) bool {
+	at := da.bucket(tx, expirationsBucket).Get(key)
+
+	return at != nil && decodeInt64(at) <= da.now().Unix()
+}
+
+// exists tells whether a key of any type exists and hasn't expired
+func (da *BoltDataAccess) exists(tx *bolt.Tx, key []byte) bool {
+	if da.expired(tx, key) {
+		return false
+	}
+
+	if da.bucket(tx, stringsBucket).Get(key) != nil {
+		return true
+	}
+
+	for _, name := range collectionBuckets {
+		if da.bucket(tx, name).Bucket(key) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteKey removes a key of any type, and its expiration
+func (da *BoltDataAccess) deleteKey(tx *bolt.Tx, key []byte) error {
+	if err := da.bucket(tx, stringsBucket).Delete(key); err != nil {
+		return err
+	}
+
+	for _, name := range collectionBuckets {
+		if err := da.bucket(tx, name).DeleteBucket(key); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+
+	return da.bucket(tx, expirationsBucket).Delete(key)
+}
+
+// purgeExpired removes a key if it expired, so it's written to as a new key
+func (da *BoltDataAccess) purgeExpired(tx *bolt.Tx, key []byte) error {
+	if da.expired(tx, key) {
+		return da.deleteKey(tx, key)
+	}
+
+	return nil
+}
+
+// readString returns a copy of a string's value, since values are only valid during their transaction
+func (da *BoltDataAccess) readString(tx *bolt.Tx, key []byte) (value []byte, ok bool) {
+	if da.expired(tx, key) {
+		return nil, false
+	}
+
+	if value = da.bucket(tx, stringsBucket).Get(key); value == nil {
+		return nil, false
+	}
+
+	return append([]byte{}, value...), true
+}
+
+// readCollection returns the bucket holding a key's hash, set or sorted set, or nil if there isn't one
+func (da *BoltDataAccess) readCollection(tx *bolt.Tx, name []byte, key []byte) *bolt.Bucket {
+	if da.expired(tx, key) {
+		return nil
+	}
+
+	return da.bucket(tx, name).Bucket(key)
+}
+
+func (da *BoltDataAccess) writeCollection(tx *bolt.Tx, name []byte, key []byte) (*bolt.Bucket, error) {
+	if err := da.purgeExpired(tx, key); err != nil {
+		return nil, err
+	}
+
+	return da.bucket(tx, name).CreateBucketIfNotExists(key)
+}
+
+// dropIfEmpty removes a collection once its last member is removed, as redis does
+func (da *BoltDataAccess) dropIfEmpty(tx *bolt.Tx, name []byte, key []byte) error {
+	b := da.bucket(tx, name).Bucket(key)
+
+	if b == nil {
+		return nil
+	}
+
+	if first, _ := b.Cursor().First(); first != nil {
+		return nil
+	}
+
+	return da.deleteKey(tx, key)
+}
+
+// members returns copies of a collection's member names
+func members(b *bolt.Bucket) []string {
+	result := make([]string, 0)
+
+	if b == nil {
+		return result
+	}
+
+	b.ForEach(func(k, v []byte) error {
+		result = append(result, string(k))
+		return nil
+	})
+
+	return result
+}
+
+func (da *BoltDataAccess) DeleteKeys(keys []string) {
+	da.update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			if err := da.deleteKey(tx, []byte(key)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (da *BoltDataAccess) HGetInt64(accountKey string, key string) int64 {
+	var val int64
+
+	da.view(func(tx *bolt.Tx) error {
+		if b := da.readCollection(tx, hashesBucket, []byte(accountKey)); b != nil {
+			// Fields that were never set read as zero
+			if value := b.Get([]byte(key)); value != nil {
+				val = parseInt64(value)
+			}
+		}
+
+		return nil
+	})
+
+	return val
+}
+
+func (da *BoltDataAccess) HSetInt64(accountKey string, key string, val int64) {
+	da.update(func(tx *bolt.Tx) error {
+		b, err := da.writeCollection(tx, hashesBucket, []byte(accountKey))
+
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), []byte(strconv.FormatInt(val, 10)))
+	})
+}
+
+func (da *BoltDataAccess) GetInt64(accountKey string) (success bool, result int64) {
+	if ok, value := da.GetString(accountKey); ok {
+		return true, parseInt64([]byte(value))
+	}
+
+	return false, 0
+}
+
+func (da *BoltDataAccess) SetInt64(accountKey string, val int64) {
+	da.SetString(accountKey, strconv.FormatInt(val, 10))
+}
+
+func (da *BoltDataAccess) GetString(key string) (success bool, result string) {
+	da.view(func(tx *bolt.Tx) error {
+		var value []byte
+
+		if value, success = da.readString(tx, []byte(key)); success {
+			result = string(value)
+		}
+
+		return nil
+	})
+
+	return success, result
+}
+
+// SetString replaces the key, clearing its expiration like SET does
+func (da *BoltDataAccess) SetString(key string, val string) {
+	da.update(func(tx *bolt.Tx) error {
+		k := []byte(key)
+
+		if err := da.deleteKey(tx, k); err != nil {
+			return err
+		}
+
+		return da.bucket(tx, stringsBucket).Put(k, []byte(val))
+	})
+}
+
+func (da *BoltDataAccess) GetSetMembers(setKey string) []string {
+	var result []string
+
+	da.view(func(tx *bolt.Tx) error {
+		result = members(da.readCollection(tx, setsBucket, []byte(setKey)))
+		return nil
+	})
+
+	return result
+}
+
+// BatchRead reads everything in one transaction, so the values are consistent with each other
+func (da *BoltDataAccess) BatchRead(hashKeys []string, setKeys []string, stringKeys []string) nodb.BatchReadResult {
+	result := nodb.BatchReadResult{
+		Hashes:       make([]map[string]string, len(hashKeys)),
+		SetMembers:   make([][]string, len(setKeys)),
+		Strings:      make([]string, len(stringKeys)),
+		StringsFound: make([]bool, len(stringKeys)),
+	}
+
+	da.view(func(tx *bolt.Tx) error {
+		for i, key := range hashKeys {
+			hash := make(map[string]string)
+
+			if b := da.readCollection(tx, hashesBucket, []byte(key)); b != nil {
+				b.ForEach(func(k, v []byte) error {
+					hash[string(k)] = string(v)
+					return nil
+				})
+			}
+
+			result.Hashes[i] = hash
+		}
+
+		for i, key := range setKeys {
+			result.SetMembers[i] = members(da.readCollection(tx, setsBucket, []byte(key)))
+		}
+
+		for i, key := range stringKeys {
+			if value, ok := da.readString(tx, []byte(key)); ok {
+				result.Strings[i] = string(value)
+				result.StringsFound[i] = true
+			}
+		}
+
+		return nil
+	})
+
+	return result
+}
+
+func (da *BoltDataAccess) AddMembersToSet(setKey string, members []interface{}) {
+	if len(members) == 0 {
+		return
+	}
+
+	da.update(func(tx *bolt.Tx) error {
+		b, err := da.writeCollection(tx, setsBucket, []byte(setKey))
+
+		if err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			if err := b.Put(formatMember(member), setMemberValue); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AddMembersToSetInBatches adds every member in one transaction, so the batch size doesn't matter
+func (da *BoltDataAccess) AddMembersToSetInBatches(setKey string, members []interface{}, batchSize int) {
+	da.AddMembersToSet(setKey, members)
+}
+
+func (da *BoltDataAccess) RemoveMembersFromSet(setKey string, members []interface{}) {
+	if len(members) == 0 {
+		return
+	}
+
+	da.update(func(tx *bolt.Tx) error {
+		key := []byte(setKey)
+		b := da.readCollection(tx, setsBucket, key)
+
+		if b == nil {
+			return nil
+		}
+
+		for _, member := range members {
+			if err := b.Delete(formatMember(member)); err != nil {
+				return err
+			}
+		}
+
+		return da.dropIfEmpty(tx, setsBucket, key)
+	})
+}
+
+func (da *BoltDataAccess) ReplaceSetMembers(setKey string, members []interface{}) {
+	da.update(func(tx *bolt.Tx) error {
+		key := []byte(setKey)
+
+		if err := da.deleteKey(tx, key); err != nil || len(members) == 0 {
+			return err
+		}
+
+		b, err := da.bucket(tx, setsBucket).CreateBucketIfNotExists(key)
+
+		if err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			if err := b.Put(formatMember(member), setMemberValue); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (da *BoltDataAccess) IsSetMember(setKey string, member string) bool {
+	isMember := false
+
+	da.view(func(tx *bolt.Tx) error {
+		if b := da.readCollection(tx, setsBucket, []byte(setKey)); b != nil {
+			isMember = b.Get([]byte(member)) != nil
+		}
+
+		return nil
+	})
+
+	return isMember
+}
+
+func (da *BoltDataAccess) SetCardinality(setKey string) int64 {
+	var cardinality int64
+
+	da.view(func(tx *bolt.Tx) error {
+		if b := da.readCollection(tx, setsBucket, []byte(setKey)); b != nil {
+			b.ForEach(func(k, v []byte) error {
+				cardinality++
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return cardinality
+}
+
+func (da *BoltDataAccess) AddMembersToSortedSets(keyValues map[string]nodb.SortedSetMember) {
+	da.update(func(tx *bolt.Tx) error {
+		for key, value := range keyValues {
+			b, err := da.writeCollection(tx, sortedSetsBucket, []byte(key))
+
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put(formatMember(value.Member), encodeInt64(value.Score)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (da *BoltDataAccess) RemoveMemberFromSortedSets(keys []string, member interface{}) {
+	da.update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			k := []byte(key)
+
+			if b := da.readCollection(tx, sortedSetsBucket, k); b != nil {
+				if err := b.Delete(formatMember(member)); err != nil {
+					return err
+				}
+
+				if err := da.dropIfEmpty(tx, sortedSetsBucket, k); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// SortedSetUnion returns the members of all of the sorted sets from highest score to lowest, like the redis data access
+func (da *BoltDataAccess) SortedSetUnion(keys []string) []string {
+	scores := make(map[string]int64)
+	result := make([]string, 0)
+
+	da.view(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			if b := da.readCollection(tx, sortedSetsBucket, []byte(key)); b != nil {
+				b.ForEach(func(k, v []byte) error {
+					member := string(k)
+					score := decodeInt64(v)
+
+					if existing, ok := scores[member]; !ok {
+						scores[member] = score
+						result = append(result, member)
+					} else if score > existing {
+						scores[member] = score
+					}
+
+					return nil
+				})
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		if scores[result[i]] != scores[result[j]] {
+			return scores[result[i]] > scores[result[j]]
+		}
+
+		return result[i] > result[j]
+	})
+
+	return result
+}
+
+// DebitIfNotZero starts a missing account at dailyBudget and debits it if it has enough left, in a single transaction
+func (da *BoltDataAccess) DebitIfNotZero(accountKey string, amount int64, dailyBudget int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCents int64, err error) {
+	debited := false
+
+	updateErr := da.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(accountKey)
+		value, ok := da.readString(tx, key)
+
+		if !ok {
+			if err := da.deleteKey(tx, key); err != nil {
+				return err
+			}
+
+			value = []byte(strconv.FormatInt(dailyBudget, 10))
+
+			if err := da.bucket(tx, expirationsBucket).Put(key, encodeInt64(dailyBudgetExpiration.Unix())); err != nil {
+				return err
+			}
+		}
+
+		remainingDailyBudgetInMicroCents = parseInt64(value)
+
+		if remainingDailyBudgetInMicroCents >= amount {
+			remainingDailyBudgetInMicroCents -= amount
+			debited = true
+		}
+
+		return da.bucket(tx, stringsBucket).Put(key, []byte(strconv.FormatInt(remainingDailyBudgetInMicroCents, 10)))
+	})
+
+	if updateErr != nil {
+		return 0, rtb.NewTransactionError(updateErr.Error(), false)
+	}
+
+	if !debited {
+		return remainingDailyBudgetInMicroCents, rtb.NewTransactionError("Insufficient daily funds.", true)
+	}
+
+	return remainingDailyBudgetInMicroCents, nil
+}
+
+func (da *BoltDataAccess) ExpireKey(key string, expirationTime time.Time) {
+	da.update(func(tx *bolt.Tx) error {
+		k := []byte(key)
+
+		if !da.exists(tx, k) {
+			return nil
+		}
+
+		return da.bucket(tx, expirationsBucket).Put(k, encodeInt64(expirationTime.Unix()))
+	})
+}
+
+// Publish does nothing, since the file belongs to a single process. Use an in process notifier to announce changes.
+func (da *BoltDataAccess) Publish(channel string, message string) {
+}
+
+// SweepExpired removes the app domain's expired keys. Keys that are never written again, like the frequency count of a
+// user who doesn't come back, otherwise stay in the file forever. Returns the number of keys removed.
+func (da *BoltDataAccess) SweepExpired() int {
+	swept := 0
+
+	// Each batch starts where the last one stopped
+	for start := []byte{}; start != nil; {
+		da.update(func(tx *bolt.Tx) error {
+			now := da.now().Unix()
+			expired := make([][]byte, 0)
+			cursor := da.bucket(tx, expirationsBucket).Cursor()
+
+			k, v := cursor.Seek(start)
+			start = nil
+
+			for ; k != nil; k, v = cursor.Next() {
+				if len(expired) == sweepBatchSize {
+					start = append([]byte{}, k...)
+					break
+				}
+
+				if decodeInt64(v) <= now {
+					expired = append(expired, append([]byte{}, k...))
+				}
+			}
+
+			for _, key := range expired {
+				if err := da.deleteKey(tx, key); err != nil {
+					return err
+				}
+			}
+
+			swept += len(expired)
+
+			return nil
+		})
+	}
+
+	return swept
+}
+
+// SweepExpiredEvery runs SweepExpired every interval, until the data access is closed
+func (da *BoltDataAccess) SweepExpiredEvery(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-da.closed:
+				return
+			case <-ticker.C:
+				da.SweepExpired()
+			}
+		}
+	}()
+}
+
+// Close stops sweeping expired keys. It must be called before db is closed, which is left to the caller.
+func (da *BoltDataAccess) Close() {
+	da.closeOnce.Do(func() { close(da.closed) })
+}
+
+// Should only be used for testing
+func (da *BoltDataAccess) GetKeys() []string {
+	keys := make([]string, 0)
+
+	da.view(func(tx *bolt.Tx) error {
+		for _, name := range append([][]byte{stringsBucket}, collectionBuckets...) {
+			da.bucket(tx, name).ForEach(func(k, v []byte) error {
+				if !da.expired(tx, k) {
+					keys = append(keys, string(k))
+				}
+
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return keys
+}
+
+// NewBoltDataAccess stores an app domain's data in db. Several app domains can share a database.
+// The caller opens and closes db; bolt only allows one process to have a database file open at a time.
+// It returns the BoltDataAccess rather than a NoDbDataAccess, so callers can sweep expired keys.
+func NewBoltDataAccess(db *bolt.DB, appDomain string) *BoltDataAccess {
+	da := new(BoltDataAccess)
+
+	da.db = db
+	da.appDomain = appDomain
+	da.now = time.Now
+	da.closed = make(chan struct{})
+
+	da.update(func(tx *bolt.Tx) error {
+		domain, err := tx.CreateBucketIfNotExists([]byte(appDomain))
+
+		if err != nil {
+			return err
+		}
+
+		for _, name := range append([][]byte{stringsBucket, expirationsBucket}, collectionBuckets...) {
+			if _, err := domain.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return da
+}
@@ -0,0 +1,179 @@
+package bolt
+
+import (
+	"github.com/evandigby/rtb/nodb"
+	"github.com/evandigby/rtb/nodb/conformance"
+	"github.com/evandigby/rtb/redis"
+	bolt "go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openTestDb(t *testing.T, path string) *bolt.DB {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// Test the bolt data access against the conformance suite
+// Expected result is every conformance test passes
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rtbbolt")
+	if err != nil {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDb(t, filepath.Join(dir, "rtb.db"))
+	defer db.Close()
+
+	domains := 0
+
+	conformance.Run(t, func() nodb.NoDbDataAccess {
+		domains++
+		return NewBoltDataAccess(db, "conformance"+strconv.Itoa(domains))
+	})
+}
+
+// Test that data outlives the process that wrote it
+// Expected result is budgets and campaigns written before the database was closed are read back after it's reopened
+func TestDurability(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rtbbolt")
+	if err != nil {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rtb.db")
+	expiration := time.Now().Add(time.Hour)
+
+	db := openTestDb(t, path)
+	da := NewBoltDataAccess(db, "durability")
+	cp := redis.NewRedisCampaignProvider(da, redis.NewRedisBanker(da))
+
+	cp.CreateCampaign(1, 100, 1000, nil)
+	cp.DebitCampaign(1, 300, expiration)
+	db.Close()
+
+	db = openTestDb(t, path)
+	defer db.Close()
+
+	da = NewBoltDataAccess(db, "durability")
+	cp = redis.NewRedisCampaignProvider(da, redis.NewRedisBanker(da))
+
+	if campaign := cp.ReadCampaign(1); campaign.BidCpmInMicroCents() != 100 || campaign.DailyBudgetInMicroCents() != 1000 {
+		t.Fail()
+	}
+
+	if remaining, err := cp.DebitCampaign(1, 300, expiration); err != nil || remaining != 400 {
+		t.Fail()
+	}
+}
+
+// Test sweeping expired keys that are never written again, in batches
+// Expected result is the app domain's expired keys are removed from the file, and keys that haven't expired or belong to
+// another app domain are kept
+func TestSweepExpired(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rtbbolt")
+	if err != nil {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDb(t, filepath.Join(dir, "rtb.db"))
+	defer db.Close()
+
+	defer func(size int) { sweepBatchSize = size }(sweepBatchSize)
+	sweepBatchSize = 2
+
+	now := time.Now()
+
+	da := NewBoltDataAccess(db, "sweep")
+	da.now = func() time.Time { return now }
+
+	other := NewBoltDataAccess(db, "other")
+	other.now = da.now
+
+	for i := 0; i < 5; i++ {
+		da.DebitIfNotZero("frequency:1:user"+strconv.Itoa(i), 1, 3, now.Add(time.Minute))
+	}
+
+	da.SetString("kept", "value")
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{"later": nodb.SortedSetMember{Score: 1, Member: "member"}})
+	da.ExpireKey("later", now.Add(time.Hour))
+	other.DebitIfNotZero("frequency:1:user0", 1, 3, now.Add(time.Minute))
+
+	now = now.Add(2 * time.Minute)
+
+	if swept := da.SweepExpired(); swept != 5 {
+		t.Fail()
+	}
+
+	if len(da.GetKeys()) != 2 {
+		t.Fail()
+	}
+
+	// Gone from the file, not just hidden
+	db.View(func(tx *bolt.Tx) error {
+		if da.bucket(tx, stringsBucket).Get([]byte("frequency:1:user0")) != nil || da.bucket(tx, expirationsBucket).Get([]byte("frequency:1:user4")) != nil {
+			t.Fail()
+		}
+
+		if other.bucket(tx, stringsBucket).Get([]byte("frequency:1:user0")) == nil {
+			t.Fail()
+		}
+
+		return nil
+	})
+
+	if other.SweepExpired() != 1 || da.SweepExpired() != 0 {
+		t.Fail()
+	}
+}
+
+// Test two app domains sharing a database, where one domain's name starts with the other's
+// Expected result is neither domain lists, reads or sweeps the other's keys
+func TestDomainsSharingDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rtbbolt")
+	if err != nil {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	db := openTestDb(t, filepath.Join(dir, "rtb.db"))
+	defer db.Close()
+
+	now := time.Now()
+
+	da := NewBoltDataAccess(db, "a")
+	da.now = func() time.Time { return now }
+
+	nested := NewBoltDataAccess(db, "a:b")
+	nested.now = da.now
+
+	nested.DebitIfNotZero("frequency:1:user", 1, 3, now.Add(time.Minute))
+	nested.SetString("kept", "value")
+	da.SetString("b:kept", "shadow")
+
+	now = now.Add(2 * time.Minute)
+
+	if da.SweepExpired() != 0 || len(da.GetKeys()) != 1 {
+		t.Fail()
+	}
+
+	if ok, value := nested.GetString("kept"); !ok || value != "value" {
+		t.Fail()
+	}
+
+	if nested.SweepExpired() != 1 || len(nested.GetKeys()) != 1 {
+		t.Fail()
+	}
+}
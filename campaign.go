@@ -14,14 +14,149 @@ const (
 	Country = 3
 	// OS defines the OS of the device the request came form
 	OS = 4
+	// Region defines a target based on the region (state or province) of the user the request came from, within its country.
+	// See InCountry.
+	Region = 5
+	// Metro defines a target based on the metro (DMA) code of the user the request came from
+	Metro = 6
+	// City defines a target based on the city of the user the request came from, within its country. See InCountry.
+	City = 7
+	// Zip defines a target based on the zip or postal code of the user the request came from, within its country. See InCountry.
+	Zip = 8
+	// GeoHash defines a target based on the geohash cell of the user's location.
+	// Requests are targeted by every geohash prefix of their location up to MaxGeoHashPrecision.
+	GeoHash = 9
+	// GeoRadius constrains a campaign to users within a distance of a point. See GeoRadiusTargets.
+	GeoRadius = 10
+	// GeoPolygon constrains a campaign to users within a polygon. See GeoPolygonTargets.
+	GeoPolygon = 11
+	// GeoPoint is the latitude and longitude of the user the request came from, as "lat,lon". It is only used to check constraints.
+	GeoPoint = 12
+	// OSVersionRange constrains a campaign to OS versions, as comparisons separated by commas, e.g. ">=14.2,<17".
+	// Campaigns should also target the OS the versions belong to.
+	OSVersionRange = 13
+	// OSVersion is the OS version of the device the request came from. It is only used to check constraints.
+	OSVersion = 14
+	// DeviceMake defines a target based on the make of the device the request came from
+	DeviceMake = 15
+	// DeviceModel defines a target based on the model of the device the request came from
+	DeviceModel = 16
+	// DeviceType defines a target based on the type of device the request came from. See DeviceTypeNames.
+	DeviceType = 17
+	// Carrier defines a target based on the carrier of the device the request came from
+	Carrier = 18
+	// ConnectionType defines a target based on how the device the request came from is connected. See ConnectionTypeNames.
+	ConnectionType = 19
+	// Language defines a target based on the language of the device the request came from
+	Language = 20
+	// Daypart constrains a campaign to the hours of the week in a schedule. See ScheduleTarget.
+	Daypart = 21
+	// RequestTime is when the request was received, in Unix seconds. It is only used to check constraints.
+	RequestTime = 22
+	// UTCOffset is the user's local time offset from UTC in minutes. It is only used to check constraints.
+	UTCOffset = 23
+	// UserSegment defines a target based on the third party audience segments the user is in, as "data provider id:segment id"
+	UserSegment = 24
+	// Audience constrains a campaign to devices in first party audience lists. See AudienceTarget.
+	// Campaigns should also have an indexed target.
+	Audience = 25
+	// DeviceID is an id of the device the request came from, lower cased. It is only used to check constraints.
+	DeviceID = 26
+	// AppBundle is the bundle of the app the request came from. It is only used to check placement lists.
+	AppBundle = 27
+	// AppID is the exchange's id of the app the request came from. It is only used to check placement lists.
+	AppID = 28
+	// SiteDomain is the domain of the site the request came from. It is only used to check placement lists.
+	SiteDomain = 29
+	// PublisherID is the exchange's id of the publisher of the app or site. It is only used to check placement lists.
+	PublisherID = 30
+	// TagID is the tag id of the impression. It is only used to check placement lists.
+	TagID = 31
+	// PlacementAllowList constrains a campaign to placements in any of a set of lists. See PlacementAllowListTarget.
+	PlacementAllowList = 32
+	// PlacementBlockList keeps a campaign off placements in any of a set of lists. See PlacementBlockListTarget.
+	PlacementBlockList = 33
+	// AllRequests is a target every request has. Campaigns with no other indexed targets can use it to be matched
+	// against every request, and rely on their constraints. See AllRequestsTarget.
+	AllRequests = 34
+	// ContentCategory defines a target based on the IAB content categories of the app or site. Requests are targeted by
+	// their categories' ancestors and equivalents too. See ContentCategoryTargets.
+	ContentCategory = 35
+	// CategoryExclude keeps a campaign off content in any of a set of IAB categories. See CategoryExcludeTarget.
+	CategoryExclude = 36
+	// DealID defines a target based on the private marketplace deals of the impression
+	DealID = 37
 )
 
+// AllRequestsTarget returns the target every request has
+func AllRequestsTarget() Target {
+	return Target{Type: AllRequests, Value: "*"}
+}
+
 // Target defines the type and value of a specific targeting
 type Target struct {
 	Type  TargetType
 	Value string
 }
 
+// GoalType defines what a campaign is paying for
+type GoalType int
+
+const (
+	// CPMGoal campaigns pay a fixed price per thousand impressions
+	CPMGoal GoalType = 0
+	// CPCGoal campaigns pay for clicks
+	CPCGoal GoalType = 1
+	// CPAGoal campaigns pay for actions (conversions)
+	CPAGoal GoalType = 2
+)
+
+func (t GoalType) String() string {
+	switch t {
+	case CPMGoal:
+		return "cpm"
+	case CPCGoal:
+		return "cpc"
+	case CPAGoal:
+		return "cpa"
+	}
+
+	return "unknown"
+}
+
+// Goal defines what a campaign is paying for, and how much it is willing to pay
+type Goal struct {
+	Type GoalType
+	// AmountInMicroCents is the cost per click or cost per action. CPM goals bid BidCpmInMicroCents instead.
+	AmountInMicroCents int64
+}
+
+// CampaignStatus defines whether a campaign is bidding
+type CampaignStatus int
+
+const (
+	// CampaignActive campaigns bid on the requests they target
+	CampaignActive CampaignStatus = 0
+	// CampaignPaused campaigns keep their settings, but are not matched by targeting until they are resumed
+	CampaignPaused CampaignStatus = 1
+	// CampaignArchived campaigns have been deleted. They can still be read, so logged bids can be traced back to them,
+	// but they are not listed and can't be changed.
+	CampaignArchived CampaignStatus = 2
+)
+
+func (s CampaignStatus) String() string {
+	switch s {
+	case CampaignActive:
+		return "active"
+	case CampaignPaused:
+		return "paused"
+	case CampaignArchived:
+		return "archived"
+	}
+
+	return "unknown"
+}
+
 // Campaign defines the structure of a campaign and what requests it is targeting
 type Campaign interface {
 	// Id defines the unique identifier of this campaign
@@ -32,4 +167,14 @@ type Campaign interface {
 	DailyBudgetInMicroCents() int64
 	// Targets define the type of request this campaign is targeting
 	Targets() *map[TargetType]string
+	// TargetList defines every target of this campaign. Unlike Targets, it keeps every value of a type.
+	TargetList() []Target
+	// Goal defines what this campaign is paying for
+	Goal() Goal
+	// Creatives define the ads this campaign can serve, in order of preference
+	Creatives() []Creative
+	// Priority defines which tier this campaign bids in
+	Priority() PriorityTier
+	// Status defines whether this campaign is bidding
+	Status() CampaignStatus
 }
@@ -18,9 +18,67 @@ type CampaignProvider interface {
 	// Creates a new persisted campaign
 	CreateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []Target) Campaign
 
+	// Creates a new persisted campaign that is paused, so it isn't matched by targeting until it is resumed
+	CreatePausedCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []Target) Campaign
+
+	// Sets what a persisted campaign is paying for
+	SetCampaignGoal(campaignId int64, goal Goal)
+
+	// Sets the ads a persisted campaign can serve
+	SetCampaignCreatives(campaignId int64, creatives []Creative)
+
+	// Sets the tier a persisted campaign bids in
+	SetCampaignPriority(campaignId int64, priority PriorityTier)
+
+	// Replaces the bid, daily budget and targets of a persisted campaign, keeping the target index in sync.
+	// Returns nil if the campaign doesn't exist or is archived.
+	UpdateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []Target) Campaign
+
+	// Stops a persisted campaign from being matched by targeting, until it is resumed
+	PauseCampaign(campaignId int64)
+
+	// Lets a paused campaign be matched by targeting again
+	ResumeCampaign(campaignId int64)
+
+	// Archives a persisted campaign. It is no longer matched by targeting or listed, but can still be read.
+	DeleteCampaign(campaignId int64)
+
 	// Reads a persisted campaign
 	ReadCampaign(campaignId int64) Campaign
 
 	// Lists campaigns
 	ListCampaigns() []int64
+
+	// Returns whether a campaign is listed: it exists, and isn't archived
+	IsCampaignListed(campaignId int64) bool
+
+	// Returns the named lists, such as audiences, that campaign constraints are checked against
+	Lists() ListProvider
+}
+
+// CachingCampaignProvider keeps a local copy of another campaign provider's campaigns, so targeting is answered in process
+type CachingCampaignProvider interface {
+	CampaignProvider
+
+	// Refresh reloads every campaign
+	Refresh()
+
+	// Invalidate reloads one campaign
+	Invalidate(campaignId int64)
+
+	// Close stops refreshing the cache
+	Close()
+}
+
+// CampaignChangeNotifier tells campaign caches, in this process or others, when campaigns change
+type CampaignChangeNotifier interface {
+	// NotifyChanged announces that a campaign changed
+	NotifyChanged(campaignId int64)
+
+	// Subscribe calls changed with the id of each campaign that changes, until the notifier is closed.
+	// An id of zero means any campaign may have changed, e.g. because notifications were missed.
+	Subscribe(changed func(campaignId int64))
+
+	// Close stops notifying subscribers
+	Close()
 }
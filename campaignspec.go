@@ -0,0 +1,594 @@
+package rtb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CampaignSpecFile defines a file of campaign specs, so campaign setup can be reviewed and reproduced
+type CampaignSpecFile struct {
+	Campaigns []CampaignSpec `json:"campaigns" yaml:"campaigns"`
+}
+
+// CampaignSpec declares a campaign. Money is in dollars.
+type CampaignSpec struct {
+	ID int64 `json:"id" yaml:"id"`
+	// Name is only for people reading the spec
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Status is active (the default) or paused
+	Status      string  `json:"status,omitempty" yaml:"status,omitempty"`
+	BidCpm      float64 `json:"bidCpm" yaml:"bidCpm"`
+	DailyBudget float64 `json:"dailyBudget" yaml:"dailyBudget"`
+	// Goal defaults to paying the bid CPM
+	Goal *GoalSpec `json:"goal,omitempty" yaml:"goal,omitempty"`
+	// Priority is open (the default), pmp or guaranteed
+	Priority  string          `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Targets   []TargetSpec    `json:"targets,omitempty" yaml:"targets,omitempty"`
+	GeoRadius []GeoRadiusSpec `json:"geoRadius,omitempty" yaml:"geoRadius,omitempty"`
+	Schedule  *ScheduleSpec   `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Creatives []Creative      `json:"creatives,omitempty" yaml:"creatives,omitempty"`
+}
+
+// GoalSpec declares what a campaign is paying for: cpm, cpc or cpa, and the cost per click or action
+type GoalSpec struct {
+	Type   string  `json:"type" yaml:"type"`
+	Amount float64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+}
+
+// TargetSpec declares a target by the name of its type. See TargetTypeNames.
+// Region, city and zip values start with their country, e.g. "USA:CA". See InCountry.
+type TargetSpec struct {
+	Type  string `json:"type" yaml:"type"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// GeoRadiusSpec declares a radius around a point. See GeoRadiusTargets.
+type GeoRadiusSpec struct {
+	Lat float64 `json:"lat" yaml:"lat"`
+	Lon float64 `json:"lon" yaml:"lon"`
+	Km  float64 `json:"km" yaml:"km"`
+}
+
+// ScheduleSpec declares a campaign's schedule. Timezone is a name time.LoadLocation can load, or "user" (the default).
+type ScheduleSpec struct {
+	Timezone string              `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	Hours    []ScheduleHoursSpec `json:"hours" yaml:"hours"`
+}
+
+// ScheduleHoursSpec makes a campaign active from the start of hour From until the start of hour To, on each of the days.
+// Days are named by their first three letters, e.g. "mon".
+type ScheduleHoursSpec struct {
+	Days []string `json:"days" yaml:"days"`
+	From int      `json:"from" yaml:"from"`
+	To   int      `json:"to" yaml:"to"`
+}
+
+// TargetTypeNames names the target types campaigns can have in specs
+var TargetTypeNames = map[TargetType]string{
+	Placement:          "placement",
+	CreativeSize:       "creativeSize",
+	Country:            "country",
+	OS:                 "os",
+	Region:             "region",
+	Metro:              "metro",
+	City:               "city",
+	Zip:                "zip",
+	GeoHash:            "geoHash",
+	GeoRadius:          "geoRadius",
+	GeoPolygon:         "geoPolygon",
+	OSVersionRange:     "osVersionRange",
+	DeviceMake:         "deviceMake",
+	DeviceModel:        "deviceModel",
+	DeviceType:         "deviceType",
+	Carrier:            "carrier",
+	ConnectionType:     "connectionType",
+	Language:           "language",
+	Daypart:            "daypart",
+	UserSegment:        "userSegment",
+	Audience:           "audience",
+	PlacementAllowList: "placementAllowList",
+	PlacementBlockList: "placementBlockList",
+	AllRequests:        "allRequests",
+	ContentCategory:    "contentCategory",
+	CategoryExclude:    "categoryExclude",
+	DealID:             "dealId",
+}
+
+// Target types whose values are qualified by country
+var inCountryTargetTypes = map[TargetType]bool{Region: true, City: true, Zip: true}
+
+// splitInCountry splits a value made by InCountry
+func splitInCountry(value string) (country string, rest string) {
+	if i := strings.Index(value, ":"); i >= 0 {
+		return value[:i], value[i+1:]
+	}
+
+	return "", value
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func goalTypeByName(name string) (GoalType, bool) {
+	for _, goalType := range []GoalType{CPMGoal, CPCGoal, CPAGoal} {
+		if strings.EqualFold(goalType.String(), name) {
+			return goalType, true
+		}
+	}
+
+	return 0, false
+}
+
+func priorityByName(name string) (PriorityTier, bool) {
+	for _, priority := range []PriorityTier{PriorityOpen, PriorityPrivateMarketplace, PriorityGuaranteed} {
+		if strings.EqualFold(priority.String(), name) {
+			return priority, true
+		}
+	}
+
+	return 0, false
+}
+
+func targetTypeByName(name string) (TargetType, bool) {
+	for targetType, typeName := range TargetTypeNames {
+		if strings.EqualFold(typeName, name) {
+			return targetType, true
+		}
+	}
+
+	return 0, false
+}
+
+// campaignSettings is a spec converted to what the campaign provider stores
+type campaignSettings struct {
+	bidCpmInMicroCents      int64
+	dailyBudgetInMicroCents int64
+	targets                 []Target
+	goal                    Goal
+	priority                PriorityTier
+	status                  CampaignStatus
+	creatives               []Creative
+}
+
+func (s *CampaignSpec) settings() (*campaignSettings, error) {
+	c := new(campaignSettings)
+
+	if s.ID <= 0 {
+		return nil, errors.New("campaign id must be positive")
+	}
+
+	if s.BidCpm < 0 || s.DailyBudget <= 0 {
+		return nil, fmt.Errorf("campaign %d: bid CPM can't be negative, and daily budget must be positive", s.ID)
+	}
+
+	c.bidCpmInMicroCents = CpmToMicroCents(s.BidCpm)
+	c.dailyBudgetInMicroCents = DollarsToMicroCents(s.DailyBudget)
+
+	switch strings.ToLower(s.Status) {
+	case "", "active":
+		c.status = CampaignActive
+	case "paused":
+		c.status = CampaignPaused
+	default:
+		return nil, fmt.Errorf("campaign %d: status must be active or paused, not %q", s.ID, s.Status)
+	}
+
+	if s.Goal != nil {
+		goalType, ok := goalTypeByName(s.Goal.Type)
+		if !ok {
+			return nil, fmt.Errorf("campaign %d: unknown goal %q", s.ID, s.Goal.Type)
+		}
+
+		if goalType != CPMGoal && s.Goal.Amount <= 0 {
+			return nil, fmt.Errorf("campaign %d: %v goals need an amount", s.ID, s.Goal.Type)
+		}
+
+		c.goal = Goal{Type: goalType, AmountInMicroCents: DollarsToMicroCents(s.Goal.Amount)}
+	}
+
+	if s.Priority != "" {
+		priority, ok := priorityByName(s.Priority)
+		if !ok {
+			return nil, fmt.Errorf("campaign %d: unknown priority %q", s.ID, s.Priority)
+		}
+
+		c.priority = priority
+	}
+
+	for _, target := range s.Targets {
+		targetType, ok := targetTypeByName(target.Type)
+		if !ok {
+			return nil, fmt.Errorf("campaign %d: unknown target type %q", s.ID, target.Type)
+		}
+
+		if target.Value == "" {
+			return nil, fmt.Errorf("campaign %d: %v target has no value", s.ID, target.Type)
+		}
+
+		if inCountryTargetTypes[targetType] {
+			if country, value := splitInCountry(target.Value); country == "" || value == "" {
+				return nil, fmt.Errorf("campaign %d: %v target must start with its country, e.g. \"USA:%s\", not %q", s.ID, target.Type, target.Value, target.Value)
+			}
+		}
+
+		c.targets = append(c.targets, Target{Type: targetType, Value: target.Value})
+	}
+
+	for _, radius := range s.GeoRadius {
+		if radius.Km <= 0 {
+			return nil, fmt.Errorf("campaign %d: geo radius must be positive", s.ID)
+		}
+
+		c.targets = append(c.targets, GeoRadiusTargets(GeoCircle{Center: LatLon{Lat: radius.Lat, Lon: radius.Lon}, RadiusInKm: radius.Km})...)
+	}
+
+	if s.Schedule != nil {
+		schedule, err := s.Schedule.schedule()
+		if err != nil {
+			return nil, fmt.Errorf("campaign %d: %v", s.ID, err)
+		}
+
+		c.targets = append(c.targets, ScheduleTarget(schedule))
+	}
+
+	if len(IndexedTargets(c.targets)) == 0 {
+		return nil, fmt.Errorf("campaign %d: needs at least one indexed target (use allRequests to match every request)", s.ID)
+	}
+
+	for _, creative := range s.Creatives {
+		if creative.ID == "" {
+			return nil, fmt.Errorf("campaign %d: creatives need an id", s.ID)
+		}
+	}
+
+	c.creatives = s.Creatives
+
+	return c, nil
+}
+
+func (s *ScheduleSpec) schedule() (*Schedule, error) {
+	var location *time.Location
+
+	if s.Timezone != "" && s.Timezone != UserTimezone {
+		var err error
+		if location, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	schedule := NewSchedule(location)
+
+	for _, hours := range s.Hours {
+		if hours.From < 0 || hours.To > HoursPerDay || hours.From >= hours.To {
+			return nil, fmt.Errorf("schedule hours must be within the day, from before to, not %d to %d", hours.From, hours.To)
+		}
+
+		days := make([]time.Weekday, 0, len(hours.Days))
+		for _, name := range hours.Days {
+			day, ok := weekdayNames[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown day %q", name)
+			}
+
+			days = append(days, day)
+		}
+
+		schedule.SetHours(days, hours.From, hours.To)
+	}
+
+	return schedule, nil
+}
+
+// Validate returns an error describing the first problem with the spec
+func (s *CampaignSpec) Validate() error {
+	_, err := s.settings()
+	return err
+}
+
+// ValidateCampaignSpecs validates each spec, and checks no two specs declare the same campaign
+func ValidateCampaignSpecs(specs []CampaignSpec) error {
+	ids := make(map[int64]bool, len(specs))
+
+	for i := range specs {
+		if err := specs[i].Validate(); err != nil {
+			return err
+		}
+
+		if ids[specs[i].ID] {
+			return fmt.Errorf("campaign %d is declared more than once", specs[i].ID)
+		}
+
+		ids[specs[i].ID] = true
+	}
+
+	return nil
+}
+
+// ParseCampaignSpecs reads a JSON campaign spec file
+func ParseCampaignSpecs(r io.Reader) ([]CampaignSpec, error) {
+	var file CampaignSpecFile
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	return file.Campaigns, nil
+}
+
+// CampaignChangeType defines how a campaign needs to change to match its spec
+type CampaignChangeType int
+
+const (
+	// CampaignCreate campaigns are in the specs, but not the campaign provider
+	CampaignCreate CampaignChangeType = 0
+	// CampaignUpdate campaigns differ from their spec
+	CampaignUpdate CampaignChangeType = 1
+	// CampaignPrune campaigns are in the campaign provider, but not the specs. They are paused, not deleted.
+	CampaignPrune CampaignChangeType = 2
+)
+
+func (t CampaignChangeType) String() string {
+	switch t {
+	case CampaignCreate:
+		return "create"
+	case CampaignUpdate:
+		return "update"
+	case CampaignPrune:
+		return "pause"
+	}
+
+	return "unknown"
+}
+
+// CampaignChange defines a change needed to make a campaign provider match the specs
+type CampaignChange struct {
+	Type       CampaignChangeType
+	CampaignId int64
+	// Fields that differ from the spec, for updates
+	Fields []string
+
+	settings *campaignSettings
+}
+
+func (c CampaignChange) String() string {
+	if len(c.Fields) == 0 {
+		return fmt.Sprintf("%v campaign %d", c.Type, c.CampaignId)
+	}
+
+	return fmt.Sprintf("%v campaign %d: %v", c.Type, c.CampaignId, strings.Join(c.Fields, ", "))
+}
+
+func sortedTargetKeys(targets []Target) []string {
+	keys := make([]string, 0, len(targets))
+	for _, target := range targets {
+		keys = append(keys, TargetKey("", target))
+	}
+
+	sort.Strings(keys)
+
+	// Duplicate targets are stored once
+	unique := keys[:0]
+	for i, key := range keys {
+		if i == 0 || key != keys[i-1] {
+			unique = append(unique, key)
+		}
+	}
+
+	return unique
+}
+
+func sameTargets(a []Target, b []Target) bool {
+	aKeys, bKeys := sortedTargetKeys(a), sortedTargetKeys(b)
+
+	if len(aKeys) != len(bKeys) {
+		return false
+	}
+
+	for i := range aKeys {
+		if aKeys[i] != bKeys[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameInts(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameCreative(a *Creative, b *Creative) bool {
+	return a.ID == b.ID && a.Adm == b.Adm && sameStrings(a.Adomain, b.Adomain) && sameStrings(a.Cat, b.Cat) &&
+		sameInts(a.Attr, b.Attr) && a.Bundle == b.Bundle && a.W == b.W && a.H == b.H
+}
+
+// sameCreatives compares creatives in order, since they're served in order of preference
+func sameCreatives(a []Creative, b []Creative) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !sameCreative(&a[i], &b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// changedFields lists the settings of the campaign that differ from the spec
+func (c *campaignSettings) changedFields(campaign Campaign) []string {
+	fields := make([]string, 0)
+
+	if campaign.BidCpmInMicroCents() != c.bidCpmInMicroCents {
+		fields = append(fields, "bidCpm")
+	}
+
+	if campaign.DailyBudgetInMicroCents() != c.dailyBudgetInMicroCents {
+		fields = append(fields, "dailyBudget")
+	}
+
+	if !sameTargets(campaign.TargetList(), c.targets) {
+		fields = append(fields, "targets")
+	}
+
+	if campaign.Goal() != c.goal {
+		fields = append(fields, "goal")
+	}
+
+	if campaign.Priority() != c.priority {
+		fields = append(fields, "priority")
+	}
+
+	if !sameCreatives(campaign.Creatives(), c.creatives) {
+		fields = append(fields, "creatives")
+	}
+
+	if campaign.Status() != c.status {
+		fields = append(fields, "status")
+	}
+
+	return fields
+}
+
+// DiffCampaigns returns the changes that make the campaign provider match the specs.
+// With prune, active campaigns that aren't in the specs are paused.
+func DiffCampaigns(cp CampaignProvider, specs []CampaignSpec, prune bool) ([]CampaignChange, error) {
+	if err := ValidateCampaignSpecs(specs); err != nil {
+		return nil, err
+	}
+
+	listed := make(map[int64]bool)
+	for _, id := range cp.ListCampaigns() {
+		listed[id] = true
+	}
+
+	changes := make([]CampaignChange, 0)
+	declared := make(map[int64]bool, len(specs))
+
+	for i := range specs {
+		settings, _ := specs[i].settings()
+		id := specs[i].ID
+		declared[id] = true
+
+		if !listed[id] {
+			if campaign := cp.ReadCampaign(id); campaign != nil && campaign.Status() == CampaignArchived {
+				return nil, fmt.Errorf("campaign %d is archived, and can't be changed", id)
+			}
+
+			changes = append(changes, CampaignChange{Type: CampaignCreate, CampaignId: id, settings: settings})
+			continue
+		}
+
+		if fields := settings.changedFields(cp.ReadCampaign(id)); len(fields) > 0 {
+			changes = append(changes, CampaignChange{Type: CampaignUpdate, CampaignId: id, Fields: fields, settings: settings})
+		}
+	}
+
+	if prune {
+		ids := make([]int64, 0, len(listed))
+		for id := range listed {
+			if !declared[id] {
+				ids = append(ids, id)
+			}
+		}
+
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		for _, id := range ids {
+			if cp.ReadCampaign(id).Status() == CampaignActive {
+				changes = append(changes, CampaignChange{Type: CampaignPrune, CampaignId: id})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// ApplyCampaignChanges makes the changes returned by DiffCampaigns
+func ApplyCampaignChanges(cp CampaignProvider, changes []CampaignChange) {
+	for _, change := range changes {
+		id := change.CampaignId
+		s := change.settings
+
+		switch change.Type {
+		case CampaignCreate:
+			// Campaigns are created paused, so they never bid without the rest of their settings
+			cp.CreatePausedCampaign(id, s.bidCpmInMicroCents, s.dailyBudgetInMicroCents, s.targets)
+			cp.SetCampaignGoal(id, s.goal)
+			cp.SetCampaignPriority(id, s.priority)
+			cp.SetCampaignCreatives(id, s.creatives)
+
+			if s.status == CampaignActive {
+				cp.ResumeCampaign(id)
+			}
+		case CampaignUpdate:
+			changed := make(map[string]bool, len(change.Fields))
+			for _, field := range change.Fields {
+				changed[field] = true
+			}
+
+			// Pause before other changes, and resume after them, so a campaign never bids with half its changes
+			if changed["status"] && s.status == CampaignPaused {
+				cp.PauseCampaign(id)
+			}
+
+			if changed["bidCpm"] || changed["dailyBudget"] || changed["targets"] {
+				cp.UpdateCampaign(id, s.bidCpmInMicroCents, s.dailyBudgetInMicroCents, s.targets)
+			}
+
+			if changed["goal"] {
+				cp.SetCampaignGoal(id, s.goal)
+			}
+
+			if changed["priority"] {
+				cp.SetCampaignPriority(id, s.priority)
+			}
+
+			if changed["creatives"] {
+				cp.SetCampaignCreatives(id, s.creatives)
+			}
+
+			if changed["status"] && s.status == CampaignActive {
+				cp.ResumeCampaign(id)
+			}
+		case CampaignPrune:
+			cp.PauseCampaign(id)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rtb
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseCampaignSpecs ensures a JSON spec file is converted to the targets the campaign provider stores
+// Expected result is the declared targets, a geofence and a schedule constraint
+func TestParseCampaignSpecs(t *testing.T) {
+	specs, err := ParseCampaignSpecs(strings.NewReader(`{"campaigns": [{
+		"id": 1, "bidCpm": 2.5, "dailyBudget": 100, "priority": "guaranteed",
+		"targets": [{"type": "city", "value": "CAN:Toronto"}, {"type": "country", "value": "CAN"}],
+		"geoRadius": [{"lat": 43.6532, "lon": -79.3832, "km": 10}],
+		"schedule": {"timezone": "America/Toronto", "hours": [{"days": ["mon", "fri"], "from": 7, "to": 10}]}
+	}]}`))
+
+	if err != nil || len(specs) != 1 {
+		t.FailNow()
+	}
+
+	settings, err := specs[0].settings()
+	if err != nil {
+		t.FailNow()
+	}
+
+	if settings.bidCpmInMicroCents != CpmToMicroCents(2.5) || settings.priority != PriorityGuaranteed {
+		t.Fail()
+	}
+
+	types := make(map[TargetType]int)
+	for _, target := range settings.targets {
+		types[target.Type]++
+	}
+
+	if types[City] != 1 || types[Country] != 1 || types[GeoRadius] != 1 || types[GeoHash] == 0 || types[Daypart] != 1 {
+		t.Fail()
+	}
+
+	if _, err := ParseCampaignSpecs(strings.NewReader(`{"campaigns": [{"id": 1, "budget": 100}]}`)); err == nil {
+		t.Fail()
+	}
+
+	// Regions, cities and zips need their country
+	unqualified := CampaignSpec{ID: 1, BidCpm: 1, DailyBudget: 1, Targets: []TargetSpec{{Type: "region", Value: "CA"}}}
+	if unqualified.Validate() == nil {
+		t.Fail()
+	}
+}
+
+// TestValidateCampaignSpecs ensures specs with mistakes are rejected
+// Expected result is an error for each broken spec, and for a campaign declared twice
+func TestValidateCampaignSpecs(t *testing.T) {
+	valid := CampaignSpec{ID: 1, BidCpm: 1, DailyBudget: 10, Targets: []TargetSpec{TargetSpec{Type: "allRequests", Value: "*"}}}
+
+	if valid.Validate() != nil {
+		t.FailNow()
+	}
+
+	broken := []func(s *CampaignSpec){
+		func(s *CampaignSpec) { s.ID = 0 },
+		func(s *CampaignSpec) { s.DailyBudget = 0 },
+		func(s *CampaignSpec) { s.Status = "archived" },
+		func(s *CampaignSpec) { s.Goal = &GoalSpec{Type: "cpc"} },
+		func(s *CampaignSpec) { s.Priority = "urgent" },
+		func(s *CampaignSpec) { s.Targets = []TargetSpec{TargetSpec{Type: "requestTime", Value: "1"}} },
+		func(s *CampaignSpec) { s.Targets = []TargetSpec{TargetSpec{Type: "audience", Value: "buyers"}} },
+		func(s *CampaignSpec) {
+			s.Schedule = &ScheduleSpec{Hours: []ScheduleHoursSpec{ScheduleHoursSpec{Days: []string{"someday"}, From: 0, To: 1}}}
+		},
+		func(s *CampaignSpec) {
+			s.Schedule = &ScheduleSpec{Hours: []ScheduleHoursSpec{ScheduleHoursSpec{Days: []string{"mon"}, From: 9, To: 25}}}
+		},
+		func(s *CampaignSpec) { s.Creatives = []Creative{Creative{}} },
+	}
+
+	for i, breakSpec := range broken {
+		s := valid
+		breakSpec(&s)
+
+		if s.Validate() == nil {
+			t.Errorf("spec %d should not be valid", i)
+		}
+	}
+
+	if ValidateCampaignSpecs([]CampaignSpec{valid, valid}) == nil {
+		t.Fail()
+	}
+}
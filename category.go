@@ -0,0 +1,318 @@
+package rtb
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Category defines an IAB content category.
+// Content Taxonomy 1.0 categories have ids like "IAB1-3", and their parent is implied by the id (IAB1).
+// Content Taxonomy 2.x categories have numeric ids, and their parent must be in the taxonomy.
+type Category struct {
+	ID     string
+	Parent string
+	Name   string
+	// Equivalent categories in the other version of the taxonomy
+	Equivalents []string
+}
+
+// CategoryTaxonomy maps content categories to their parents and equivalents, so targeting a category also matches
+// its subcategories, and either version of the taxonomy matches the other
+type CategoryTaxonomy struct {
+	mutex      sync.RWMutex
+	categories map[string]*Category
+}
+
+// Categories is the taxonomy used to target requests. It ships with the tier 1 categories of both versions,
+// and can be extended with LoadCategories. 1.0 subcategories roll up by their id, but 2.x subcategories have numeric
+// ids: until they're loaded they have no parent, and only match campaigns targeting them directly.
+var Categories = NewCategoryTaxonomy()
+
+var iab1Categories = [][2]string{
+	{"IAB1", "Arts & Entertainment"},
+	{"IAB2", "Automotive"},
+	{"IAB3", "Business"},
+	{"IAB4", "Careers"},
+	{"IAB5", "Education"},
+	{"IAB6", "Family & Parenting"},
+	{"IAB7", "Health & Fitness"},
+	{"IAB8", "Food & Drink"},
+	{"IAB9", "Hobbies & Interests"},
+	{"IAB10", "Home & Garden"},
+	{"IAB11", "Law, Gov't & Politics"},
+	{"IAB12", "News"},
+	{"IAB13", "Personal Finance"},
+	{"IAB14", "Society"},
+	{"IAB15", "Science"},
+	{"IAB16", "Pets"},
+	{"IAB17", "Sports"},
+	{"IAB18", "Style & Fashion"},
+	{"IAB19", "Technology & Computing"},
+	{"IAB20", "Travel"},
+	{"IAB21", "Real Estate"},
+	{"IAB22", "Shopping"},
+	{"IAB23", "Religion & Spirituality"},
+	{"IAB24", "Uncategorized"},
+	{"IAB25", "Non-Standard Content"},
+	{"IAB26", "Illegal Content"},
+}
+
+var iab2Categories = [][2]string{
+	{"1", "Automotive"},
+	{"42", "Books and Literature"},
+	{"52", "Business and Finance"},
+	{"123", "Careers"},
+	{"132", "Education"},
+	{"150", "Events and Attractions"},
+	{"186", "Family and Relationships"},
+	{"201", "Fine Art"},
+	{"210", "Food & Drink"},
+	{"223", "Healthy Living"},
+	{"239", "Hobbies & Interests"},
+	{"274", "Home & Garden"},
+	{"286", "Medical Health"},
+	{"324", "Movies"},
+	{"338", "Music and Audio"},
+	{"379", "News and Politics"},
+	{"391", "Personal Finance"},
+	{"422", "Pets"},
+	{"432", "Pop Culture"},
+	{"441", "Real Estate"},
+	{"453", "Religion & Spirituality"},
+	{"464", "Science"},
+	{"473", "Shopping"},
+	{"483", "Sports"},
+	{"552", "Style & Fashion"},
+	{"596", "Technology & Computing"},
+	{"640", "Television"},
+	{"653", "Travel"},
+	{"680", "Video Gaming"},
+}
+
+// Content Taxonomy 1.0 categories and their closest 2.x equivalents
+var iab1To2Categories = map[string]string{
+	"IAB1-1":  "42",
+	"IAB1-2":  "432",
+	"IAB1-4":  "201",
+	"IAB1-5":  "324",
+	"IAB1-6":  "338",
+	"IAB1-7":  "640",
+	"IAB2":    "1",
+	"IAB3":    "52",
+	"IAB4":    "123",
+	"IAB5":    "132",
+	"IAB6":    "186",
+	"IAB7":    "223",
+	"IAB8":    "210",
+	"IAB9":    "239",
+	"IAB9-30": "680",
+	"IAB10":   "274",
+	"IAB11":   "379",
+	"IAB12":   "379",
+	"IAB13":   "391",
+	"IAB15":   "464",
+	"IAB16":   "422",
+	"IAB17":   "483",
+	"IAB18":   "552",
+	"IAB19":   "596",
+	"IAB20":   "653",
+	"IAB21":   "441",
+	"IAB22":   "473",
+	"IAB23":   "453",
+}
+
+// NormalizeCategory returns the id of a category as it is stored in targets
+func NormalizeCategory(id string) string {
+	return strings.ToUpper(strings.TrimSpace(id))
+}
+
+// NewCategoryTaxonomy creates a taxonomy with the tier 1 categories of both versions
+func NewCategoryTaxonomy() *CategoryTaxonomy {
+	t := new(CategoryTaxonomy)
+	t.categories = make(map[string]*Category)
+
+	for _, c := range iab1Categories {
+		t.Add(c[0], "", c[1])
+	}
+
+	for _, c := range iab2Categories {
+		t.Add(c[0], "", c[1])
+	}
+
+	for iab1, iab2 := range iab1To2Categories {
+		t.AddEquivalent(iab1, iab2)
+	}
+
+	return t
+}
+
+func (t *CategoryTaxonomy) category(id string) *Category {
+	c, ok := t.categories[id]
+	if !ok {
+		c = &Category{ID: id}
+		t.categories[id] = c
+	}
+
+	return c
+}
+
+// Add adds a category to the taxonomy, or updates its parent and name
+func (t *CategoryTaxonomy) Add(id string, parent string, name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	c := t.category(NormalizeCategory(id))
+	c.Parent = NormalizeCategory(parent)
+	c.Name = name
+}
+
+// AddEquivalent records that two categories, one from each version of the taxonomy, are the same
+func (t *CategoryTaxonomy) AddEquivalent(a string, b string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	a = NormalizeCategory(a)
+	b = NormalizeCategory(b)
+
+	t.category(a).Equivalents = append(t.category(a).Equivalents, b)
+	t.category(b).Equivalents = append(t.category(b).Equivalents, a)
+}
+
+// Name returns the name of a category, or an empty string if it is not in the taxonomy
+func (t *CategoryTaxonomy) Name(id string) string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	if c, ok := t.categories[NormalizeCategory(id)]; ok {
+		return c.Name
+	}
+
+	return ""
+}
+
+func (t *CategoryTaxonomy) parent(id string) string {
+	if c, ok := t.categories[id]; ok && c.Parent != "" {
+		return c.Parent
+	}
+
+	// Content Taxonomy 1.0 subcategories are named after their parent
+	if strings.HasPrefix(id, "IAB") {
+		if i := strings.LastIndexByte(id, '-'); i > 0 {
+			return id[:i]
+		}
+	}
+
+	return ""
+}
+
+// Expand returns the categories, every one of their ancestors, and the equivalents of all of them. Each category appears once.
+// Equivalents add their own ancestors but not their own equivalents, since several 1.0 categories share one 2.x equivalent
+// (IAB11 and IAB12 are both News and Politics), and news isn't politics.
+func (t *CategoryTaxonomy) Expand(ids []string) []string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	expanded := make([]string, 0, len(ids)*2)
+	// Whether each category added so far had its equivalents added
+	seen := make(map[string]bool)
+
+	var add func(id string, withEquivalents bool)
+	add = func(id string, withEquivalents bool) {
+		if id == "" {
+			return
+		}
+
+		followed, ok := seen[id]
+		if ok && (followed || !withEquivalents) {
+			return
+		}
+
+		if !ok {
+			expanded = append(expanded, id)
+		}
+		seen[id] = withEquivalents
+
+		if c, ok := t.categories[id]; ok && withEquivalents {
+			for _, equivalent := range c.Equivalents {
+				add(equivalent, false)
+			}
+		}
+
+		add(t.parent(id), withEquivalents)
+	}
+
+	for _, id := range ids {
+		add(NormalizeCategory(id), true)
+	}
+
+	return expanded
+}
+
+// LoadCategories adds the categories in a CSV file to the taxonomy. Each record is an id, a parent id (empty for tier 1) and a name.
+// A header record ("id,parent,name") is skipped. Returns the number of categories read.
+func (t *CategoryTaxonomy) LoadCategories(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	count := 0
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+
+		if err != nil {
+			return count, err
+		}
+
+		if count == 0 && strings.EqualFold(record[0], "id") {
+			continue
+		}
+
+		t.Add(record[0], record[1], record[2])
+		count++
+	}
+}
+
+// ContentCategoryTargets returns the targets for campaigns that want content in any of the categories, or their subcategories
+func ContentCategoryTargets(ids ...string) []Target {
+	targets := make([]Target, 0, len(ids))
+
+	for _, id := range ids {
+		targets = append(targets, Target{Type: ContentCategory, Value: NormalizeCategory(id)})
+	}
+
+	return targets
+}
+
+// CategoryExcludeTarget returns the constraint keeping a campaign off content in any of the categories, or their subcategories
+func CategoryExcludeTarget(ids ...string) Target {
+	normalized := make([]string, len(ids))
+
+	for i, id := range ids {
+		normalized[i] = NormalizeCategory(id)
+	}
+
+	return Target{Type: CategoryExclude, Value: strings.Join(normalized, ",")}
+}
+
+// categoryExcludeSatisfied checks a CategoryExclude value against the request's (already expanded) content categories
+func categoryExcludeSatisfied(value string, targets []Target) bool {
+	excluded := strings.Split(value, ",")
+
+	for _, target := range targets {
+		if target.Type != ContentCategory {
+			continue
+		}
+
+		for _, id := range excluded {
+			if target.Value == id {
+				return false
+			}
+		}
+	}
+
+	return true
+}
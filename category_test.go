@@ -0,0 +1,69 @@
+package rtb
+
+import (
+	"strings"
+	"testing"
+)
+
+func containsCategory(categories []string, id string) bool {
+	for _, c := range categories {
+		if c == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TestExpandCategories ensures subcategories roll up to their parents, and each version of the taxonomy maps to the other
+// Expected result is IAB1-5 expands to IAB1 and Movies (324), and Automotive (1) expands to IAB2
+func TestExpandCategories(t *testing.T) {
+	expanded := NewCategoryTaxonomy().Expand([]string{"iab1-5", "1"})
+
+	for _, id := range []string{"IAB1-5", "IAB1", "324", "1", "IAB2"} {
+		if !containsCategory(expanded, id) {
+			t.Fail()
+		}
+	}
+
+	if containsCategory(expanded, "IAB1-6") {
+		t.Fail()
+	}
+}
+
+// TestExpandSharedEquivalent ensures 1.0 categories sharing a 2.x equivalent don't expand to each other
+// Expected result is News (IAB12) expands to News and Politics (379) but not Law, Gov't & Politics (IAB11), and isn't excluded by it
+func TestExpandSharedEquivalent(t *testing.T) {
+	taxonomy := NewCategoryTaxonomy()
+	expanded := taxonomy.Expand([]string{"IAB12"})
+
+	if len(expanded) != 2 || expanded[0] != "IAB12" || expanded[1] != "379" {
+		t.Fail()
+	}
+
+	if !categoryExcludeSatisfied(CategoryExcludeTarget("IAB11").Value, ContentCategoryTargets(expanded...)) {
+		t.Fail()
+	}
+
+	// News and Politics covers both
+	if expanded := taxonomy.Expand([]string{"379"}); len(expanded) != 3 || !containsCategory(expanded, "IAB11") || !containsCategory(expanded, "IAB12") {
+		t.Fail()
+	}
+}
+
+// TestLoadCategories ensures Content Taxonomy 2.x subcategories loaded from a file roll up to their parents
+// Expected result is Auto Body Styles (2) expands to Automotive (1) and its 1.0 equivalent
+func TestLoadCategories(t *testing.T) {
+	taxonomy := NewCategoryTaxonomy()
+
+	count, err := taxonomy.LoadCategories(strings.NewReader("id,parent,name\n2,1,Auto Body Styles\n3,2,Commercial Trucks\n"))
+	if err != nil || count != 2 {
+		t.FailNow()
+	}
+
+	expanded := taxonomy.Expand([]string{"3"})
+
+	if len(expanded) != 4 || !containsCategory(expanded, "1") || !containsCategory(expanded, "IAB2") || taxonomy.Name("3") != "Commercial Trucks" {
+		t.Fail()
+	}
+}
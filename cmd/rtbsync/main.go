@@ -0,0 +1,133 @@
+// Command rtbsync makes the campaigns in redis match a set of campaign spec files.
+//
+// Spec files are JSON, or YAML if they end in .yaml or .yml, and declare a list of campaigns:
+//
+//	campaigns:
+//	  - id: 1001
+//	    name: Toronto commuters
+//	    bidCpm: 2.5
+//	    dailyBudget: 100
+//	    targets:
+//	      - {type: city, value: "CAN:Toronto"}
+//	      - {type: connectionType, value: cellular}
+//	    schedule:
+//	      timezone: America/Toronto
+//	      hours:
+//	        - {days: [mon, tue, wed, thu, fri], from: 7, to: 10}
+//	    creatives:
+//	      - {id: banner-1, adomain: [example.com], w: 320, h: 50}
+//
+// By default the changes are only printed. Pass --apply to make them.
+//
+//	rtbsync --validate campaigns/*.yaml
+//	rtbsync --addr localhost:6379 --domain rtb campaigns/*.yaml
+//	rtbsync --addr localhost:6379 --domain rtb --apply --prune campaigns/*.yaml
+//	rtbsync --cluster --addr node1:6379,node2:6379 --domain rtb campaigns/*.yaml
+//	rtbsync --sentinel-master rtb --addr sentinel:26379 --domain rtb campaigns/*.yaml
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	"github.com/evandigby/rtb/redis"
+	"github.com/evandigby/rtb/redis/radix"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func readSpecs(fileName string) ([]rtb.CampaignSpec, error) {
+	contents, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		var file rtb.CampaignSpecFile
+
+		if err := yaml.UnmarshalStrict(contents, &file); err != nil {
+			return nil, err
+		}
+
+		return file.Campaigns, nil
+	}
+
+	return rtb.ParseCampaignSpecs(bytes.NewReader(contents))
+}
+
+func main() {
+	network := flag.String("network", "tcp", "Network of the redis server")
+	addr := flag.String("addr", "localhost:6379", "Address of the redis server")
+	cluster := flag.Bool("cluster", false, "Treat --addr as a comma separated list of redis cluster nodes")
+	sentinelMaster := flag.String("sentinel-master", "", "Connect to this master through the sentinel at --addr")
+	domain := flag.String("domain", "", "App domain the campaigns are stored under")
+	validate := flag.Bool("validate", false, "Only validate the spec files")
+	apply := flag.Bool("apply", false, "Make the changes instead of printing them")
+	prune := flag.Bool("prune", false, "Pause active campaigns that aren't in the spec files")
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	specs := make([]rtb.CampaignSpec, 0)
+
+	for _, fileName := range flag.Args() {
+		fileSpecs, err := readSpecs(fileName)
+		if err != nil {
+			log.Fatalf("%v: %v", fileName, err)
+		}
+
+		specs = append(specs, fileSpecs...)
+	}
+
+	if err := rtb.ValidateCampaignSpecs(specs); err != nil {
+		log.Fatal(err)
+	}
+
+	if *validate {
+		log.Printf("%v campaigns are valid\n", len(specs))
+		return
+	}
+
+	var da nodb.NoDbDataAccess
+
+	switch {
+	case *cluster:
+		da = radix.NewRedisClusterDataAccess(*network, strings.Split(*addr, ","), *domain, 1)
+	case *sentinelMaster != "":
+		da = radix.NewRedisSentinelDataAccess(*network, *addr, *sentinelMaster, *domain, 1)
+	default:
+		da = radix.NewRedisDataAccess(*network, *addr, *domain, 1)
+	}
+
+	cp := redis.NewRedisCampaignProvider(da, redis.NewRedisBanker(da))
+
+	changes, err := rtb.DiffCampaigns(cp, specs, *prune)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	if len(changes) == 0 {
+		log.Println("Campaigns match the spec files")
+		return
+	}
+
+	if *apply {
+		rtb.ApplyCampaignChanges(cp, changes)
+		log.Printf("Applied %v changes\n", len(changes))
+	}
+}
@@ -0,0 +1,134 @@
+// Command rtbtrain trains a click or conversion prediction model from bid logs.
+// It also writes the auction outcomes bid shading learns win rates from, which should be regenerated from the latest logs
+// regularly, e.g. daily, so shading follows the market.
+//
+// The bid log is the full JSON log written by inmemory.FileBidLogger, one item per line.
+// The shown and events logs list one impression per line as "bid request id:impression id".
+// For a click model pass the win log as shown and the click log as events;
+// for a conversion model pass the click log as shown and the conversion log as events.
+//
+//	rtbtrain --bidlog bids.log --shown wins.log --events clicks.log --out ctr.json
+//	rtbtrain --bidlog bids.log --shown wins.log --outcomes outcomes.csv
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/inmemory"
+	"log"
+	"os"
+)
+
+func readBidLog(fileName string) []*rtb.BidLogItem {
+	file, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	items := make([]*rtb.BidLogItem, 0)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		item := new(rtb.BidLogItem)
+
+		if err := json.Unmarshal(scanner.Bytes(), item); err != nil {
+			log.Fatal(err)
+		}
+
+		items = append(items, item)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	return items
+}
+
+func readKeys(fileName string) map[string]bool {
+	file, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	keys := make(map[string]bool)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			keys[line] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	return keys
+}
+
+func writeOutcomes(fileName string, bidLog []*rtb.BidLogItem, wins map[string]bool) {
+	out, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := inmemory.WriteAuctionOutcomes(out, bidLog, wins); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Wrote the auction outcomes of %v bid log items to %v\n", len(bidLog), fileName)
+}
+
+func main() {
+	bidLogFile := flag.String("bidlog", "", "Full JSON bid log")
+	shownFile := flag.String("shown", "", "Impressions to train on (win log for clicks, click log for conversions). Every bid is used if empty.")
+	eventsFile := flag.String("events", "", "Impressions that were positive (click log or conversion log)")
+	outFile := flag.String("out", "model.json", "Where to write the model")
+	epochs := flag.Int("epochs", 10, "Passes over the training data")
+	learningRate := flag.Float64("rate", 0.05, "Learning rate")
+	l2 := flag.Float64("l2", 0.0001, "L2 regularization of feature weights")
+	outcomesFile := flag.String("outcomes", "", "Write the auction outcomes of every bid, won if it's in the win log passed as shown, instead of training a model")
+
+	flag.Parse()
+
+	if *bidLogFile == "" || (*eventsFile == "" && *outcomesFile == "") || (*outcomesFile != "" && *shownFile == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	bidLog := readBidLog(*bidLogFile)
+
+	if *outcomesFile != "" {
+		writeOutcomes(*outcomesFile, bidLog, readKeys(*shownFile))
+		return
+	}
+
+	var shown map[string]bool
+	if *shownFile != "" {
+		shown = readKeys(*shownFile)
+	}
+
+	examples := inmemory.TrainingExamplesFromLogs(bidLog, shown, readKeys(*eventsFile))
+
+	model := inmemory.TrainLogisticRegression(examples, *epochs, *learningRate, *l2)
+
+	out, err := os.Create(*outFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := model.Write(out); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Trained on %v examples, wrote %v\n", len(examples), *outFile)
+}
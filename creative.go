@@ -0,0 +1,129 @@
+package rtb
+
+import (
+	"strings"
+)
+
+// Creative defines an ad a campaign can serve, and what publishers need to know to decide whether they allow it
+type Creative struct {
+	ID  string `json:"id"`
+	Adm string `json:"adm,omitempty"`
+	// Advertiser domains, checked against badv
+	Adomain []string `json:"adomain,omitempty"`
+	// IAB content categories of the ad, checked against bcat
+	Cat []string `json:"cat,omitempty"`
+	// OpenRTB creative attributes, checked against the banner's battr
+	Attr []int `json:"attr,omitempty"`
+	// Bundle of the app being advertised, if any, checked against bapp
+	Bundle string `json:"bundle,omitempty"`
+	// Size of the ad. Zero matches any size.
+	W int32 `json:"w,omitempty"`
+	H int32 `json:"h,omitempty"`
+}
+
+// StubCreative is served by campaigns that have no creatives of their own
+var StubCreative = Creative{ID: "stub", Adm: "<span>stub</span>", Adomain: []string{"stub.go2mobi.com"}}
+
+// categoryBlocked returns whether cat is one of the blocked categories, or a subcategory of one (IAB7-1 is blocked by IAB7)
+func categoryBlocked(cat string, blocked []string) bool {
+	for _, b := range blocked {
+		if strings.EqualFold(cat, b) || (len(cat) > len(b) && strings.EqualFold(cat[:len(b)+1], b+"-")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// domainBlocked returns whether domain is one of the blocked domains, or a subdomain of one
+func domainBlocked(domain string, blocked []string) bool {
+	domain = strings.ToLower(domain)
+
+	for _, b := range blocked {
+		b = strings.ToLower(b)
+
+		if domain == b || strings.HasSuffix(domain, "."+b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allowed returns whether the publisher allows the creative to be shown in the impression
+func (c *Creative) Allowed(r *BidRequest, imp *Imp) bool {
+	for _, cat := range c.Cat {
+		if categoryBlocked(cat, r.Bcat) {
+			return false
+		}
+	}
+
+	for _, domain := range c.Adomain {
+		if domainBlocked(domain, r.Badv) {
+			return false
+		}
+	}
+
+	if c.Bundle != "" {
+		for _, bundle := range r.Bapp {
+			if strings.EqualFold(c.Bundle, bundle) {
+				return false
+			}
+		}
+	}
+
+	if imp.Banner != nil {
+		for _, attr := range c.Attr {
+			for _, blocked := range imp.Banner.Battr {
+				if attr == int(blocked) {
+					return false
+				}
+			}
+		}
+
+		if c.W != 0 && c.H != 0 && imp.Banner.W != 0 && imp.Banner.H != 0 && (c.W != imp.Banner.W || c.H != imp.Banner.H) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// uncheckable returns whether the publisher blocks categories or attributes, which can't be checked without a campaign's own creatives
+func uncheckable(r *BidRequest, imp *Imp) bool {
+	return len(r.Bcat) > 0 || (imp.Banner != nil && len(imp.Banner.Battr) > 0)
+}
+
+// AllowedCreatives returns the campaign's creatives the publisher allows in the impression, in order of preference.
+// Campaigns without creatives serve the StubCreative, unless the publisher blocks categories or attributes, since the stub
+// has neither and would pass every check. They don't bid on those impressions.
+func AllowedCreatives(r *BidRequest, imp *Imp, campaign Campaign) []Creative {
+	creatives := campaign.Creatives()
+	if len(creatives) == 0 {
+		if uncheckable(r, imp) {
+			return []Creative{}
+		}
+
+		creatives = []Creative{StubCreative}
+	}
+
+	allowed := make([]Creative, 0, len(creatives))
+
+	for _, creative := range creatives {
+		if creative.Allowed(r, imp) {
+			allowed = append(allowed, creative)
+		}
+	}
+
+	return allowed
+}
+
+// AllowedCreative returns the first of the campaign's creatives the publisher allows in the impression.
+// Returns false if every creative is blocked.
+func AllowedCreative(r *BidRequest, imp *Imp, campaign Campaign) (Creative, bool) {
+	if allowed := AllowedCreatives(r, imp, campaign); len(allowed) > 0 {
+		return allowed[0], true
+	}
+
+	return Creative{}, false
+}
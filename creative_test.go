@@ -0,0 +1,67 @@
+package rtb
+
+import (
+	"testing"
+)
+
+// TestCreativeAllowed ensures each of the publisher's block lists is honored
+// Expected result is the creative is blocked by a parent category, a parent domain, its app bundle and its attributes
+func TestCreativeAllowed(t *testing.T) {
+	c := &Creative{ID: "1", Adomain: []string{"Shop.Example.com"}, Cat: []string{"IAB7-1"}, Attr: []int{6}, Bundle: "com.example.shop"}
+	imp := &Imp{Banner: &Banner{W: 320, H: 50}}
+
+	if !c.Allowed(&BidRequest{Bcat: []string{"IAB7-10"}, Badv: []string{"example.org"}}, imp) {
+		t.Fail()
+	}
+
+	if c.Allowed(&BidRequest{Bcat: []string{"IAB7"}}, imp) {
+		t.Fail()
+	}
+
+	if c.Allowed(&BidRequest{Badv: []string{"example.com"}}, imp) {
+		t.Fail()
+	}
+
+	if c.Allowed(&BidRequest{Bapp: []string{"com.example.shop"}}, imp) {
+		t.Fail()
+	}
+
+	if c.Allowed(&BidRequest{}, &Imp{Banner: &Banner{Battr: []float64{6}}}) {
+		t.Fail()
+	}
+}
+
+// TestAllowedCreative ensures the first allowed creative of the right size is chosen
+// Expected result is the second creative, since the first is the wrong size, and no creative once its category is blocked
+func TestAllowedCreative(t *testing.T) {
+	c := &goalCampaign{creatives: []Creative{Creative{ID: "1", W: 300, H: 250}, Creative{ID: "2", W: 320, H: 50, Cat: []string{"IAB3"}}}}
+	imp := &Imp{Banner: &Banner{W: 320, H: 50}}
+
+	creative, ok := AllowedCreative(&BidRequest{}, imp, c)
+	if !ok || creative.ID != "2" {
+		t.Fail()
+	}
+
+	if _, ok := AllowedCreative(&BidRequest{Bcat: []string{"IAB3"}}, imp, c); ok {
+		t.Fail()
+	}
+}
+
+// TestAllowedCreativeWithoutCreatives ensures campaigns without creatives only serve the stub where it can be checked
+// Expected result is the stub creative, unless the publisher blocks categories or attributes
+func TestAllowedCreativeWithoutCreatives(t *testing.T) {
+	c := &goalCampaign{}
+	imp := &Imp{Banner: &Banner{W: 320, H: 50}}
+
+	if creative, ok := AllowedCreative(&BidRequest{Badv: []string{"example.com"}}, imp, c); !ok || creative.ID != StubCreative.ID {
+		t.Fail()
+	}
+
+	if _, ok := AllowedCreative(&BidRequest{Bcat: []string{"IAB3"}}, imp, c); ok {
+		t.Fail()
+	}
+
+	if _, ok := AllowedCreative(&BidRequest{}, &Imp{Banner: &Banner{Battr: []float64{6}}}, c); ok {
+		t.Fail()
+	}
+}
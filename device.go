@@ -0,0 +1,97 @@
+package rtb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DeviceTypeNames are the values DeviceType targets use for each OpenRTB device type
+var DeviceTypeNames = map[int]string{
+	1: "mobile",
+	2: "pc",
+	3: "ctv",
+	4: "phone",
+	5: "tablet",
+	6: "connected",
+	7: "settopbox",
+}
+
+// ConnectionTypeNames are the values ConnectionType targets use for each OpenRTB connection type
+var ConnectionTypeNames = map[int]string{
+	1: "ethernet",
+	2: "wifi",
+	3: "cellular",
+	4: "2g",
+	5: "3g",
+	6: "4g",
+	7: "5g",
+}
+
+// ConnectionTypeCellular matches any cellular connection, whatever its generation
+const ConnectionTypeCellular = "cellular"
+
+// Targeting creates a list of targets from the device a request came from, not including its location
+func (d *Device) Targeting() []Target {
+	targets := make([]Target, 0, 8)
+
+	if d.Os != "" {
+		os := Target{Type: OS, Value: d.Os}
+		targets = append(targets, os)
+	}
+
+	if d.Osv != "" {
+		targets = append(targets, Target{Type: OSVersion, Value: d.Osv})
+	}
+
+	if d.Make != "" {
+		targets = append(targets, Target{Type: DeviceMake, Value: d.Make})
+	}
+
+	if d.Model != "" {
+		targets = append(targets, Target{Type: DeviceModel, Value: d.Model})
+	}
+
+	if name, ok := DeviceTypeNames[int(d.Devicetype)]; ok {
+		targets = append(targets, Target{Type: DeviceType, Value: name})
+	}
+
+	if d.Carrier != "" {
+		targets = append(targets, Target{Type: Carrier, Value: d.Carrier})
+	}
+
+	connectionType := int(d.Connectiontype)
+	if name, ok := ConnectionTypeNames[connectionType]; ok {
+		targets = append(targets, Target{Type: ConnectionType, Value: name})
+
+		// 2G through 5G are also just cellular
+		if connectionType > 3 {
+			targets = append(targets, Target{Type: ConnectionType, Value: ConnectionTypeCellular})
+		}
+	}
+
+	if d.Language != "" {
+		targets = append(targets, Target{Type: Language, Value: d.Language})
+	}
+
+	for _, id := range []string{d.Ifa, d.Didsha1, d.Didmd5, d.Dpidsha1, d.Dpidmd5} {
+		if id != "" {
+			targets = append(targets, Target{Type: DeviceID, Value: strings.ToLower(id)})
+		}
+	}
+
+	return targets
+}
+
+// OSVersionRangeTarget returns the constraint for a range of OS versions, e.g. OSVersionRangeTarget(">=14.2", "<17")
+func OSVersionRangeTarget(comparisons ...string) Target {
+	return Target{Type: OSVersionRange, Value: strings.Join(comparisons, ",")}
+}
+
+// DeviceTypeTarget returns the target for an OpenRTB device type
+func DeviceTypeTarget(deviceType int) Target {
+	if name, ok := DeviceTypeNames[deviceType]; ok {
+		return Target{Type: DeviceType, Value: name}
+	}
+
+	return Target{Type: DeviceType, Value: strconv.Itoa(deviceType)}
+}
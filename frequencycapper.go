@@ -0,0 +1,7 @@
+package rtb
+
+// FrequencyCapper limits how many times each user is shown a campaign
+type FrequencyCapper interface {
+	// Allow counts an impression of the campaign for the user, and tells whether it was within the cap
+	Allow(campaignId int64, userId string) bool
+}
@@ -0,0 +1,365 @@
+package rtb
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxGeoHashPrecision is the longest geohash a request is indexed by (about 150m x 150m)
+const MaxGeoHashPrecision = 7
+
+// Geofences are covered by at most this many geohash cells in the target index
+const maxGeoHashCoverCells = 64
+
+const earthRadiusInKm = 6371.0
+
+const geoHashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// LatLon defines a point on the earth
+type LatLon struct {
+	Lat float64
+	Lon float64
+}
+
+// GeoCircle defines a radius around a point
+type GeoCircle struct {
+	Center     LatLon
+	RadiusInKm float64
+}
+
+type geoBox struct {
+	minLat float64
+	maxLat float64
+	minLon float64
+	maxLon float64
+}
+
+// EncodeGeoHash returns the geohash of a point at the given precision (number of characters)
+func EncodeGeoHash(point LatLon, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	even := true
+	bit := 0
+	ch := 0
+
+	for len(hash) < precision {
+		if even {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if point.Lon >= mid {
+				ch |= 1 << uint(4-bit)
+				lonRange[0] = mid
+			} else {
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if point.Lat >= mid {
+				ch |= 1 << uint(4-bit)
+				latRange[0] = mid
+			} else {
+				latRange[1] = mid
+			}
+		}
+
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			hash = append(hash, geoHashBase32[ch])
+			bit = 0
+			ch = 0
+		}
+	}
+
+	return string(hash)
+}
+
+// geoHashCellSize returns the height and width in degrees of geohash cells at a precision
+func geoHashCellSize(precision int) (latDegrees float64, lonDegrees float64) {
+	bits := uint(5 * precision)
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+func geoHashBox(hash string) geoBox {
+	box := geoBox{minLat: -90, maxLat: 90, minLon: -180, maxLon: 180}
+	even := true
+
+	for i := 0; i < len(hash); i++ {
+		ch := strings.IndexByte(geoHashBase32, hash[i])
+
+		for bit := 4; bit >= 0; bit-- {
+			on := ch&(1<<uint(bit)) != 0
+
+			if even {
+				mid := (box.minLon + box.maxLon) / 2
+				if on {
+					box.minLon = mid
+				} else {
+					box.maxLon = mid
+				}
+			} else {
+				mid := (box.minLat + box.maxLat) / 2
+				if on {
+					box.minLat = mid
+				} else {
+					box.maxLat = mid
+				}
+			}
+
+			even = !even
+		}
+	}
+
+	return box
+}
+
+// DistanceInKm returns the great circle distance between two points
+func DistanceInKm(a LatLon, b LatLon) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusInKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Contains returns whether the point is within the circle
+func (c GeoCircle) Contains(point LatLon) bool {
+	return DistanceInKm(c.Center, point) <= c.RadiusInKm
+}
+
+func (c GeoCircle) box() geoBox {
+	latDelta := c.RadiusInKm / earthRadiusInKm * 180 / math.Pi
+	lonDelta := latDelta / math.Max(math.Cos(c.Center.Lat*math.Pi/180), 0.01)
+
+	return geoBox{minLat: c.Center.Lat - latDelta, maxLat: c.Center.Lat + latDelta, minLon: c.Center.Lon - lonDelta, maxLon: c.Center.Lon + lonDelta}
+}
+
+func (c GeoCircle) intersects(box geoBox) bool {
+	nearest := LatLon{Lat: math.Max(box.minLat, math.Min(c.Center.Lat, box.maxLat)), Lon: math.Max(box.minLon, math.Min(c.Center.Lon, box.maxLon))}
+
+	return c.Contains(nearest)
+}
+
+// PolygonContains returns whether the point is inside the polygon. Polygons must not cross the antimeridian.
+func PolygonContains(polygon []LatLon, point LatLon) bool {
+	inside := false
+
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a := polygon[i]
+		b := polygon[j]
+
+		if (a.Lat > point.Lat) != (b.Lat > point.Lat) && point.Lon < (b.Lon-a.Lon)*(point.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func segmentsIntersect(p1 LatLon, p2 LatLon, p3 LatLon, p4 LatLon) bool {
+	cross := func(o LatLon, a LatLon, b LatLon) float64 {
+		return (a.Lon-o.Lon)*(b.Lat-o.Lat) - (a.Lat-o.Lat)*(b.Lon-o.Lon)
+	}
+
+	d1 := cross(p3, p4, p1)
+	d2 := cross(p3, p4, p2)
+	d3 := cross(p1, p2, p3)
+	d4 := cross(p1, p2, p4)
+
+	return ((d1 > 0) != (d2 > 0)) && ((d3 > 0) != (d4 > 0))
+}
+
+func polygonIntersects(polygon []LatLon, box geoBox) bool {
+	corners := []LatLon{{box.minLat, box.minLon}, {box.minLat, box.maxLon}, {box.maxLat, box.maxLon}, {box.maxLat, box.minLon}}
+
+	for _, corner := range corners {
+		if PolygonContains(polygon, corner) {
+			return true
+		}
+	}
+
+	for i, point := range polygon {
+		if point.Lat >= box.minLat && point.Lat <= box.maxLat && point.Lon >= box.minLon && point.Lon <= box.maxLon {
+			return true
+		}
+
+		next := polygon[(i+1)%len(polygon)]
+		for c := range corners {
+			if segmentsIntersect(point, next, corners[c], corners[(c+1)%len(corners)]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func polygonBox(polygon []LatLon) geoBox {
+	box := geoBox{minLat: 90, maxLat: -90, minLon: 180, maxLon: -180}
+
+	for _, point := range polygon {
+		box.minLat = math.Min(box.minLat, point.Lat)
+		box.maxLat = math.Max(box.maxLat, point.Lat)
+		box.minLon = math.Min(box.minLon, point.Lon)
+		box.maxLon = math.Max(box.maxLon, point.Lon)
+	}
+
+	return box
+}
+
+// coverGeoHashes returns the geohash cells that intersect a shape, at the finest precision that keeps the number of cells small
+func coverGeoHashes(box geoBox, intersects func(geoBox) bool) []string {
+	precision := 1
+
+	for p := MaxGeoHashPrecision; p > 1; p-- {
+		latDegrees, lonDegrees := geoHashCellSize(p)
+		cells := (math.Floor((box.maxLat-box.minLat)/latDegrees) + 2) * (math.Floor((box.maxLon-box.minLon)/lonDegrees) + 2)
+
+		if cells <= maxGeoHashCoverCells {
+			precision = p
+			break
+		}
+	}
+
+	latDegrees, lonDegrees := geoHashCellSize(precision)
+
+	hashes := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for lat := box.minLat; lat < box.maxLat+latDegrees; lat += latDegrees {
+		for lon := box.minLon; lon < box.maxLon+lonDegrees; lon += lonDegrees {
+			hash := EncodeGeoHash(LatLon{Lat: math.Min(lat, box.maxLat), Lon: math.Min(lon, box.maxLon)}, precision)
+
+			if !seen[hash] {
+				seen[hash] = true
+
+				if intersects(geoHashBox(hash)) {
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+	}
+
+	return hashes
+}
+
+func formatLatLon(point LatLon) string {
+	return strconv.FormatFloat(point.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(point.Lon, 'f', -1, 64)
+}
+
+func parseFloats(value string) ([]float64, bool) {
+	fields := strings.Split(value, ",")
+	floats := make([]float64, len(fields))
+
+	for i, field := range fields {
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, false
+		}
+		floats[i] = f
+	}
+
+	return floats, true
+}
+
+func parseLatLon(value string) (LatLon, bool) {
+	floats, ok := parseFloats(value)
+
+	if !ok || len(floats) != 2 {
+		return LatLon{}, false
+	}
+
+	return LatLon{Lat: floats[0], Lon: floats[1]}, true
+}
+
+// GeoRadiusTargets returns the targets for a campaign that should only bid within any of the circles:
+// the geohash cells covering them for the target index, and a GeoRadius constraint to check the exact distance
+func GeoRadiusTargets(circles ...GeoCircle) []Target {
+	targets := make([]Target, 0)
+	values := make([]string, 0, len(circles))
+	seen := make(map[string]bool)
+
+	for _, circle := range circles {
+		for _, hash := range coverGeoHashes(circle.box(), circle.intersects) {
+			if !seen[hash] {
+				seen[hash] = true
+				targets = append(targets, Target{Type: GeoHash, Value: hash})
+			}
+		}
+
+		values = append(values, formatLatLon(circle.Center)+","+strconv.FormatFloat(circle.RadiusInKm, 'f', -1, 64))
+	}
+
+	return append(targets, Target{Type: GeoRadius, Value: strings.Join(values, ";")})
+}
+
+// GeoPolygonTargets returns the targets for a campaign that should only bid within any of the polygons:
+// the geohash cells covering them for the target index, and a GeoPolygon constraint to check the exact shape
+func GeoPolygonTargets(polygons ...[]LatLon) []Target {
+	targets := make([]Target, 0)
+	values := make([]string, 0, len(polygons))
+	seen := make(map[string]bool)
+
+	for _, polygon := range polygons {
+		intersects := func(box geoBox) bool { return polygonIntersects(polygon, box) }
+
+		for _, hash := range coverGeoHashes(polygonBox(polygon), intersects) {
+			if !seen[hash] {
+				seen[hash] = true
+				targets = append(targets, Target{Type: GeoHash, Value: hash})
+			}
+		}
+
+		points := make([]string, len(polygon))
+		for i, point := range polygon {
+			points[i] = formatLatLon(point)
+		}
+
+		values = append(values, strings.Join(points, ";"))
+	}
+
+	return append(targets, Target{Type: GeoPolygon, Value: strings.Join(values, "|")})
+}
+
+// geoRadiusSatisfied checks a GeoRadius value: circles as "lat,lon,km" separated by ";"
+func geoRadiusSatisfied(value string, point LatLon) bool {
+	for _, circle := range strings.Split(value, ";") {
+		floats, ok := parseFloats(circle)
+
+		if ok && len(floats) == 3 && (GeoCircle{Center: LatLon{Lat: floats[0], Lon: floats[1]}, RadiusInKm: floats[2]}).Contains(point) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// geoPolygonSatisfied checks a GeoPolygon value: polygons separated by "|", each a list of "lat,lon" separated by ";"
+func geoPolygonSatisfied(value string, point LatLon) bool {
+	for _, polygonValue := range strings.Split(value, "|") {
+		pointValues := strings.Split(polygonValue, ";")
+		polygon := make([]LatLon, 0, len(pointValues))
+
+		for _, pointValue := range pointValues {
+			if p, ok := parseLatLon(pointValue); ok {
+				polygon = append(polygon, p)
+			}
+		}
+
+		if len(polygon) >= 3 && PolygonContains(polygon, point) {
+			return true
+		}
+	}
+
+	return false
+}
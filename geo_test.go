@@ -0,0 +1,125 @@
+package rtb
+
+import (
+	"strings"
+	"testing"
+)
+
+var toronto = LatLon{Lat: 43.6532, Lon: -79.3832}
+
+// TestEncodeGeoHash ensures points are encoded to the well known geohash
+// Expected result is ezs42 for 42.6, -5.6
+func TestEncodeGeoHash(t *testing.T) {
+	if EncodeGeoHash(LatLon{Lat: 42.6, Lon: -5.6}, 5) != "ezs42" {
+		t.Fail()
+	}
+}
+
+// TestDistanceInKm ensures great circle distances are calculated correctly
+// Expected result is roughly 505km between Toronto and Montreal
+func TestDistanceInKm(t *testing.T) {
+	distance := DistanceInKm(toronto, LatLon{Lat: 45.5017, Lon: -73.5673})
+
+	if distance < 500 || distance > 510 {
+		t.Fail()
+	}
+}
+
+// TestGeoRadiusTargets ensures a radius is indexed by cells containing its center, and constrained by its exact distance
+// Expected result is the constraint is satisfied inside the radius but not outside
+func TestGeoRadiusTargets(t *testing.T) {
+	targets := GeoRadiusTargets(GeoCircle{Center: toronto, RadiusInKm: 10})
+
+	constraint := targets[len(targets)-1]
+	if constraint.Type != GeoRadius {
+		t.FailNow()
+	}
+
+	center := EncodeGeoHash(toronto, MaxGeoHashPrecision)
+	covered := false
+
+	for _, target := range targets[:len(targets)-1] {
+		if target.Type != GeoHash {
+			t.Fail()
+		}
+
+		if strings.HasPrefix(center, target.Value) {
+			covered = true
+		}
+	}
+
+	if !covered || len(targets) > maxGeoHashCoverCells+1 {
+		t.Fail()
+	}
+
+	inside := (&Geo{Lat: 43.7, Lon: -79.4}).Targeting()
+	outside := (&Geo{Lat: 43.9, Lon: -79.4}).Targeting()
+
+	if !ConstraintSatisfied(constraint, inside, nil) || ConstraintSatisfied(constraint, outside, nil) {
+		t.Fail()
+	}
+}
+
+// TestGeoPolygonTargets ensures a polygon is constrained by its exact shape
+// Expected result is the constraint is satisfied inside the triangle but not in the rest of its bounding box
+func TestGeoPolygonTargets(t *testing.T) {
+	triangle := []LatLon{{Lat: 0.5, Lon: 0.5}, {Lat: 0.5, Lon: 1.5}, {Lat: 1.5, Lon: 0.5}}
+
+	targets := GeoPolygonTargets(triangle)
+	constraint := targets[len(targets)-1]
+
+	inside := (&Geo{Lat: 0.7, Lon: 0.7}).Targeting()
+	outside := (&Geo{Lat: 1.3, Lon: 1.3}).Targeting()
+
+	if !ConstraintSatisfied(constraint, inside, nil) || ConstraintSatisfied(constraint, outside, nil) {
+		t.Fail()
+	}
+}
+
+// TestGeoTargeting ensures a request is targeted by every part of its location
+// Expected result is region, metro, city, zip, geohash prefixes and the point itself
+func TestGeoTargeting(t *testing.T) {
+	g := &Geo{Country: "CAN", Region: "ON", Metro: "535", City: "Toronto", Zip: "M5H", Lat: toronto.Lat, Lon: toronto.Lon}
+
+	counts := make(map[TargetType]int)
+	for _, target := range g.Targeting() {
+		counts[target.Type]++
+	}
+
+	if counts[Country] != 1 || counts[Region] != 1 || counts[Metro] != 1 || counts[City] != 1 || counts[Zip] != 1 || counts[GeoPoint] != 1 {
+		t.Fail()
+	}
+
+	if counts[GeoHash] != MaxGeoHashPrecision {
+		t.Fail()
+	}
+}
+
+// TestGeoTargetingWithinCountry ensures regions, cities and zips are targeted within their country
+// Expected result is the same region code in two countries gives two different targets, and nothing without a country
+func TestGeoTargetingWithinCountry(t *testing.T) {
+	california := Target{Type: Region, Value: "USA:CA"}
+
+	hasTarget := func(g *Geo, target Target) bool {
+		for _, got := range g.Targeting() {
+			if got == target {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if !hasTarget(&Geo{Country: "USA", Region: "CA"}, california) || hasTarget(&Geo{Country: "CAN", Region: "CA"}, california) {
+		t.Fail()
+	}
+
+	if !hasTarget(&Geo{Country: "FRA", City: "Paris", Zip: "75001"}, Target{Type: City, Value: "FRA:Paris"}) ||
+		hasTarget(&Geo{Country: "USA", City: "Paris"}, Target{Type: City, Value: "FRA:Paris"}) {
+		t.Fail()
+	}
+
+	if len((&Geo{Region: "CA", City: "Paris", Zip: "75001"}).Targeting()) != 0 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,158 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncBidLogProducer buffers log items in a bounded ring buffer, and passes them to another producer from a single
+// goroutine, in batches when the producer supports them.
+type AsyncBidLogProducer struct {
+	producer  rtb.BidLogProducer
+	batchSize int
+	policy    rtb.BufferFullPolicy
+
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []*rtb.BidLogItem
+	head     int
+	count    int
+	closed   bool
+
+	done chan struct{}
+
+	logged  int64
+	dropped int64
+	failed  int64
+}
+
+func (l *AsyncBidLogProducer) LogItem(logItem *rtb.BidLogItem) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for l.count == len(l.items) && !l.closed {
+		if l.policy == rtb.DropWhenFull {
+			atomic.AddInt64(&l.dropped, 1)
+			return
+		}
+
+		l.notFull.Wait()
+	}
+
+	if l.closed {
+		atomic.AddInt64(&l.dropped, 1)
+		return
+	}
+
+	l.items[(l.head+l.count)%len(l.items)] = logItem
+	l.count++
+
+	l.notEmpty.Signal()
+}
+
+// nextBatch waits for items and removes up to a batch of them from the buffer. Returns nil once closed and empty.
+func (l *AsyncBidLogProducer) nextBatch() []*rtb.BidLogItem {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for l.count == 0 && !l.closed {
+		l.notEmpty.Wait()
+	}
+
+	if l.count == 0 {
+		return nil
+	}
+
+	size := l.count
+	if size > l.batchSize {
+		size = l.batchSize
+	}
+
+	batch := make([]*rtb.BidLogItem, size)
+
+	for i := range batch {
+		batch[i] = l.items[l.head]
+		l.items[l.head] = nil
+		l.head = (l.head + 1) % len(l.items)
+	}
+
+	l.count -= size
+	l.notFull.Broadcast()
+
+	return batch
+}
+
+// logBatch passes a batch to the wrapped producer. A panicking producer only loses its batch.
+func (l *AsyncBidLogProducer) logBatch(batch []*rtb.BidLogItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddInt64(&l.failed, int64(len(batch)))
+			log.Println("Bid log producer failed:", r)
+		}
+	}()
+
+	if batchProducer, ok := l.producer.(rtb.BatchBidLogProducer); ok {
+		batchProducer.LogItems(batch)
+	} else {
+		for _, logItem := range batch {
+			l.producer.LogItem(logItem)
+		}
+	}
+
+	atomic.AddInt64(&l.logged, int64(len(batch)))
+}
+
+func (l *AsyncBidLogProducer) run() {
+	defer close(l.done)
+
+	for batch := l.nextBatch(); batch != nil; batch = l.nextBatch() {
+		l.logBatch(batch)
+	}
+}
+
+func (l *AsyncBidLogProducer) Stats() rtb.BidLogStats {
+	return rtb.BidLogStats{
+		Logged:  atomic.LoadInt64(&l.logged),
+		Dropped: atomic.LoadInt64(&l.dropped),
+		Failed:  atomic.LoadInt64(&l.failed),
+	}
+}
+
+func (l *AsyncBidLogProducer) Close() {
+	l.mutex.Lock()
+	l.closed = true
+	l.notEmpty.Broadcast()
+	l.notFull.Broadcast()
+	l.mutex.Unlock()
+
+	<-l.done
+}
+
+// NewAsyncBidLogProducer logs to producer in the background. bufferSize items can wait to be logged, and up to batchSize
+// are passed to producer at once. policy decides whether LogItem drops items or waits when the buffer is full.
+func NewAsyncBidLogProducer(producer rtb.BidLogProducer, bufferSize int, batchSize int, policy rtb.BufferFullPolicy) rtb.AsyncBidLogProducer {
+	l := new(AsyncBidLogProducer)
+
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	l.producer = producer
+	l.batchSize = batchSize
+	l.policy = policy
+	l.items = make([]*rtb.BidLogItem, bufferSize)
+	l.notEmpty = sync.NewCond(&l.mutex)
+	l.notFull = sync.NewCond(&l.mutex)
+	l.done = make(chan struct{})
+
+	go l.run()
+
+	return l
+}
@@ -0,0 +1,156 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// recordingBidLogProducer records the batches it's given. Batches wait for release, if it's set.
+type recordingBidLogProducer struct {
+	mutex   sync.Mutex
+	batches [][]*rtb.BidLogItem
+
+	received chan bool
+	release  chan bool
+	panicOn  string
+}
+
+func (p *recordingBidLogProducer) LogItem(logItem *rtb.BidLogItem) {
+	p.LogItems([]*rtb.BidLogItem{logItem})
+}
+
+func (p *recordingBidLogProducer) LogItems(logItems []*rtb.BidLogItem) {
+	if p.received != nil {
+		p.received <- true
+	}
+
+	if p.release != nil {
+		<-p.release
+	}
+
+	for _, logItem := range logItems {
+		if logItem.Domain == p.panicOn {
+			panic("failed to log " + logItem.Domain)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.batches = append(p.batches, logItems)
+}
+
+func (p *recordingBidLogProducer) domains() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	domains := make([]string, 0)
+
+	for _, batch := range p.batches {
+		for _, logItem := range batch {
+			domains = append(domains, logItem.Domain)
+		}
+	}
+
+	return domains
+}
+
+func testLogItem(i int) *rtb.BidLogItem {
+	return &rtb.BidLogItem{Domain: strconv.Itoa(i)}
+}
+
+// TestAsyncBidLogProducerBlocking tests that a blocking producer logs every item, in order and in batches
+// Expected result is every item is logged by the time Close returns, and no batch is larger than the batch size
+func TestAsyncBidLogProducerBlocking(t *testing.T) {
+	producer := new(recordingBidLogProducer)
+	l := NewAsyncBidLogProducer(producer, 4, 3, rtb.BlockWhenFull)
+
+	for i := 0; i < 100; i++ {
+		l.LogItem(testLogItem(i))
+	}
+
+	l.Close()
+
+	domains := producer.domains()
+
+	if len(domains) != 100 {
+		t.FailNow()
+	}
+
+	for i, domain := range domains {
+		if domain != strconv.Itoa(i) {
+			t.Fail()
+		}
+	}
+
+	for _, batch := range producer.batches {
+		if len(batch) > 3 {
+			t.Fail()
+		}
+	}
+
+	if stats := l.Stats(); stats.Logged != 100 || stats.Dropped != 0 || stats.Failed != 0 {
+		t.Fail()
+	}
+
+	l.LogItem(testLogItem(100))
+
+	if l.Stats().Dropped != 1 {
+		t.Fail()
+	}
+}
+
+// TestAsyncBidLogProducerDropping tests that a dropping producer never waits for a slow producer
+// Expected result is items that don't fit in the buffer are dropped and counted, and the rest are logged
+func TestAsyncBidLogProducerDropping(t *testing.T) {
+	producer := new(recordingBidLogProducer)
+	producer.received = make(chan bool, 10)
+	producer.release = make(chan bool, 10)
+
+	l := NewAsyncBidLogProducer(producer, 4, 10, rtb.DropWhenFull)
+
+	// The first item is taken out of the buffer, and stuck in the producer
+	l.LogItem(testLogItem(0))
+	<-producer.received
+
+	for i := 1; i < 8; i++ {
+		l.LogItem(testLogItem(i))
+	}
+
+	if l.Stats().Dropped != 3 {
+		t.Fail()
+	}
+
+	producer.release <- true
+	producer.release <- true
+	l.Close()
+
+	if len(producer.domains()) != 5 {
+		t.Fail()
+	}
+
+	if stats := l.Stats(); stats.Logged != 5 || stats.Dropped != 3 {
+		t.Fail()
+	}
+}
+
+// TestAsyncBidLogProducerFailure tests that a panicking producer doesn't stop logging
+// Expected result is the failed batch is counted, and later items are still logged
+func TestAsyncBidLogProducerFailure(t *testing.T) {
+	producer := new(recordingBidLogProducer)
+	producer.panicOn = "1"
+
+	l := NewAsyncBidLogProducer(producer, 10, 1, rtb.BlockWhenFull)
+
+	for i := 0; i < 3; i++ {
+		l.LogItem(testLogItem(i))
+	}
+
+	l.Close()
+
+	if stats := l.Stats(); stats.Logged != 2 || stats.Failed != 1 || len(producer.domains()) != 2 {
+		t.Fail()
+	}
+}
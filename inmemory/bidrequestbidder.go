@@ -2,6 +2,7 @@ package inmemory
 
 import (
 	"github.com/evandigby/rtb"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -10,84 +11,348 @@ type BidRequestBidder struct {
 	Request                   *rtb.BidRequest
 	CampaignProvider          rtb.CampaignProvider
 	Pacer                     rtb.Pacer
+	BidPriceModifier          rtb.BidPriceModifier
+	ClickPredictor            rtb.Predictor
+	ConversionPredictor       rtb.Predictor
+	MultiImpPolicy            rtb.MultiImpPolicy
+	Auction                   rtb.InternalAuction
+	now                       time.Time
 	dailyBudgetExpirationTime time.Time
+	noBidReason               rtb.NoBidReason
+	auctionResults            []rtb.InternalAuctionResult
+
+	// Campaigns and creatives that have bid on an impression of the request
+	campaignsWithBids map[int64]bool
+	creativesWithBids map[string]bool
+}
+
+func creativeKey(campaignId int64, creative rtb.Creative) string {
+	return strconv.FormatInt(campaignId, 10) + ":" + creative.ID
+}
+
+func targetsKey(targets []rtb.Target) string {
+	key := ""
+
+	for _, target := range targets {
+		key += rtb.TargetKey("|", target)
+	}
+
+	return key
+}
+
+// readCampaigns returns the campaigns matching each impression. Impressions with the same targeting share a single read,
+// so a request whose impressions are alike reads campaigns once rather than once per impression.
+func (b *BidRequestBidder) readCampaigns(requestTargets []rtb.Target) [][]rtb.Campaign {
+	imps := b.Request.Imp
+
+	keys := make([]string, len(imps))
+	impTargets := make(map[string][]rtb.Target)
+	bidFloors := make(map[string]int64)
+
+	for i := range imps {
+		targets := imps[i].Targeting()
+		key := targetsKey(targets)
+		bidFloor := rtb.CpmToMicroCents(imps[i].Bidfloor)
+
+		// Read with the lowest floor of the impressions sharing the read. Each impression's own floor is checked when bidding.
+		if floor, ok := bidFloors[key]; !ok || bidFloor < floor {
+			bidFloors[key] = bidFloor
+		}
+
+		keys[i] = key
+		impTargets[key] = targets
+	}
+
+	reads := make(map[string][]rtb.Campaign, len(impTargets))
+	for key, targets := range impTargets {
+		all := make([]rtb.Target, 0, len(requestTargets)+len(targets))
+		all = append(append(all, requestTargets...), targets...)
+
+		reads[key] = b.CampaignProvider.ReadByTargeting(bidFloors[key], all)
+	}
+
+	campaigns := make([][]rtb.Campaign, len(imps))
+	for i, key := range keys {
+		campaigns[i] = reads[key]
+	}
+
+	return campaigns
+}
+
+// allowedCampaigns returns the campaigns with a creative the publisher allows in the impression, and the creatives each could serve.
+// Order is preserved.
+func (b *BidRequestBidder) allowedCampaigns(imp *rtb.Imp, campaigns []rtb.Campaign) (allowed []rtb.Campaign, creatives map[int64][]rtb.Creative) {
+	allowed = make([]rtb.Campaign, 0, len(campaigns))
+	creatives = make(map[int64][]rtb.Creative, len(campaigns))
+
+	for _, campaign := range campaigns {
+		if campaignCreatives := rtb.AllowedCreatives(b.Request, imp, campaign); len(campaignCreatives) > 0 {
+			allowed = append(allowed, campaign)
+			creatives[campaign.Id()] = campaignCreatives
+		}
+	}
+
+	return allowed, creatives
 }
 
-// highestBidder Returns the highest bidder with available funds.
-// campaigns must be in order from highest CPM to lowest
-// Caller is committed to using the campaign returned by this
-func (b *BidRequestBidder) highestBidder(campaigns []rtb.Campaign) (campaign rtb.Campaign, remainingDailyBudgetInMicroCents int64) {
+// unusedCampaigns applies the multi-imp policy, dropping campaigns and creatives that have already bid on another impression
+// of the request, and returns the creative each remaining campaign will serve. Order is preserved.
+func (b *BidRequestBidder) unusedCampaigns(campaigns []rtb.Campaign, creatives map[int64][]rtb.Creative) (unused []rtb.Campaign, creative map[int64]rtb.Creative) {
+	applies := b.MultiImpPolicy.Applies(b.Request)
+
+	unused = make([]rtb.Campaign, 0, len(campaigns))
+	creative = make(map[int64]rtb.Creative, len(campaigns))
+
 	for _, campaign := range campaigns {
 		id := campaign.Id()
 
-		if b.Pacer == nil || b.Pacer.CanBid(campaign) {
-			bid := campaign.BidCpmInMicroCents()
+		if applies && b.MultiImpPolicy.OneBidPerCampaign && b.campaignsWithBids[id] {
+			continue
+		}
 
-			if remainingDailyBudgetInMicroCents, err := b.CampaignProvider.DebitCampaign(id, rtb.MicroCentsPerImpression(bid), b.dailyBudgetExpirationTime); err == nil {
-				return campaign, remainingDailyBudgetInMicroCents
+		for _, c := range creatives[id] {
+			if applies && b.MultiImpPolicy.UniqueCreatives && b.creativesWithBids[creativeKey(id, c)] {
+				continue
 			}
+
+			unused = append(unused, campaign)
+			creative[id] = c
+			break
 		}
 	}
 
-	return nil, 0
+	return unused, creative
+}
+
+// effectiveCpmInMicroCents returns what the impression is worth to the campaign.
+// CPC and CPA goals are converted using the predicted click through and conversion rates.
+func (b *BidRequestBidder) effectiveCpmInMicroCents(imp *rtb.Imp, campaign rtb.Campaign) int64 {
+	goal := campaign.Goal()
+
+	if goal.Type == rtb.CPMGoal {
+		return campaign.BidCpmInMicroCents()
+	}
+
+	clickThroughRate := float64(0)
+	if b.ClickPredictor != nil {
+		clickThroughRate = b.ClickPredictor.Predict(b.Request, imp, campaign)
+	}
+
+	conversionRate := float64(0)
+	if goal.Type == rtb.CPAGoal && b.ConversionPredictor != nil {
+		conversionRate = b.ConversionPredictor.Predict(b.Request, imp, campaign)
+	}
+
+	return rtb.EffectiveCpmInMicroCents(campaign, clickThroughRate, conversionRate)
 }
 
-// If the bid is nil, the remaining remainingDailyBudgetInMicroCents and campaign id are invalid
-func (b *BidRequestBidder) impressionBid(imp *rtb.Imp, userTargets []rtb.Target) (bid *rtb.Bid, remainingDailyBudgetInMicroCents int64) {
-	targets := append(userTargets, imp.Targeting()...)
+// rankByEffectiveCpm orders campaigns from highest eCPM to lowest, and returns the eCPM of each campaign
+// Campaigns with the same eCPM keep the order they were given in
+func (b *BidRequestBidder) rankByEffectiveCpm(imp *rtb.Imp, campaigns []rtb.Campaign) (ranked []rtb.Campaign, effectiveCpmsInMicroCents map[int64]int64) {
+	effectiveCpmsInMicroCents = make(map[int64]int64, len(campaigns))
 
-	campaigns := b.CampaignProvider.ReadByTargeting(rtb.CpmToMicroCents(imp.Bidfloor), targets)
+	for _, campaign := range campaigns {
+		effectiveCpmsInMicroCents[campaign.Id()] = b.effectiveCpmInMicroCents(imp, campaign)
+	}
+
+	ranked = make([]rtb.Campaign, len(campaigns))
+	copy(ranked, campaigns)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return effectiveCpmsInMicroCents[ranked[i].Id()] > effectiveCpmsInMicroCents[ranked[j].Id()]
+	})
+
+	return ranked, effectiveCpmsInMicroCents
+}
+
+// bidCpmInMicroCents returns the CPM the campaign will bid on the impression
+func (b *BidRequestBidder) bidCpmInMicroCents(imp *rtb.Imp, campaign rtb.Campaign, effectiveCpmInMicroCents int64) int64 {
+	bid := effectiveCpmInMicroCents
+
+	if b.BidPriceModifier != nil {
+		bid = b.BidPriceModifier.ModifyBidCpmInMicroCents(b.Request, imp, campaign, bid)
+	}
+
+	return bid
+}
+
+// auctionBids returns the bids of the campaigns that bid at least the floor.
+// campaigns must be in order from highest eCPM to lowest. The pacer isn't asked here, since asking spends pacing allowance.
+func (b *BidRequestBidder) auctionBids(imp *rtb.Imp, campaigns []rtb.Campaign, effectiveCpmsInMicroCents map[int64]int64) []rtb.AuctionBid {
+	private := imp.Pmp != nil && imp.Pmp.Private == 1
+	bids := make([]rtb.AuctionBid, 0, len(campaigns))
+
+	for _, campaign := range campaigns {
+		bid := rtb.AuctionBid{Campaign: campaign, Priority: campaign.Priority(), BidFloorInMicroCents: rtb.CpmToMicroCents(imp.Bidfloor)}
+
+		dealId := ""
+		if targets := campaign.Targets(); targets != nil {
+			dealId = (*targets)[rtb.DealID]
+		}
+
+		if deal, ok := rtb.ImpDeal(imp, dealId); ok {
+			bid.DealID = deal.ID
+			if deal.Bidfloor != 0 {
+				bid.BidFloorInMicroCents = rtb.CpmToMicroCents(deal.Bidfloor)
+			}
+		} else if private {
+			// Only deals can bid on private impressions
+			continue
+		} else if bid.Priority == rtb.PriorityPrivateMarketplace {
+			bid.Priority = rtb.PriorityOpen
+		}
+
+		bid.BidCpmInMicroCents = b.bidCpmInMicroCents(imp, campaign, effectiveCpmsInMicroCents[campaign.Id()])
+
+		// The price modifier may have taken the bid below the floor
+		if bid.BidCpmInMicroCents <= 0 || bid.BidCpmInMicroCents < bid.BidFloorInMicroCents {
+			continue
+		}
+
+		bids = append(bids, bid)
+	}
+
+	return bids
+}
+
+// auctionWinner runs the internal auction and returns the winner the pacer admits with available funds, and the price it bids.
+// If the pacer rejects the winner, or its budget is spent by the time it is debited, the auction is run again without it.
+// Only winners are put to the pacer, so campaigns that lose the auction don't spend their pacing allowance.
+// Caller is committed to using the bid returned by this
+func (b *BidRequestBidder) auctionWinner(imp *rtb.Imp, bids []rtb.AuctionBid) (winner *rtb.AuctionBid, result rtb.InternalAuctionResult, remainingDailyBudgetInMicroCents int64) {
+	auction := b.Auction
+	if auction == nil {
+		auction = highestBidAuction{}
+	}
+
+	bidders := len(bids)
+
+	for len(bids) > 0 {
+		outcome := auction.Run(bids)
+		if outcome.Winner < 0 {
+			break
+		}
+
+		w := bids[outcome.Winner]
+
+		if b.Pacer != nil && !b.Pacer.CanBid(w.Campaign) {
+			bids = append(bids[:outcome.Winner:outcome.Winner], bids[outcome.Winner+1:]...)
+			continue
+		}
+
+		remainingDailyBudgetInMicroCents, err := b.CampaignProvider.DebitCampaign(w.Campaign.Id(), rtb.MicroCentsPerImpression(outcome.PriceCpmInMicroCents), b.dailyBudgetExpirationTime)
+		if err == nil {
+			result = rtb.InternalAuctionResult{ImpID: imp.ID, CampaignId: w.Campaign.Id(), Priority: w.Priority, DealID: w.DealID, BidCpmInMicroCents: w.BidCpmInMicroCents, PriceCpmInMicroCents: outcome.PriceCpmInMicroCents, Bidders: bidders}
+
+			if outcome.RunnerUp >= 0 {
+				result.RunnerUpCampaignId = bids[outcome.RunnerUp].Campaign.Id()
+				result.RunnerUpCpmInMicroCents = bids[outcome.RunnerUp].BidCpmInMicroCents
+			}
+
+			return &w, result, remainingDailyBudgetInMicroCents
+		}
+
+		bids = append(bids[:outcome.Winner:outcome.Winner], bids[outcome.Winner+1:]...)
+	}
+
+	return nil, result, 0
+}
+
+// If the bid is nil, the remaining remainingDailyBudgetInMicroCents and campaign id are invalid, and noBidReason explains why
+func (b *BidRequestBidder) impressionBid(imp *rtb.Imp, campaigns []rtb.Campaign) (bid *rtb.Bid, remainingDailyBudgetInMicroCents int64, noBidReason rtb.NoBidReason) {
+	if len(campaigns) == 0 {
+		return nil, 0, rtb.NoBidNoCampaigns
+	}
+
+	// Never bid a creative the publisher has blocked
+	campaigns, allowedCreatives := b.allowedCampaigns(imp, campaigns)
+	if len(campaigns) == 0 {
+		return nil, 0, rtb.NoBidCreativesBlocked
+	}
+
+	campaigns, creatives := b.unusedCampaigns(campaigns, allowedCreatives)
 	if len(campaigns) == 0 {
-		return nil, 0
+		return nil, 0, rtb.NoBidMultiImpPolicy
 	}
 
+	campaigns, effectiveCpmsInMicroCents := b.rankByEffectiveCpm(imp, campaigns)
+
 	// Returns nil if none of the campaigns have available budget at the time of the call
 	// We need to double check this, in case the budget is spent by the time we've decided to bid.
 	// To be fair, we're committed to using the result of this call.
-	campaign, remainingDailyBudgetInMicroCents := b.highestBidder(campaigns)
+	winner, result, remainingDailyBudgetInMicroCents := b.auctionWinner(imp, b.auctionBids(imp, campaigns, effectiveCpmsInMicroCents))
 
 	// Either the pacer rejected them all, or none of them had available remainingDailyBudgetInMicroCents
-	if campaign == nil {
-		return nil, 0
+	if winner == nil {
+		return nil, 0, rtb.NoBidNoBudget
 	}
 
+	campaign := winner.Campaign
+	creative := creatives[campaign.Id()]
+
+	b.auctionResults = append(b.auctionResults, result)
+
+	b.campaignsWithBids[campaign.Id()] = true
+	b.creativesWithBids[creativeKey(campaign.Id(), creative)] = true
+
 	bid = new(rtb.Bid)
-	bid.Price = rtb.MicroCentsToCpm(campaign.BidCpmInMicroCents())
+	bid.Price = rtb.MicroCentsToCpm(result.PriceCpmInMicroCents)
 	bid.Impid = imp.ID
+	bid.Dealid = winner.DealID
 	bid.Cid = strconv.FormatInt(campaign.Id(), 10)
+	bid.Crid = creative.ID
+	bid.Adm = creative.Adm
+	bid.Adomain = creative.Adomain
+
+	for _, attr := range creative.Attr {
+		bid.Attr = append(bid.Attr, float64(attr))
+	}
 
 	// Stub the rest of the fields
 	bid.ID = "stub"
 	bid.Adid = "stub"
-	bid.Adm = "<span>stub</span>"
-	bid.Adomain = []string{"stub.go2mobi.com"}
-	bid.Crid = "stub"
 
-	return bid, remainingDailyBudgetInMicroCents
+	return bid, remainingDailyBudgetInMicroCents, rtb.NoBidReasonNone
 }
 
 // BidResponse returns nil if there is no bid
 func (b *BidRequestBidder) Bid() (response *rtb.BidResponse, campaignRemainingDailyBudgetsInMicroCents map[string]int64, err error) {
-	targets := b.Request.Targeting()
+	// Scheduled campaigns are checked against the time the bidder was created for
+	targets := append(b.Request.Targeting(), rtb.RequestTimeTarget(b.now))
 
 	campaignRemainingDailyBudgetsInMicroCents = make(map[string]int64)
 	// Allocate up to the amount of impressions
 	bids := make([]rtb.Bid, 0, len(b.Request.Imp))
 
-	for _, imp := range b.Request.Imp {
-		ibid, remainingDailyBudgetInMicroCents := b.impressionBid(&imp, targets)
+	b.noBidReason = rtb.NoBidReasonNone
+	b.campaignsWithBids = make(map[int64]bool)
+	b.creativesWithBids = make(map[string]bool)
+	b.auctionResults = nil
+
+	campaigns := b.readCampaigns(targets)
+
+	for i := range b.Request.Imp {
+		ibid, remainingDailyBudgetInMicroCents, noBidReason := b.impressionBid(&b.Request.Imp[i], campaigns[i])
 
 		if ibid != nil {
 			bids = append(bids, *ibid)
 			campaignRemainingDailyBudgetsInMicroCents[ibid.Cid] = remainingDailyBudgetInMicroCents
+		} else if noBidReason.Further(b.noBidReason) {
+			b.noBidReason = noBidReason
 		}
 	}
 
 	// No bids
 	if len(bids) <= 0 {
+		if b.noBidReason == rtb.NoBidReasonNone {
+			b.noBidReason = rtb.NoBidNoCampaigns
+		}
+
 		return nil, campaignRemainingDailyBudgetsInMicroCents, nil
 	}
 
+	b.noBidReason = rtb.NoBidReasonNone
+
 	// We have bids to submit! Build a response
 	response = new(rtb.BidResponse)
 
@@ -99,12 +364,45 @@ func (b *BidRequestBidder) Bid() (response *rtb.BidResponse, campaignRemainingDa
 	return response, campaignRemainingDailyBudgetsInMicroCents, nil
 }
 
+func (b *BidRequestBidder) NoBidReason() rtb.NoBidReason {
+	return b.noBidReason
+}
+
+func (b *BidRequestBidder) AuctionResults() []rtb.InternalAuctionResult {
+	return b.auctionResults
+}
+
+// BidRequestBidderOptions holds the optional stages of a BidRequestBidder. The zero value bids every campaign with its CPM,
+// shares impressions freely between campaigns, and bids the highest bidder's price.
+type BidRequestBidderOptions struct {
+	Pacer            rtb.Pacer
+	BidPriceModifier rtb.BidPriceModifier
+	// Campaigns with CPC or CPA goals will not bid without the predictors they need
+	ClickPredictor      rtb.Predictor
+	ConversionPredictor rtb.Predictor
+	// Decides how impressions of a request with several impressions are shared between campaigns
+	MultiImpPolicy rtb.MultiImpPolicy
+	// Picks which campaign bids on each impression and its price. A nil auction bids the highest bidder's price.
+	Auction rtb.InternalAuction
+}
+
 func NewBidRequestBidder(r *rtb.BidRequest, cp rtb.CampaignProvider, pacer rtb.Pacer, now time.Time) rtb.Bidder {
+	return NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{Pacer: pacer}, now)
+}
+
+// NewBidRequestBidderWithOptions creates a bidder for the request with the optional stages in options
+func NewBidRequestBidderWithOptions(r *rtb.BidRequest, cp rtb.CampaignProvider, options BidRequestBidderOptions, now time.Time) rtb.Bidder {
 	b := new(BidRequestBidder)
 
 	b.Request = r
 	b.CampaignProvider = cp
-	b.Pacer = pacer
+	b.Pacer = options.Pacer
+	b.BidPriceModifier = options.BidPriceModifier
+	b.ClickPredictor = options.ClickPredictor
+	b.ConversionPredictor = options.ConversionPredictor
+	b.MultiImpPolicy = options.MultiImpPolicy
+	b.Auction = options.Auction
+	b.now = now
 
 	// Budgets expire on calendar days (not 24 hour periods)
 	b.dailyBudgetExpirationTime = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
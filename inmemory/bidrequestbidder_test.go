@@ -289,3 +289,204 @@ func TestBiddingPacerFalse(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+// countingPacer admits the campaigns in canBid, and records every campaign it was asked about
+type countingPacer struct {
+	canBid map[int64]bool
+	asked  []int64
+}
+
+func (p *countingPacer) CanBid(campaign rtb.Campaign) bool {
+	p.asked = append(p.asked, campaign.Id())
+	return p.canBid[campaign.Id()]
+}
+
+// TestBiddingPacerOnlyChargesWinner tests that the pacer is only asked about the campaign about to be debited
+// Expected result is losing campaigns are never put to the pacer, and a campaign the pacer rejects gives way to the next best
+func TestBiddingPacerOnlyChargesWinner(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = []rtb.Imp{rtb.Imp{ID: "1"}}
+
+	high := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(3), rtb.DollarsToMicroCents(1), nil)
+	middle := mocks.NewMockCampaign(101, rtb.CpmToMicroCents(2), rtb.DollarsToMicroCents(1), nil)
+	low := mocks.NewMockCampaign(102, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(1), nil)
+
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{high, middle, low}, map[int64]int64{100: 1, 101: 1, 102: 1}, map[int64]error{}, nil)
+
+	pacer := &countingPacer{canBid: map[int64]bool{100: true, 101: true, 102: true}}
+
+	response, _, _ := NewBidRequestBidder(r, cp, pacer, time.Now().UTC()).Bid()
+	if response == nil || response.Seatbid[0].Bid[0].Cid != "100" {
+		t.Fail()
+	}
+
+	if len(pacer.asked) != 1 || pacer.asked[0] != 100 {
+		t.Fail()
+	}
+
+	pacer = &countingPacer{canBid: map[int64]bool{100: false, 101: true, 102: true}}
+
+	response, _, _ = NewBidRequestBidder(r, cp, pacer, time.Now().UTC()).Bid()
+	if response == nil || response.Seatbid[0].Bid[0].Cid != "101" {
+		t.Fail()
+	}
+
+	if len(pacer.asked) != 2 || pacer.asked[0] != 100 || pacer.asked[1] != 101 {
+		t.Fail()
+	}
+}
+
+// TestBiddingBlockedCreatives tests that the bidder skips campaigns whose creatives the publisher has blocked
+// Expected result is the lower bidding campaign wins with its own creative, and no bid with a reason once both are blocked
+func TestBiddingBlockedCreatives(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = []rtb.Imp{rtb.Imp{ID: "1"}}
+	r.Badv = []string{"blocked.com"}
+
+	blocked := mocks.NewMockCreativeCampaign(100, rtb.CpmToMicroCents(2), rtb.DollarsToMicroCents(1), nil, []rtb.Creative{rtb.Creative{ID: "a", Adomain: []string{"ads.blocked.com"}}})
+	allowed := mocks.NewMockCreativeCampaign(101, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(1), nil, []rtb.Creative{rtb.Creative{ID: "b", Adomain: []string{"allowed.com"}, Cat: []string{"IAB1"}}})
+
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{blocked, allowed}, map[int64]int64{100: rtb.DollarsToMicroCents(1), 101: rtb.DollarsToMicroCents(1)}, map[int64]error{}, nil)
+
+	b := NewBidRequestBidder(r, cp, nil, time.Now().UTC())
+
+	response, _, _ := b.Bid()
+	if response == nil {
+		t.FailNow()
+	}
+
+	if response.Seatbid[0].Bid[0].Cid != "101" || response.Seatbid[0].Bid[0].Crid != "b" || b.NoBidReason() != rtb.NoBidReasonNone {
+		t.Fail()
+	}
+
+	r.Bcat = []string{"IAB1"}
+
+	b = NewBidRequestBidder(r, cp, nil, time.Now().UTC())
+
+	response, _, _ = b.Bid()
+	if response != nil || b.NoBidReason() != rtb.NoBidCreativesBlocked {
+		t.Fail()
+	}
+}
+
+type countingCampaignProvider struct {
+	rtb.CampaignProvider
+	reads int
+}
+
+func (cp *countingCampaignProvider) ReadByTargeting(bidFloorInMicroCents int64, targets []rtb.Target) []rtb.Campaign {
+	cp.reads++
+	return cp.CampaignProvider.ReadByTargeting(bidFloorInMicroCents, targets)
+}
+
+// TestBiddingOneBidPerCampaign tests that a campaign only wins one impression of a request when the policy says so
+// Expected result is each impression goes to a different campaign, and campaigns are read once for the alike impressions
+func TestBiddingOneBidPerCampaign(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = []rtb.Imp{rtb.Imp{ID: "1"}, rtb.Imp{ID: "2"}}
+
+	first := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(2), rtb.DollarsToMicroCents(1), nil)
+	second := mocks.NewMockCampaign(101, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(1), nil)
+
+	cp := &countingCampaignProvider{CampaignProvider: mocks.NewMockCampaignProvider([]rtb.Campaign{first, second}, map[int64]int64{100: 1, 101: 1}, map[int64]error{}, nil)}
+
+	b := NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{MultiImpPolicy: rtb.MultiImpPolicy{OneBidPerCampaign: true}}, time.Now().UTC())
+
+	response, _, _ := b.Bid()
+	if response == nil || len(response.Seatbid[0].Bid) != 2 {
+		t.FailNow()
+	}
+
+	if response.Seatbid[0].Bid[0].Cid != "100" || response.Seatbid[0].Bid[1].Cid != "101" {
+		t.Fail()
+	}
+
+	if cp.reads != 1 {
+		t.Fail()
+	}
+}
+
+// TestBiddingUniqueCreatives tests that the same creative isn't bid on two impressions of a page
+// Expected result is the campaign bids both of its creatives, unless the policy only applies to allimps requests
+func TestBiddingUniqueCreatives(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = []rtb.Imp{rtb.Imp{ID: "1"}, rtb.Imp{ID: "2"}, rtb.Imp{ID: "3"}}
+
+	campaign := mocks.NewMockCreativeCampaign(100, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(1), nil, []rtb.Creative{rtb.Creative{ID: "a"}, rtb.Creative{ID: "b"}})
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{campaign}, map[int64]int64{100: 1}, map[int64]error{}, nil)
+
+	b := NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{MultiImpPolicy: rtb.MultiImpPolicy{UniqueCreatives: true}}, time.Now().UTC())
+
+	response, _, _ := b.Bid()
+	if response == nil || len(response.Seatbid[0].Bid) != 2 {
+		t.FailNow()
+	}
+
+	if response.Seatbid[0].Bid[0].Crid != "a" || response.Seatbid[0].Bid[1].Crid != "b" || b.NoBidReason() != rtb.NoBidReasonNone {
+		t.Fail()
+	}
+
+	b = NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{MultiImpPolicy: rtb.MultiImpPolicy{UniqueCreatives: true, AllImpsOnly: true}}, time.Now().UTC())
+
+	response, _, _ = b.Bid()
+	if response == nil || len(response.Seatbid[0].Bid) != 3 {
+		t.Fail()
+	}
+}
+
+// TestBiddingInternalAuction tests that our campaigns are ranked by priority tier, and the winner bids the second price
+// Expected result is the deal campaign wins over a higher open bid at the deal floor, and the open campaigns pay the runner up's price
+func TestBiddingInternalAuction(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = []rtb.Imp{rtb.Imp{ID: "1", Bidfloor: 0.5, Pmp: &rtb.Pmp{Deals: []rtb.Deal{rtb.Deal{ID: "deal", Bidfloor: 1.5}}}}}
+
+	dealTargets := map[rtb.TargetType]string{rtb.DealID: "deal"}
+	deal := mocks.NewMockPriorityCampaign(100, rtb.CpmToMicroCents(2), rtb.DollarsToMicroCents(1), &dealTargets, rtb.PriorityPrivateMarketplace)
+	high := mocks.NewMockCampaign(101, rtb.CpmToMicroCents(4), rtb.DollarsToMicroCents(1), nil)
+	low := mocks.NewMockCampaign(102, rtb.CpmToMicroCents(3), rtb.DollarsToMicroCents(1), nil)
+
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{deal, high, low}, map[int64]int64{100: 1, 101: 1, 102: 1}, map[int64]error{}, nil)
+
+	b := NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{Auction: NewSecondPriceAuction(rtb.CpmToMicroCents(0.01))}, time.Now().UTC())
+
+	response, _, _ := b.Bid()
+	if response == nil || len(response.Seatbid[0].Bid) != 1 {
+		t.FailNow()
+	}
+
+	bid := response.Seatbid[0].Bid[0]
+	if bid.Cid != "100" || bid.Dealid != "deal" || bid.Price != 2 {
+		t.Fail()
+	}
+
+	results := b.AuctionResults()
+	if len(results) != 1 || results[0].Priority != rtb.PriorityPrivateMarketplace || results[0].RunnerUpCampaignId != 0 || results[0].Bidders != 3 {
+		t.Fail()
+	}
+
+	// Without the deal on the impression, the deal campaign bids in the open auction
+	r.Imp[0].Pmp = nil
+
+	response, _, _ = b.Bid()
+	if response == nil || len(response.Seatbid[0].Bid) != 1 {
+		t.FailNow()
+	}
+
+	bid = response.Seatbid[0].Bid[0]
+	if bid.Cid != "101" || bid.Dealid != "" || bid.Price != rtb.MicroCentsToCpm(rtb.CpmToMicroCents(3)+rtb.CpmToMicroCents(0.01)) {
+		t.Fail()
+	}
+
+	results = b.AuctionResults()
+	if len(results) != 1 || results[0].RunnerUpCampaignId != 102 || results[0].RunnerUpCpmInMicroCents != rtb.CpmToMicroCents(3) {
+		t.Fail()
+	}
+
+	// Only deals bid on private impressions
+	r.Imp[0].Pmp = &rtb.Pmp{Private: 1, Deals: []rtb.Deal{rtb.Deal{ID: "other"}}}
+
+	response, _, _ = b.Bid()
+	if response != nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,67 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+)
+
+// SurplusBidShader shades bids in first price auctions down to the price that maximizes expected surplus:
+// (value - price) * probability of winning at that price.
+// Second price auctions, and placements without enough logged outcomes, are bid at full price.
+type SurplusBidShader struct {
+	estimator       rtb.WinRateEstimator
+	minObservations int64
+	steps           int64
+}
+
+func (s *SurplusBidShader) ModifyBidCpmInMicroCents(request *rtb.BidRequest, imp *rtb.Imp, campaign rtb.Campaign, bidCpmInMicroCents int64) int64 {
+	if request == nil || imp == nil || request.At != rtb.FirstPriceAuction {
+		return bidCpmInMicroCents
+	}
+
+	key := rtb.AuctionOutcomeKey(request, imp)
+
+	if _, observations := s.estimator.WinRate(key, bidCpmInMicroCents); observations < s.minObservations {
+		return bidCpmInMicroCents
+	}
+
+	floor := rtb.CpmToMicroCents(imp.Bidfloor)
+	if floor >= bidCpmInMicroCents {
+		return bidCpmInMicroCents
+	}
+
+	best := bidCpmInMicroCents
+	bestSurplus := float64(0)
+
+	step := (bidCpmInMicroCents - floor) / s.steps
+	if step == 0 {
+		step = 1
+	}
+
+	for price := floor; price <= bidCpmInMicroCents; price += step {
+		winRate, _ := s.estimator.WinRate(key, price)
+		surplus := float64(bidCpmInMicroCents-price) * winRate
+
+		if surplus > bestSurplus {
+			best = price
+			bestSurplus = surplus
+		}
+	}
+
+	return best
+}
+
+// NewSurplusBidShader creates a bid shader that tries steps prices between the floor and the full bid,
+// once at least minObservations outcomes have been recorded for the placement and size.
+func NewSurplusBidShader(estimator rtb.WinRateEstimator, minObservations int64, steps int64) rtb.BidPriceModifier {
+	s := new(SurplusBidShader)
+
+	s.estimator = estimator
+	s.minObservations = minObservations
+
+	if steps < 1 {
+		steps = 1
+	}
+	s.steps = steps
+
+	return s
+}
@@ -0,0 +1,167 @@
+package inmemory
+
+import (
+	"bytes"
+	"github.com/evandigby/rtb"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func shadingRequest(at float64) (*rtb.BidRequest, *rtb.Imp) {
+	r := new(rtb.BidRequest)
+	r.At = at
+	r.App = &rtb.App{Name: "Words With Friends 2 iPad", Bundle: "com.zynga.wwf2.free"}
+	r.Imp = []rtb.Imp{rtb.Imp{Banner: &rtb.Banner{W: 320, H: 50}}}
+
+	return r, &r.Imp[0]
+}
+
+// recordLinearWinRate records outcomes where a bid of x dollars CPM wins x of the time, up to $1
+func recordLinearWinRate(e rtb.WinRateEstimator, key string) {
+	for cents := int64(5); cents < 100; cents += 10 {
+		bid := rtb.CpmToMicroCents(float64(cents) / 100)
+
+		for i := int64(0); i < 100; i++ {
+			e.RecordOutcome(key, bid, i < cents)
+		}
+	}
+}
+
+// TestHistogramWinRateEstimatorMonotonic tests that win rates never decrease as the price increases
+// Expected result is the out of order bucket pooled with its neighbour
+func TestHistogramWinRateEstimatorMonotonic(t *testing.T) {
+	e := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+
+	outcomes := "key,10000000,false\nkey,10000000,true\n" +
+		"key,20000000,false\nkey,20000000,false\n" +
+		"key,30000000,true\nkey,30000000,true\n"
+
+	if err := LoadAuctionOutcomes(strings.NewReader(outcomes), e); err != nil {
+		t.FailNow()
+	}
+
+	low, observations := e.WinRate("key", rtb.CpmToMicroCents(0.1))
+	middle, _ := e.WinRate("key", rtb.CpmToMicroCents(0.2))
+	high, _ := e.WinRate("key", rtb.CpmToMicroCents(0.3))
+
+	if observations != 6 {
+		t.Fail()
+	}
+
+	if low != 0.25 || middle != 0.25 || high != 1 {
+		t.Fail()
+	}
+}
+
+// TestHistogramWinRateEstimatorRecordAfterWinRate tests that outcomes recorded after a win rate was estimated are used
+// Expected result is the second estimate includes the new outcomes
+func TestHistogramWinRateEstimatorRecordAfterWinRate(t *testing.T) {
+	e := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+	bid := rtb.CpmToMicroCents(0.1)
+
+	e.RecordOutcome("key", bid, false)
+
+	if winRate, observations := e.WinRate("key", bid); winRate != 0 || observations != 1 {
+		t.Fail()
+	}
+
+	e.RecordOutcome("key", bid, true)
+
+	if winRate, observations := e.WinRate("key", bid); winRate != 0.5 || observations != 2 {
+		t.Fail()
+	}
+
+	if winRate, observations := e.WinRate("other", bid); winRate != 0 || observations != 0 {
+		t.Fail()
+	}
+}
+
+// TestSurplusBidShaderFirstPrice tests that a first price bid is shaded to the price maximizing expected surplus
+// Expected result is close to half the value for a linear win rate curve
+func TestSurplusBidShaderFirstPrice(t *testing.T) {
+	r, imp := shadingRequest(rtb.FirstPriceAuction)
+
+	e := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+	recordLinearWinRate(e, rtb.AuctionOutcomeKey(r, imp))
+
+	s := NewSurplusBidShader(e, 100, 100)
+
+	bid := s.ModifyBidCpmInMicroCents(r, imp, nil, rtb.CpmToMicroCents(1))
+
+	if bid < rtb.CpmToMicroCents(0.4) || bid > rtb.CpmToMicroCents(0.6) {
+		t.Fail()
+	}
+}
+
+// TestSurplusBidShaderSecondPrice tests that second price bids are not shaded
+// Expected result is the full bid
+func TestSurplusBidShaderSecondPrice(t *testing.T) {
+	r, imp := shadingRequest(rtb.SecondPriceAuction)
+
+	e := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+	recordLinearWinRate(e, rtb.AuctionOutcomeKey(r, imp))
+
+	s := NewSurplusBidShader(e, 100, 100)
+
+	if s.ModifyBidCpmInMicroCents(r, imp, nil, rtb.CpmToMicroCents(1)) != rtb.CpmToMicroCents(1) {
+		t.Fail()
+	}
+}
+
+// TestSurplusBidShaderThinData tests that placements without enough outcomes are bid at full price
+// Expected result is the full bid
+func TestSurplusBidShaderThinData(t *testing.T) {
+	r, imp := shadingRequest(rtb.FirstPriceAuction)
+
+	e := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+	e.RecordOutcome(rtb.AuctionOutcomeKey(r, imp), rtb.CpmToMicroCents(0.1), true)
+
+	s := NewSurplusBidShader(e, 100, 100)
+
+	if s.ModifyBidCpmInMicroCents(r, imp, nil, rtb.CpmToMicroCents(1)) != rtb.CpmToMicroCents(1) {
+		t.Fail()
+	}
+}
+
+// TestAuctionOutcomesFromLogs tests that the bidder's own bids are joined with the win log
+// Expected result is every bid is an outcome at its price, won if it's in the win log, recorded directly or through the CSV
+func TestAuctionOutcomesFromLogs(t *testing.T) {
+	bidLog := make([]*rtb.BidLogItem, 0, 4)
+
+	for i := 0; i < 4; i++ {
+		r, imp := shadingRequest(rtb.FirstPriceAuction)
+		r.ID = strconv.Itoa(i)
+		imp.ID = "1"
+
+		response := &rtb.BidResponse{Seatbid: []rtb.Seatbid{rtb.Seatbid{Bid: []rtb.Bid{rtb.Bid{Impid: "1", Price: 0.5}}}}}
+
+		bidLog = append(bidLog, &rtb.BidLogItem{BidRequest: r, BidResponse: response})
+	}
+
+	// Requests without a bid aren't outcomes
+	bidLog = append(bidLog, &rtb.BidLogItem{BidRequest: bidLog[0].BidRequest})
+
+	wins := map[string]bool{ImpressionKey("0", "1"): true}
+	r, imp := shadingRequest(rtb.FirstPriceAuction)
+	key := rtb.AuctionOutcomeKey(r, imp)
+
+	recorded := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+	RecordAuctionOutcomesFromLogs(recorded, bidLog, wins)
+
+	var csv bytes.Buffer
+	if err := WriteAuctionOutcomes(&csv, bidLog, wins); err != nil {
+		t.FailNow()
+	}
+
+	loaded := NewHistogramWinRateEstimator(rtb.CpmToMicroCents(0.1))
+	if err := LoadAuctionOutcomes(&csv, loaded); err != nil {
+		t.FailNow()
+	}
+
+	for _, e := range []rtb.WinRateEstimator{recorded, loaded} {
+		if winRate, observations := e.WinRate(key, rtb.CpmToMicroCents(0.5)); winRate != 0.25 || observations != 4 {
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"sync"
+	"time"
+)
+
+// CachedCampaignProvider answers targeting from a local copy of another campaign provider's campaigns, so a request
+// doesn't cost any calls to the source. Changes are written through to the source and announced to other caches.
+// Budgets are still debited through the banker, so they stay shared with other bidders.
+type CachedCampaignProvider struct {
+	source   rtb.CampaignProvider
+	banker   rtb.Banker
+	notifier rtb.CampaignChangeNotifier
+
+	// Serializes loads from the source, so an older load never replaces a newer one
+	loadMutex sync.Mutex
+
+	snapshotMutex sync.RWMutex
+	snapshot      *InMemoryCampaignProvider
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// cachedCopy copies a campaign from the source, so it isn't read from the source again
+func cachedCopy(campaign rtb.Campaign) *InMemoryCampaign {
+	c := new(InMemoryCampaign)
+
+	c.campaignId = campaign.Id()
+	c.bidCpmInMicroCents = campaign.BidCpmInMicroCents()
+	c.dailyBudgetInMicroCents = campaign.DailyBudgetInMicroCents()
+	c.targets = campaign.TargetList()
+	c.goal = campaign.Goal()
+	c.creatives = campaign.Creatives()
+	c.priority = campaign.Priority()
+	c.status = campaign.Status()
+
+	return c
+}
+
+func (cp *CachedCampaignProvider) current() *InMemoryCampaignProvider {
+	cp.snapshotMutex.RLock()
+	defer cp.snapshotMutex.RUnlock()
+
+	return cp.snapshot
+}
+
+func (cp *CachedCampaignProvider) isClosed() bool {
+	select {
+	case <-cp.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cp *CachedCampaignProvider) Refresh() {
+	cp.loadMutex.Lock()
+	defer cp.loadMutex.Unlock()
+
+	snapshot := newInMemoryCampaignProvider(cp.banker, cp.source.Lists())
+
+	for _, id := range cp.source.ListCampaigns() {
+		if campaign := cp.source.ReadCampaign(id); campaign != nil {
+			snapshot.put(cachedCopy(campaign))
+		}
+	}
+
+	cp.snapshotMutex.Lock()
+	cp.snapshot = snapshot
+	cp.snapshotMutex.Unlock()
+}
+
+// Invalidate reloads one campaign. An id of zero reloads every campaign.
+func (cp *CachedCampaignProvider) Invalidate(campaignId int64) {
+	if campaignId == 0 {
+		cp.Refresh()
+		return
+	}
+
+	cp.loadMutex.Lock()
+	defer cp.loadMutex.Unlock()
+
+	campaign := cp.source.ReadCampaign(campaignId)
+
+	// Some sources, like redis, read an id they don't have as an active campaign with no settings, so unlisted campaigns
+	// aren't cached. Archived campaigns are unlisted too, and are read from the source.
+	if campaign == nil || campaign.Status() == rtb.CampaignArchived || !cp.source.IsCampaignListed(campaignId) {
+		cp.current().remove(campaignId)
+	} else {
+		cp.current().put(cachedCopy(campaign))
+	}
+}
+
+// changed reloads a campaign this provider changed, and lets other caches know
+func (cp *CachedCampaignProvider) changed(campaignId int64) {
+	cp.Invalidate(campaignId)
+
+	if cp.notifier != nil {
+		cp.notifier.NotifyChanged(campaignId)
+	}
+}
+
+func (cp *CachedCampaignProvider) Close() {
+	cp.closeOnce.Do(func() { close(cp.closed) })
+}
+
+func (cp *CachedCampaignProvider) ReadByTargeting(bidFloorInMicroCents int64, targets []rtb.Target) []rtb.Campaign {
+	return cp.current().ReadByTargeting(bidFloorInMicroCents, targets)
+}
+
+func (cp *CachedCampaignProvider) DebitCampaign(campaignId int64, amountInMicroCents int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCents int64, err error) {
+	return cp.current().DebitCampaign(campaignId, amountInMicroCents, dailyBudgetExpiration)
+}
+
+func (cp *CachedCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	campaign := cp.source.CreateCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets)
+	cp.changed(campaignId)
+
+	return campaign
+}
+
+func (cp *CachedCampaignProvider) CreatePausedCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	campaign := cp.source.CreatePausedCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets)
+	cp.changed(campaignId)
+
+	return campaign
+}
+
+func (cp *CachedCampaignProvider) UpdateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	campaign := cp.source.UpdateCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets)
+	cp.changed(campaignId)
+
+	return campaign
+}
+
+func (cp *CachedCampaignProvider) PauseCampaign(campaignId int64) {
+	cp.source.PauseCampaign(campaignId)
+	cp.changed(campaignId)
+}
+
+func (cp *CachedCampaignProvider) ResumeCampaign(campaignId int64) {
+	cp.source.ResumeCampaign(campaignId)
+	cp.changed(campaignId)
+}
+
+func (cp *CachedCampaignProvider) DeleteCampaign(campaignId int64) {
+	cp.source.DeleteCampaign(campaignId)
+	cp.changed(campaignId)
+}
+
+func (cp *CachedCampaignProvider) SetCampaignGoal(campaignId int64, goal rtb.Goal) {
+	cp.source.SetCampaignGoal(campaignId, goal)
+	cp.changed(campaignId)
+}
+
+func (cp *CachedCampaignProvider) SetCampaignCreatives(campaignId int64, creatives []rtb.Creative) {
+	cp.source.SetCampaignCreatives(campaignId, creatives)
+	cp.changed(campaignId)
+}
+
+func (cp *CachedCampaignProvider) SetCampaignPriority(campaignId int64, priority rtb.PriorityTier) {
+	cp.source.SetCampaignPriority(campaignId, priority)
+	cp.changed(campaignId)
+}
+
+// ReadCampaign reads a campaign from the cache. Campaigns that aren't cached, such as archived campaigns, are read from the source.
+func (cp *CachedCampaignProvider) ReadCampaign(campaignId int64) rtb.Campaign {
+	if campaign := cp.current().ReadCampaign(campaignId); campaign != nil {
+		return campaign
+	}
+
+	return cp.source.ReadCampaign(campaignId)
+}
+
+// ListCampaigns lists the source's campaigns, so tools managing campaigns see every change
+func (cp *CachedCampaignProvider) ListCampaigns() []int64 {
+	return cp.source.ListCampaigns()
+}
+
+func (cp *CachedCampaignProvider) IsCampaignListed(campaignId int64) bool {
+	return cp.source.IsCampaignListed(campaignId)
+}
+
+func (cp *CachedCampaignProvider) Lists() rtb.ListProvider {
+	return cp.source.Lists()
+}
+
+// NewCachedCampaignProvider loads every campaign from source, and keeps them up to date. Budgets are debited through banker,
+// which should be the banker source uses. notifier is optional, and tells this cache and others when campaigns change.
+// With a refreshInterval, every campaign is also reloaded periodically, in case a notification is lost.
+func NewCachedCampaignProvider(source rtb.CampaignProvider, banker rtb.Banker, notifier rtb.CampaignChangeNotifier, refreshInterval time.Duration) rtb.CachingCampaignProvider {
+	cp := new(CachedCampaignProvider)
+
+	cp.source = source
+	cp.banker = banker
+	cp.notifier = notifier
+	cp.closed = make(chan struct{})
+
+	cp.Refresh()
+
+	if notifier != nil {
+		notifier.Subscribe(func(campaignId int64) {
+			if !cp.isClosed() {
+				cp.Invalidate(campaignId)
+			}
+		})
+	}
+
+	if refreshInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(refreshInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-cp.closed:
+					return
+				case <-ticker.C:
+					cp.Refresh()
+				}
+			}
+		}()
+	}
+
+	return cp
+}
@@ -0,0 +1,132 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"testing"
+	"time"
+)
+
+// TestCachedCampaignProviderNotifications tests that a change made through one cache reaches another cache of the same source
+// Expected result is both caches see created, updated and paused campaigns, and share the campaign's budget
+func TestCachedCampaignProviderNotifications(t *testing.T) {
+	banker := NewInMemoryBanker()
+	source := NewInMemoryCampaignProvider(banker)
+	notifier := NewInMemoryCampaignChangeNotifier()
+
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Cached Targeting 1"}}
+
+	source.CreateCampaign(415, 100, 100, targets)
+
+	first := NewCachedCampaignProvider(source, banker, notifier, 0)
+	second := NewCachedCampaignProvider(source, banker, notifier, 0)
+	defer first.Close()
+	defer second.Close()
+
+	if len(first.ReadByTargeting(0, targets)) != 1 || len(second.ReadByTargeting(0, targets)) != 1 {
+		t.FailNow()
+	}
+
+	first.CreateCampaign(416, 200, 100, targets)
+
+	campaigns := second.ReadByTargeting(0, targets)
+	if len(campaigns) != 2 || campaigns[0].Id() != 416 {
+		t.Fail()
+	}
+
+	first.UpdateCampaign(415, 300, 100, targets)
+
+	campaigns = second.ReadByTargeting(0, targets)
+	if len(campaigns) != 2 || campaigns[0].Id() != 415 {
+		t.Fail()
+	}
+
+	second.PauseCampaign(416)
+
+	campaigns = first.ReadByTargeting(0, targets)
+	if len(campaigns) != 1 || campaigns[0].Id() != 415 {
+		t.Fail()
+	}
+
+	if first.ReadCampaign(416).Status() != rtb.CampaignPaused {
+		t.Fail()
+	}
+
+	expiration := time.Now().Add(time.Hour)
+
+	if _, err := first.DebitCampaign(415, 60, expiration); err != nil {
+		t.Fail()
+	}
+
+	if _, err := second.DebitCampaign(415, 60, expiration); err == nil {
+		t.Fail()
+	}
+}
+
+// TestCachedCampaignProviderRefresh tests that changes made directly to the source are only seen once the cache is refreshed
+// Expected result is the cache answers from its snapshot until it's invalidated or refreshed
+func TestCachedCampaignProviderRefresh(t *testing.T) {
+	banker := NewInMemoryBanker()
+	source := NewInMemoryCampaignProvider(banker)
+
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Cached Targeting 2"}}
+
+	source.CreateCampaign(417, 100, 100, targets)
+
+	cp := NewCachedCampaignProvider(source, banker, nil, 0)
+	defer cp.Close()
+
+	source.CreateCampaign(418, 100, 100, targets)
+	source.DeleteCampaign(417)
+
+	campaigns := cp.ReadByTargeting(0, targets)
+	if len(campaigns) != 1 || campaigns[0].Id() != 417 {
+		t.FailNow()
+	}
+
+	cp.Invalidate(417)
+
+	if len(cp.ReadByTargeting(0, targets)) != 0 {
+		t.Fail()
+	}
+
+	cp.Refresh()
+
+	campaigns = cp.ReadByTargeting(0, targets)
+	if len(campaigns) != 1 || campaigns[0].Id() != 418 {
+		t.Fail()
+	}
+
+	// Archived campaigns aren't cached, but can still be read
+	if cp.ReadCampaign(417) == nil || cp.ReadCampaign(417).Status() != rtb.CampaignArchived {
+		t.Fail()
+	}
+}
+
+// phantomSource reads ids it doesn't have as active campaigns with no settings, the way the redis campaign provider does
+type phantomSource struct {
+	rtb.CampaignProvider
+}
+
+func (s phantomSource) ReadCampaign(campaignId int64) rtb.Campaign {
+	if campaign := s.CampaignProvider.ReadCampaign(campaignId); campaign != nil {
+		return campaign
+	}
+
+	return NewInMemoryCampaign(campaignId, 0, 0, nil)
+}
+
+// TestCachedCampaignProviderInvalidateUnknown tests a change notification for an id the source doesn't have
+// Expected result is nothing is cached for the id
+func TestCachedCampaignProviderInvalidateUnknown(t *testing.T) {
+	banker := NewInMemoryBanker()
+	source := phantomSource{NewInMemoryCampaignProvider(banker)}
+
+	cp := NewCachedCampaignProvider(source, banker, nil, 0).(*CachedCampaignProvider)
+	defer cp.Close()
+
+	cp.Invalidate(419)
+
+	if cp.current().ReadCampaign(419) != nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,34 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/evandigby/rtb"
+	"os"
+	"sync"
+)
+
+// FileAuditLogger writes each audit entry to a file as a line of JSON
+type FileAuditLogger struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func (l *FileAuditLogger) LogChange(entry *rtb.AuditEntry) {
+	js, err := json.Marshal(entry)
+
+	if err != nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	fmt.Fprintln(l.file, string(js))
+}
+
+func NewFileAuditLogger(file *os.File) rtb.AuditLogger {
+	l := new(FileAuditLogger)
+	l.file = file
+	return l
+}
@@ -1,9 +1,11 @@
 package inmemory
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/evandigby/rtb"
+	"io"
 	"os"
 )
 
@@ -12,33 +14,54 @@ type FileBidLogger struct {
 	fullbid bool
 }
 
-func (l *FileBidLogger) LogItem(logItem *rtb.BidLogItem) {
+// format writes a log item as one line
+func (l *FileBidLogger) format(w io.Writer, logItem *rtb.BidLogItem) {
 	if l.fullbid {
 		js, err := json.Marshal(logItem)
 
 		if err == nil {
-			fmt.Fprintln(l.file, string(js[:]))
+			fmt.Fprintln(w, string(js[:]))
 		}
 	} else {
 		responseTimeInMs := (logItem.EndTimestampInNanoseconds - logItem.StartTimestampInNanoseconds) / 1000000
 
-		fmt.Fprint(l.file, logItem.Domain, " / Request ID: ", logItem.BidRequest.ID, " / ")
+		fmt.Fprint(w, logItem.Domain, " / Request ID: ", logItem.BidRequest.ID, " / ")
 		if logItem.BidResponse != nil && logItem.BidResponse.Seatbid != nil && len(logItem.BidResponse.Seatbid) >= 1 && logItem.BidResponse.Seatbid[0].Bid != nil && len(logItem.BidResponse.Seatbid[0].Bid) >= 1 {
 			for _, bid := range logItem.BidResponse.Seatbid[0].Bid {
-				fmt.Fprint(l.file,
+				fmt.Fprint(w,
 					"Campaign: ", bid.Cid,
 					" / Bid: $", bid.Price,
 					" / Remaining Daily Budget: $", rtb.MicroCentsToDollarsRounded(logItem.RemainingDailyBudgetsInMicroCents[bid.Cid], 5),
 					" / Response Time: ", responseTimeInMs, "ms")
 			}
 		} else {
-			fmt.Fprint(l.file, "No Bid.")
+			fmt.Fprint(w, "No Bid.")
+
+			if logItem.NoBidReason != rtb.NoBidReasonNone {
+				fmt.Fprint(w, " Reason: ", logItem.NoBidReason)
+			}
 		}
 
-		fmt.Fprintf(l.file, "\n")
+		fmt.Fprintf(w, "\n")
 	}
 }
 
+// LogItem writes the item's line with a single write, so lines logged concurrently don't interleave
+func (l *FileBidLogger) LogItem(logItem *rtb.BidLogItem) {
+	l.LogItems([]*rtb.BidLogItem{logItem})
+}
+
+// LogItems writes the items' lines with a single write
+func (l *FileBidLogger) LogItems(logItems []*rtb.BidLogItem) {
+	var buffer bytes.Buffer
+
+	for _, logItem := range logItems {
+		l.format(&buffer, logItem)
+	}
+
+	l.file.Write(buffer.Bytes())
+}
+
 func NewFileBidLogger(file *os.File, fullbid bool) rtb.BidLogProducer {
 	l := new(FileBidLogger)
 	l.file = file
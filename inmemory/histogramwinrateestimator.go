@@ -0,0 +1,257 @@
+package inmemory
+
+import (
+	"encoding/csv"
+	"github.com/evandigby/rtb"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type winRateBucket struct {
+	bids int64
+	wins int64
+}
+
+// winRateBlock is a run of adjacent buckets pooled together, ending at the bucket at lastIndex
+type winRateBlock struct {
+	lastIndex int64
+	bids      int64
+	wins      int64
+}
+
+func (b winRateBlock) winRate() float64 {
+	return float64(b.wins) / float64(b.bids)
+}
+
+// winRateCurve is the smoothed curve for one key, kept until another outcome is recorded for it
+type winRateCurve struct {
+	firstIndex   int64
+	blocks       []winRateBlock
+	observations int64
+}
+
+func newWinRateCurve(buckets map[int64]*winRateBucket, observations int64) *winRateCurve {
+	indexes := make([]int64, 0, len(buckets))
+	for index := range buckets {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	// Pool adjacent violators until the win rate never decreases as price increases
+	blocks := make([]winRateBlock, 0, len(indexes))
+	for _, index := range indexes {
+		bucket := buckets[index]
+		blocks = append(blocks, winRateBlock{lastIndex: index, bids: bucket.bids, wins: bucket.wins})
+
+		for len(blocks) > 1 {
+			last := blocks[len(blocks)-1]
+			previous := blocks[len(blocks)-2]
+
+			if previous.winRate() <= last.winRate() {
+				break
+			}
+
+			blocks = blocks[:len(blocks)-2]
+			blocks = append(blocks, winRateBlock{lastIndex: last.lastIndex, bids: previous.bids + last.bids, wins: previous.wins + last.wins})
+		}
+	}
+
+	c := new(winRateCurve)
+
+	c.firstIndex = indexes[0]
+	c.blocks = blocks
+	c.observations = observations
+
+	return c
+}
+
+func (c *winRateCurve) winRate(index int64) float64 {
+	// Bids below everything we've seen are assumed not to win
+	if index < c.firstIndex {
+		return 0
+	}
+
+	// Bids above everything we've seen win as often as the highest bids did
+	i := sort.Search(len(c.blocks), func(i int) bool { return c.blocks[i].lastIndex >= index })
+	if i == len(c.blocks) {
+		i--
+	}
+
+	return c.blocks[i].winRate()
+}
+
+// HistogramWinRateEstimator estimates win rates from outcomes grouped into price buckets.
+// Win rate can only go up as price goes up, so the buckets are smoothed into a non-decreasing curve before use.
+// Each key's curve is smoothed once, and smoothed again only after a new outcome is recorded for it.
+type HistogramWinRateEstimator struct {
+	bucketWidthInMicroCents int64
+
+	mutex        sync.RWMutex
+	buckets      map[string]map[int64]*winRateBucket
+	observations map[string]int64
+	curves       map[string]*winRateCurve
+}
+
+func (e *HistogramWinRateEstimator) RecordOutcome(key string, bidCpmInMicroCents int64, won bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	buckets, ok := e.buckets[key]
+	if !ok {
+		buckets = make(map[int64]*winRateBucket)
+		e.buckets[key] = buckets
+	}
+
+	index := bidCpmInMicroCents / e.bucketWidthInMicroCents
+
+	bucket, ok := buckets[index]
+	if !ok {
+		bucket = new(winRateBucket)
+		buckets[index] = bucket
+	}
+
+	bucket.bids++
+	if won {
+		bucket.wins++
+	}
+
+	e.observations[key]++
+
+	delete(e.curves, key)
+}
+
+// curve returns the smoothed curve for a key, smoothing it if it isn't cached. Returns nil if nothing was recorded for the key.
+func (e *HistogramWinRateEstimator) curve(key string) *winRateCurve {
+	e.mutex.RLock()
+	curve, ok := e.curves[key]
+	e.mutex.RUnlock()
+
+	if ok {
+		return curve
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// Another caller may have smoothed it while we waited for the lock
+	if curve, ok := e.curves[key]; ok {
+		return curve
+	}
+
+	buckets, ok := e.buckets[key]
+	if !ok {
+		return nil
+	}
+
+	curve = newWinRateCurve(buckets, e.observations[key])
+	e.curves[key] = curve
+
+	return curve
+}
+
+func (e *HistogramWinRateEstimator) WinRate(key string, bidCpmInMicroCents int64) (winRate float64, observations int64) {
+	curve := e.curve(key)
+	if curve == nil {
+		return 0, 0
+	}
+
+	return curve.winRate(bidCpmInMicroCents / e.bucketWidthInMicroCents), curve.observations
+}
+
+// LoadAuctionOutcomes records outcomes logged as CSV records of key, bid CPM in micro cents, and whether the bid won (true/false)
+func LoadAuctionOutcomes(r io.Reader, e rtb.WinRateEstimator) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		bid, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		won, err := strconv.ParseBool(record[2])
+		if err != nil {
+			return err
+		}
+
+		e.RecordOutcome(record[0], bid, won)
+	}
+}
+
+// auctionOutcomesFromLogs passes every bid in a bid log to record, as won if its impression key is in wins
+func auctionOutcomesFromLogs(bidLog []*rtb.BidLogItem, wins map[string]bool, record func(key string, bidCpmInMicroCents int64, won bool)) {
+	for _, item := range bidLog {
+		if item.BidRequest == nil || item.BidResponse == nil {
+			continue
+		}
+
+		imps := make(map[string]*rtb.Imp, len(item.BidRequest.Imp))
+		for i := range item.BidRequest.Imp {
+			imps[item.BidRequest.Imp[i].ID] = &item.BidRequest.Imp[i]
+		}
+
+		for _, seatbid := range item.BidResponse.Seatbid {
+			for _, bid := range seatbid.Bid {
+				imp, ok := imps[bid.Impid]
+				if !ok {
+					continue
+				}
+
+				record(rtb.AuctionOutcomeKey(item.BidRequest, imp), rtb.CpmToMicroCents(bid.Price), wins[ImpressionKey(item.BidRequest.ID, bid.Impid)])
+			}
+		}
+	}
+}
+
+// RecordAuctionOutcomesFromLogs records the outcome of every bid in a bid log: won if its impression key (see ImpressionKey)
+// is in wins, the win log, and lost otherwise. Feeding it each new batch of logs keeps the estimator following the market
+// while the bidder runs, instead of staying at what was loaded when it started.
+func RecordAuctionOutcomesFromLogs(e rtb.WinRateEstimator, bidLog []*rtb.BidLogItem, wins map[string]bool) {
+	auctionOutcomesFromLogs(bidLog, wins, e.RecordOutcome)
+}
+
+// WriteAuctionOutcomes writes the outcome of every bid in a bid log as the CSV LoadAuctionOutcomes reads, so the file can be
+// regenerated from the latest logs
+func WriteAuctionOutcomes(w io.Writer, bidLog []*rtb.BidLogItem, wins map[string]bool) error {
+	writer := csv.NewWriter(w)
+
+	var err error
+
+	auctionOutcomesFromLogs(bidLog, wins, func(key string, bidCpmInMicroCents int64, won bool) {
+		if err == nil {
+			err = writer.Write([]string{key, strconv.FormatInt(bidCpmInMicroCents, 10), strconv.FormatBool(won)})
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// NewHistogramWinRateEstimator creates an estimator grouping outcomes into buckets of bucketWidthInMicroCents CPM
+func NewHistogramWinRateEstimator(bucketWidthInMicroCents int64) rtb.WinRateEstimator {
+	e := new(HistogramWinRateEstimator)
+
+	e.bucketWidthInMicroCents = bucketWidthInMicroCents
+	e.buckets = make(map[string]map[int64]*winRateBucket)
+	e.observations = make(map[string]int64)
+	e.curves = make(map[string]*winRateCurve)
+
+	return e
+}
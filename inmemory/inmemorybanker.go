@@ -0,0 +1,90 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"sync"
+	"time"
+)
+
+type inMemoryAccount struct {
+	remainingDailyBudgetInMicroCents int64
+	expiration                       time.Time
+}
+
+// InMemoryBanker tracks daily budgets in process. Budgets are not shared between bidders.
+type InMemoryBanker struct {
+	mutex    sync.Mutex
+	accounts map[int64]*inMemoryAccount
+
+	now func() time.Time
+}
+
+// account returns the account if it exists and hasn't expired
+func (b *InMemoryBanker) account(account int64) *inMemoryAccount {
+	a, ok := b.accounts[account]
+
+	if !ok {
+		return nil
+	}
+
+	if !b.now().Before(a.expiration) {
+		delete(b.accounts, account)
+		return nil
+	}
+
+	return a
+}
+
+func (b *InMemoryBanker) DebitAccount(account int64, amount int64, dailyBudget int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCents int64, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	a := b.account(account)
+
+	if a == nil {
+		a = &inMemoryAccount{remainingDailyBudgetInMicroCents: dailyBudget, expiration: dailyBudgetExpiration}
+		b.accounts[account] = a
+	}
+
+	if a.remainingDailyBudgetInMicroCents < amount {
+		return a.remainingDailyBudgetInMicroCents, rtb.NewTransactionError("Insufficient daily funds.", true)
+	}
+
+	a.remainingDailyBudgetInMicroCents -= amount
+
+	return a.remainingDailyBudgetInMicroCents, nil
+}
+
+func (b *InMemoryBanker) RemainingDailyBudgetInMicroCents(account int64) int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if a := b.account(account); a != nil {
+		return a.remainingDailyBudgetInMicroCents
+	}
+
+	return 0
+}
+
+func (b *InMemoryBanker) DeleteAccount(account int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.accounts, account)
+}
+
+func (b *InMemoryBanker) SetRemainingDailyBudgetInMicroCents(account int64, amount int64, dailyBudgetExpiration time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.accounts[account] = &inMemoryAccount{remainingDailyBudgetInMicroCents: amount, expiration: dailyBudgetExpiration}
+}
+
+func NewInMemoryBanker() rtb.Banker {
+	b := new(InMemoryBanker)
+
+	b.accounts = make(map[int64]*inMemoryAccount)
+	b.now = time.Now
+
+	return b
+}
@@ -0,0 +1,77 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+)
+
+// InMemoryCampaign is a campaign held by the InMemoryCampaignProvider.
+// Campaigns are never modified once created; the provider replaces them instead, so they are safe to share.
+type InMemoryCampaign struct {
+	campaignId              int64
+	bidCpmInMicroCents      int64
+	dailyBudgetInMicroCents int64
+	targets                 []rtb.Target
+	goal                    rtb.Goal
+	creatives               []rtb.Creative
+	priority                rtb.PriorityTier
+	status                  rtb.CampaignStatus
+}
+
+func (c *InMemoryCampaign) Id() int64 {
+	return c.campaignId
+}
+
+func (c *InMemoryCampaign) BidCpmInMicroCents() int64 {
+	return c.bidCpmInMicroCents
+}
+
+func (c *InMemoryCampaign) DailyBudgetInMicroCents() int64 {
+	return c.dailyBudgetInMicroCents
+}
+
+func (c *InMemoryCampaign) Targets() *map[rtb.TargetType]string {
+	targets := make(map[rtb.TargetType]string, len(c.targets))
+
+	for _, target := range c.targets {
+		targets[target.Type] = target.Value
+	}
+
+	return &targets
+}
+
+func (c *InMemoryCampaign) TargetList() []rtb.Target {
+	return append([]rtb.Target(nil), c.targets...)
+}
+
+func (c *InMemoryCampaign) Goal() rtb.Goal {
+	return c.goal
+}
+
+func (c *InMemoryCampaign) Creatives() []rtb.Creative {
+	return c.creatives
+}
+
+func (c *InMemoryCampaign) Priority() rtb.PriorityTier {
+	return c.priority
+}
+
+func (c *InMemoryCampaign) Status() rtb.CampaignStatus {
+	return c.status
+}
+
+// copy returns a copy of the campaign that can be modified before it replaces this one
+func (c *InMemoryCampaign) copy() *InMemoryCampaign {
+	copied := *c
+	return &copied
+}
+
+func NewInMemoryCampaign(id int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	c := new(InMemoryCampaign)
+
+	c.campaignId = id
+	c.bidCpmInMicroCents = bidCpmInMicroCents
+	c.dailyBudgetInMicroCents = dailyBudgetInMicroCents
+	c.targets = append([]rtb.Target(nil), targets...)
+
+	return c
+}
@@ -0,0 +1,45 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"sync"
+)
+
+// InMemoryCampaignChangeNotifier announces campaign changes to subscribers in the same process
+type InMemoryCampaignChangeNotifier struct {
+	mutex       sync.RWMutex
+	subscribers []func(campaignId int64)
+	closed      bool
+}
+
+func (n *InMemoryCampaignChangeNotifier) NotifyChanged(campaignId int64) {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	if n.closed {
+		return
+	}
+
+	for _, changed := range n.subscribers {
+		changed(campaignId)
+	}
+}
+
+func (n *InMemoryCampaignChangeNotifier) Subscribe(changed func(campaignId int64)) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.subscribers = append(n.subscribers, changed)
+}
+
+func (n *InMemoryCampaignChangeNotifier) Close() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.closed = true
+	n.subscribers = nil
+}
+
+func NewInMemoryCampaignChangeNotifier() rtb.CampaignChangeNotifier {
+	return new(InMemoryCampaignChangeNotifier)
+}
@@ -0,0 +1,300 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// InMemoryCampaignProvider keeps campaigns and the target index in process. Only active campaigns are in the index.
+// Budgets are tracked by the banker, which can be shared with other bidders.
+type InMemoryCampaignProvider struct {
+	banker rtb.Banker
+	lists  rtb.ListProvider
+
+	mutex     sync.RWMutex
+	campaigns map[int64]*InMemoryCampaign
+	// Target key to the ids of the campaigns targeting it
+	index map[string]map[int64]bool
+}
+
+func (cp *InMemoryCampaignProvider) ReadByTargeting(bidFloorInMicroCents int64, targets []rtb.Target) []rtb.Campaign {
+	cp.mutex.RLock()
+
+	matched := make(map[int64]bool)
+	for _, target := range rtb.IndexedTargets(targets) {
+		for id := range cp.index[rtb.TargetKey("", target)] {
+			matched[id] = true
+		}
+	}
+
+	campaigns := make([]rtb.Campaign, 0, len(matched))
+	for id := range matched {
+		campaigns = append(campaigns, cp.campaigns[id])
+	}
+
+	cp.mutex.RUnlock()
+
+	// Highest CPM first. Ties are broken the same way the data access's sorted set union breaks them, by the highest id
+	// compared as a string (so 9 comes before 10), so every backend picks the same winner.
+	sort.Slice(campaigns, func(i, j int) bool {
+		if campaigns[i].BidCpmInMicroCents() != campaigns[j].BidCpmInMicroCents() {
+			return campaigns[i].BidCpmInMicroCents() > campaigns[j].BidCpmInMicroCents()
+		}
+		return strconv.FormatInt(campaigns[i].Id(), 10) > strconv.FormatInt(campaigns[j].Id(), 10)
+	})
+
+	return rtb.FilterByConstraints(campaigns, targets, cp.lists)
+}
+
+func (cp *InMemoryCampaignProvider) DebitCampaign(campaignId int64, amountInMicroCents int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCents int64, err error) {
+	campaign := cp.ReadCampaign(campaignId)
+
+	if campaign == nil {
+		return 0, rtb.NewTransactionError("Campaign does not exist.", false)
+	}
+
+	return cp.banker.DebitAccount(campaignId, amountInMicroCents, campaign.DailyBudgetInMicroCents(), dailyBudgetExpiration)
+}
+
+// Must be called with the write lock held
+func (cp *InMemoryCampaignProvider) addToIndex(campaignId int64, targets []rtb.Target) {
+	for _, target := range rtb.IndexedTargets(targets) {
+		key := rtb.TargetKey("", target)
+
+		ids, ok := cp.index[key]
+		if !ok {
+			ids = make(map[int64]bool)
+			cp.index[key] = ids
+		}
+
+		ids[campaignId] = true
+	}
+}
+
+// Must be called with the write lock held
+func (cp *InMemoryCampaignProvider) removeFromIndex(campaignId int64, targets []rtb.Target) {
+	for _, target := range rtb.IndexedTargets(targets) {
+		key := rtb.TargetKey("", target)
+
+		if ids, ok := cp.index[key]; ok {
+			delete(ids, campaignId)
+
+			if len(ids) == 0 {
+				delete(cp.index, key)
+			}
+		}
+	}
+}
+
+// put adds or replaces a campaign as it is, indexing it if it's active
+func (cp *InMemoryCampaignProvider) put(campaign *InMemoryCampaign) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if existing, ok := cp.campaigns[campaign.campaignId]; ok && existing.status == rtb.CampaignActive {
+		cp.removeFromIndex(existing.campaignId, existing.targets)
+	}
+
+	cp.campaigns[campaign.campaignId] = campaign
+
+	if campaign.status == rtb.CampaignActive {
+		cp.addToIndex(campaign.campaignId, campaign.targets)
+	}
+}
+
+// remove forgets a campaign entirely
+func (cp *InMemoryCampaignProvider) remove(campaignId int64) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if existing, ok := cp.campaigns[campaignId]; ok {
+		if existing.status == rtb.CampaignActive {
+			cp.removeFromIndex(campaignId, existing.targets)
+		}
+
+		delete(cp.campaigns, campaignId)
+	}
+}
+
+func (cp *InMemoryCampaignProvider) createCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target, status rtb.CampaignStatus) rtb.Campaign {
+	campaign := NewInMemoryCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets).(*InMemoryCampaign)
+	campaign.status = status
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if existing, ok := cp.campaigns[campaignId]; ok {
+		cp.removeFromIndex(campaignId, existing.targets)
+	}
+
+	cp.campaigns[campaignId] = campaign
+
+	if status == rtb.CampaignActive {
+		cp.addToIndex(campaignId, targets)
+	}
+
+	return campaign
+}
+
+func (cp *InMemoryCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	return cp.createCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets, rtb.CampaignActive)
+}
+
+func (cp *InMemoryCampaignProvider) CreatePausedCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	return cp.createCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets, rtb.CampaignPaused)
+}
+
+func (cp *InMemoryCampaignProvider) UpdateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	campaign, ok := cp.campaigns[campaignId]
+	if !ok || campaign.status == rtb.CampaignArchived {
+		return nil
+	}
+
+	updated := campaign.copy()
+	updated.bidCpmInMicroCents = bidCpmInMicroCents
+	updated.dailyBudgetInMicroCents = dailyBudgetInMicroCents
+	updated.targets = append([]rtb.Target(nil), targets...)
+
+	if campaign.status == rtb.CampaignActive {
+		cp.removeFromIndex(campaignId, campaign.targets)
+		cp.addToIndex(campaignId, updated.targets)
+	}
+
+	cp.campaigns[campaignId] = updated
+
+	return updated
+}
+
+// setStatus moves a campaign from one of the statuses to the new status, adding it to or removing it from the index
+func (cp *InMemoryCampaignProvider) setStatus(campaignId int64, from []rtb.CampaignStatus, status rtb.CampaignStatus) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	campaign, ok := cp.campaigns[campaignId]
+	if !ok {
+		return
+	}
+
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || campaign.status == s
+	}
+
+	if !allowed || campaign.status == status {
+		return
+	}
+
+	if campaign.status == rtb.CampaignActive {
+		cp.removeFromIndex(campaignId, campaign.targets)
+	} else if status == rtb.CampaignActive {
+		cp.addToIndex(campaignId, campaign.targets)
+	}
+
+	updated := campaign.copy()
+	updated.status = status
+	cp.campaigns[campaignId] = updated
+}
+
+func (cp *InMemoryCampaignProvider) PauseCampaign(campaignId int64) {
+	cp.setStatus(campaignId, []rtb.CampaignStatus{rtb.CampaignActive}, rtb.CampaignPaused)
+}
+
+func (cp *InMemoryCampaignProvider) ResumeCampaign(campaignId int64) {
+	cp.setStatus(campaignId, []rtb.CampaignStatus{rtb.CampaignPaused}, rtb.CampaignActive)
+}
+
+func (cp *InMemoryCampaignProvider) DeleteCampaign(campaignId int64) {
+	cp.setStatus(campaignId, []rtb.CampaignStatus{rtb.CampaignActive, rtb.CampaignPaused}, rtb.CampaignArchived)
+}
+
+func (cp *InMemoryCampaignProvider) SetCampaignGoal(campaignId int64, goal rtb.Goal) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if campaign, ok := cp.campaigns[campaignId]; ok {
+		updated := campaign.copy()
+		updated.goal = goal
+		cp.campaigns[campaignId] = updated
+	}
+}
+
+func (cp *InMemoryCampaignProvider) SetCampaignCreatives(campaignId int64, creatives []rtb.Creative) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if campaign, ok := cp.campaigns[campaignId]; ok {
+		updated := campaign.copy()
+		updated.creatives = append([]rtb.Creative(nil), creatives...)
+		cp.campaigns[campaignId] = updated
+	}
+}
+
+func (cp *InMemoryCampaignProvider) SetCampaignPriority(campaignId int64, priority rtb.PriorityTier) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if campaign, ok := cp.campaigns[campaignId]; ok {
+		updated := campaign.copy()
+		updated.priority = priority
+		cp.campaigns[campaignId] = updated
+	}
+}
+
+func (cp *InMemoryCampaignProvider) ReadCampaign(campaignId int64) rtb.Campaign {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	if campaign, ok := cp.campaigns[campaignId]; ok {
+		return campaign
+	}
+
+	return nil
+}
+
+func (cp *InMemoryCampaignProvider) ListCampaigns() []int64 {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	ids := make([]int64, 0, len(cp.campaigns))
+	for id, campaign := range cp.campaigns {
+		if campaign.status != rtb.CampaignArchived {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func (cp *InMemoryCampaignProvider) IsCampaignListed(campaignId int64) bool {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	campaign, ok := cp.campaigns[campaignId]
+
+	return ok && campaign.status != rtb.CampaignArchived
+}
+
+func (cp *InMemoryCampaignProvider) Lists() rtb.ListProvider {
+	return cp.lists
+}
+
+func newInMemoryCampaignProvider(banker rtb.Banker, lists rtb.ListProvider) *InMemoryCampaignProvider {
+	cp := new(InMemoryCampaignProvider)
+
+	cp.banker = banker
+	cp.lists = lists
+	cp.campaigns = make(map[int64]*InMemoryCampaign)
+	cp.index = make(map[string]map[int64]bool)
+
+	return cp
+}
+
+func NewInMemoryCampaignProvider(banker rtb.Banker) rtb.CampaignProvider {
+	return newInMemoryCampaignProvider(banker, NewInMemoryListProvider())
+}
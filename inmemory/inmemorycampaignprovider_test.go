@@ -0,0 +1,420 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestInMemoryReadCampaignByTargetingOrder tests that campaigns are read back from highest bid cpm to lowest
+// Expected result is all matching campaigns are returned in order
+func TestInMemoryReadCampaignByTargetingOrder(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	target := rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 6"}
+	targets := []rtb.Target{target}
+
+	cp.CreateCampaign(309, 100, 100, targets)
+	cp.CreateCampaign(310, 104, 100, targets)
+	cp.CreateCampaign(311, 102, 100, targets)
+	cp.CreateCampaign(312, 99, 100, []rtb.Target{rtb.Target{Type: rtb.CreativeSize, Value: "320x50"}})
+
+	expectedResults := []int64{310, 311, 309}
+
+	campaigns := cp.ReadByTargeting(0, targets)
+
+	if len(campaigns) != len(expectedResults) {
+		t.FailNow()
+	}
+
+	for i, campaign := range campaigns {
+		if campaign.Id() != expectedResults[i] {
+			t.Fail()
+		}
+	}
+}
+
+// TestInMemoryReadCampaignByTargetingTies tests the order of campaigns with the same bid cpm
+// Expected result is the same order as the sorted set union, by id as a string, so 9 comes before 10
+func TestInMemoryReadCampaignByTargetingTies(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Tied"}}
+
+	cp.CreateCampaign(10, 100, 100, targets)
+	cp.CreateCampaign(9, 100, 100, targets)
+
+	campaigns := cp.ReadByTargeting(0, targets)
+
+	if len(campaigns) != 2 || campaigns[0].Id() != 9 || campaigns[1].Id() != 10 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignByGeoRadius tests that a radius targeted campaign only matches requests within the radius
+// Expected result is the campaign is returned for a request inside the radius, and not for one outside it
+func TestInMemoryReadCampaignByGeoRadius(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	cp.CreateCampaign(400, 100, 100, rtb.GeoRadiusTargets(rtb.GeoCircle{Center: rtb.LatLon{Lat: 43.6532, Lon: -79.3832}, RadiusInKm: 10}))
+
+	inside := (&rtb.Geo{Lat: 43.66, Lon: -79.39}).Targeting()
+	outside := (&rtb.Geo{Lat: 43.75, Lon: -79.25}).Targeting()
+
+	if len(cp.ReadByTargeting(0, inside)) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, outside)) != 0 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryDebitCampaign tests that a debit initializes the daily budget and subtracts from it
+// Expected result is the daily budget minus the debit, then a failed debit once the budget runs out
+func TestInMemoryDebitCampaign(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	cp.CreateCampaign(401, 100, 50, nil)
+
+	expiration := time.Now().UTC().AddDate(0, 0, 1)
+
+	remaining, err := cp.DebitCampaign(401, 32, expiration)
+
+	if err != nil || remaining != 18 {
+		t.Fail()
+	}
+
+	remaining, err = cp.DebitCampaign(401, 32, expiration)
+
+	if err == nil || remaining != 18 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignByOSVersionRange tests that a campaign targeting an OS version range only matches devices in range
+// Expected result is the campaign is returned for iOS 14.4, and not for iOS 13.7
+func TestInMemoryReadCampaignByOSVersionRange(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	cp.CreateCampaign(402, 100, 100, []rtb.Target{rtb.Target{Type: rtb.OS, Value: "iOS"}, rtb.OSVersionRangeTarget(">=14.2")})
+
+	inRange := (&rtb.Device{Os: "iOS", Osv: "14.4"}).Targeting()
+	outOfRange := (&rtb.Device{Os: "iOS", Osv: "13.7"}).Targeting()
+
+	if len(cp.ReadByTargeting(0, inRange)) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, outOfRange)) != 0 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignByConnectionType tests that a campaign targeting cellular matches any cellular generation
+// Expected result is the campaign is returned for a 4G device, and not for a wifi device
+func TestInMemoryReadCampaignByConnectionType(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	cp.CreateCampaign(403, 100, 100, []rtb.Target{rtb.Target{Type: rtb.ConnectionType, Value: rtb.ConnectionTypeCellular}})
+
+	if len(cp.ReadByTargeting(0, (&rtb.Device{Connectiontype: 6}).Targeting())) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, (&rtb.Device{Connectiontype: 2}).Targeting())) != 0 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignBySchedule tests that a campaign is only read during its scheduled hours
+// Expected result is the campaign is returned at 10am on a Monday in its timezone, and not at 10pm
+func TestInMemoryReadCampaignBySchedule(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	location, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := rtb.NewSchedule(location)
+	schedule.SetHours([]time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, 9, 17)
+
+	cp.CreateCampaign(404, 100, 100, []rtb.Target{rtb.Target{Type: rtb.Country, Value: "CAN"}, rtb.ScheduleTarget(schedule)})
+
+	morning := []rtb.Target{rtb.Target{Type: rtb.Country, Value: "CAN"}, rtb.RequestTimeTarget(time.Date(2015, 6, 1, 10, 0, 0, 0, location))}
+	evening := []rtb.Target{rtb.Target{Type: rtb.Country, Value: "CAN"}, rtb.RequestTimeTarget(time.Date(2015, 6, 1, 22, 0, 0, 0, location))}
+
+	if len(cp.ReadByTargeting(0, morning)) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, evening)) != 0 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignByAudience tests that a campaign with an audience constraint only matches devices in the audience
+// Expected result is the campaign is returned for a device in the uploaded list, whatever the case of its id
+func TestInMemoryReadCampaignByAudience(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	cp.CreateCampaign(405, 100, 100, []rtb.Target{rtb.SegmentTarget("7", "42"), rtb.AudienceTarget("lapsed", "loyal")})
+
+	if _, err := rtb.LoadList(strings.NewReader("AAAA-1111\n\nbbbb-2222\n"), cp.Lists(), "loyal", 1, true); err != nil {
+		t.FailNow()
+	}
+
+	user := &rtb.User{Data: []rtb.UserData{rtb.UserData{ID: "7", Segment: []rtb.Segment{rtb.Segment{ID: "42"}}}}}
+
+	inAudience := &rtb.BidRequest{User: user, Device: &rtb.Device{Ifa: "aaaa-1111"}}
+	notInAudience := &rtb.BidRequest{User: user, Device: &rtb.Device{Ifa: "cccc-3333"}}
+
+	if len(cp.ReadByTargeting(0, inAudience.Targeting())) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, notInAudience.Targeting())) != 0 {
+		t.Fail()
+	}
+
+	if cp.Lists().ListSize("loyal") != 2 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignByPlacementLists tests allow and block lists loaded from CSV and shared between campaigns
+// Expected result is the allow list campaign only matches listed apps, and the block list campaign matches everything else
+func TestInMemoryReadCampaignByPlacementLists(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	csv := "kind,value\nbundle,com.Example.Game\ndomain,example.com\n"
+	if count, err := rtb.LoadPlacementList(strings.NewReader(csv), cp.Lists(), "premium"); err != nil || count != 2 {
+		t.FailNow()
+	}
+
+	cp.CreateCampaign(406, 200, 100, []rtb.Target{rtb.AllRequestsTarget(), rtb.PlacementAllowListTarget("premium")})
+	cp.CreateCampaign(407, 100, 100, []rtb.Target{rtb.AllRequestsTarget(), rtb.PlacementBlockListTarget("premium")})
+
+	listed := (&rtb.BidRequest{App: &rtb.App{Bundle: "com.example.game"}}).Targeting()
+	subdomain := (&rtb.BidRequest{Site: &rtb.Site{Domain: "www.example.com"}}).Targeting()
+	unlisted := (&rtb.BidRequest{App: &rtb.App{Bundle: "com.other.game"}}).Targeting()
+
+	if campaigns := cp.ReadByTargeting(0, listed); len(campaigns) != 1 || campaigns[0].Id() != 406 {
+		t.Fail()
+	}
+
+	if campaigns := cp.ReadByTargeting(0, subdomain); len(campaigns) != 1 || campaigns[0].Id() != 406 {
+		t.Fail()
+	}
+
+	if campaigns := cp.ReadByTargeting(0, unlisted); len(campaigns) != 1 || campaigns[0].Id() != 407 {
+		t.Fail()
+	}
+
+	if _, err := rtb.LoadPlacementList(strings.NewReader("site,example.com\n"), cp.Lists(), "premium"); err == nil {
+		t.Fail()
+	}
+}
+
+// TestInMemoryReadCampaignByContentCategory tests category targeting with an excluded subcategory
+// Expected result is the campaign matches sports content, but not the excluded subcategory
+func TestInMemoryReadCampaignByContentCategory(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	targets := append(rtb.ContentCategoryTargets("IAB17"), rtb.CategoryExcludeTarget("IAB17-18"))
+	cp.CreateCampaign(408, 100, 100, targets)
+
+	if len(cp.ReadByTargeting(0, (&rtb.BidRequest{Site: &rtb.Site{Cat: []string{"IAB17-12"}}}).Targeting())) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, (&rtb.BidRequest{Site: &rtb.Site{Cat: []string{"IAB17-18"}}}).Targeting())) != 0 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, (&rtb.BidRequest{App: &rtb.App{Cat: []string{"483"}}}).Targeting())) != 1 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryCampaignStatus tests that paused and archived campaigns aren't matched by targeting
+// Expected result is the campaign is matched while active, and not while paused or once it's archived
+func TestInMemoryCampaignStatus(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 409"}}
+
+	cp.CreateCampaign(409, 100, 100, targets)
+
+	cp.PauseCampaign(409)
+	if len(cp.ReadByTargeting(0, targets)) != 0 || cp.ReadCampaign(409).Status() != rtb.CampaignPaused {
+		t.Fail()
+	}
+
+	cp.ResumeCampaign(409)
+	if len(cp.ReadByTargeting(0, targets)) != 1 {
+		t.Fail()
+	}
+
+	cp.DeleteCampaign(409)
+	if len(cp.ReadByTargeting(0, targets)) != 0 || len(cp.ListCampaigns()) != 0 || cp.ReadCampaign(409).Status() != rtb.CampaignArchived {
+		t.Fail()
+	}
+
+	// Archived campaigns can't be brought back
+	cp.ResumeCampaign(409)
+	if cp.UpdateCampaign(409, 100, 100, targets) != nil || len(cp.ReadByTargeting(0, targets)) != 0 {
+		t.Fail()
+	}
+}
+
+// TestInMemoryUpdateCampaign tests that updating a campaign's targets and CPM keeps the index in sync
+// Expected result is the campaign is only matched by its new targets, and is ordered by its new CPM
+func TestInMemoryUpdateCampaign(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	oldTargets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 410"}}
+	newTargets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 411"}}
+
+	cp.CreateCampaign(410, 100, 100, oldTargets)
+	cp.CreateCampaign(411, 200, 100, newTargets)
+
+	if cp.UpdateCampaign(410, 300, 100, newTargets) == nil {
+		t.FailNow()
+	}
+
+	if len(cp.ReadByTargeting(0, oldTargets)) != 0 {
+		t.Fail()
+	}
+
+	campaigns := cp.ReadByTargeting(0, newTargets)
+	if len(campaigns) != 2 || campaigns[0].Id() != 410 || campaigns[0].BidCpmInMicroCents() != 300 {
+		t.Fail()
+	}
+
+	// A paused campaign's targets can be changed without it being matched
+	cp.PauseCampaign(410)
+	cp.UpdateCampaign(410, 300, 100, oldTargets)
+
+	if len(cp.ReadByTargeting(0, oldTargets)) != 0 || len(cp.ReadByTargeting(0, newTargets)) != 1 {
+		t.Fail()
+	}
+}
+
+// TestInMemorySyncCampaignSpecs tests that specs are diffed against the campaign provider and applied
+// Expected result is the missing campaign is created, the changed one updated, the undeclared one paused, and then nothing differs
+func TestInMemorySyncCampaignSpecs(t *testing.T) {
+	cp := NewInMemoryCampaignProvider(NewInMemoryBanker())
+
+	cp.CreateCampaign(412, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(10), []rtb.Target{rtb.Target{Type: rtb.Country, Value: "CAN"}})
+	cp.CreateCampaign(413, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(10), []rtb.Target{rtb.Target{Type: rtb.Country, Value: "USA"}})
+
+	specs := []rtb.CampaignSpec{
+		rtb.CampaignSpec{ID: 412, BidCpm: 2, DailyBudget: 10, Targets: []rtb.TargetSpec{rtb.TargetSpec{Type: "country", Value: "CAN"}, rtb.TargetSpec{Type: "country", Value: "MEX"}}},
+		rtb.CampaignSpec{ID: 414, BidCpm: 1, DailyBudget: 5, Status: "paused", Targets: []rtb.TargetSpec{rtb.TargetSpec{Type: "country", Value: "MEX"}},
+			Creatives: []rtb.Creative{rtb.Creative{ID: "414a", W: 320, H: 50}}},
+	}
+
+	changes, err := rtb.DiffCampaigns(cp, specs, true)
+	if err != nil || len(changes) != 3 {
+		t.FailNow()
+	}
+
+	if changes[0].Type != rtb.CampaignUpdate || changes[1].Type != rtb.CampaignCreate || changes[2].Type != rtb.CampaignPrune || changes[2].CampaignId != 413 {
+		t.Fail()
+	}
+
+	if strings.Join(changes[0].Fields, ",") != "bidCpm,targets" {
+		t.Fail()
+	}
+
+	rtb.ApplyCampaignChanges(cp, changes)
+
+	campaigns := cp.ReadByTargeting(0, []rtb.Target{rtb.Target{Type: rtb.Country, Value: "MEX"}})
+	if len(campaigns) != 1 || campaigns[0].Id() != 412 || campaigns[0].BidCpmInMicroCents() != rtb.CpmToMicroCents(2) {
+		t.Fail()
+	}
+
+	if cp.ReadCampaign(413).Status() != rtb.CampaignPaused || cp.ReadCampaign(414).Status() != rtb.CampaignPaused || len(cp.ReadCampaign(414).Creatives()) != 1 {
+		t.Fail()
+	}
+
+	changes, err = rtb.DiffCampaigns(cp, specs, true)
+	if err != nil || len(changes) != 0 {
+		t.Fail()
+	}
+}
+
+// settingsWatchingCampaignProvider records whether a campaign could be matched by targeting while its settings were applied
+type settingsWatchingCampaignProvider struct {
+	rtb.CampaignProvider
+	targets []rtb.Target
+	matched bool
+}
+
+func (cp *settingsWatchingCampaignProvider) watch() {
+	cp.matched = cp.matched || len(cp.ReadByTargeting(0, cp.targets)) > 0
+}
+
+func (cp *settingsWatchingCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	campaign := cp.CampaignProvider.CreateCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets)
+	cp.watch()
+
+	return campaign
+}
+
+func (cp *settingsWatchingCampaignProvider) CreatePausedCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	campaign := cp.CampaignProvider.CreatePausedCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets)
+	cp.watch()
+
+	return campaign
+}
+
+func (cp *settingsWatchingCampaignProvider) SetCampaignGoal(campaignId int64, goal rtb.Goal) {
+	cp.watch()
+	cp.CampaignProvider.SetCampaignGoal(campaignId, goal)
+}
+
+func (cp *settingsWatchingCampaignProvider) SetCampaignPriority(campaignId int64, priority rtb.PriorityTier) {
+	cp.watch()
+	cp.CampaignProvider.SetCampaignPriority(campaignId, priority)
+}
+
+func (cp *settingsWatchingCampaignProvider) SetCampaignCreatives(campaignId int64, creatives []rtb.Creative) {
+	cp.watch()
+	cp.CampaignProvider.SetCampaignCreatives(campaignId, creatives)
+}
+
+// TestInMemoryCreateCampaignFromSpec tests that a campaign created from a spec isn't matched until all of its settings are applied
+// Expected result is the campaign is never matched before its goal, priority and creatives are set, and is matched afterwards
+func TestInMemoryCreateCampaignFromSpec(t *testing.T) {
+	targets := []rtb.Target{rtb.Target{Type: rtb.Country, Value: "CAN"}}
+	cp := &settingsWatchingCampaignProvider{CampaignProvider: NewInMemoryCampaignProvider(NewInMemoryBanker()), targets: targets}
+
+	specs := []rtb.CampaignSpec{rtb.CampaignSpec{ID: 419, BidCpm: 1, DailyBudget: 5, Priority: "guaranteed", Targets: []rtb.TargetSpec{rtb.TargetSpec{Type: "country", Value: "CAN"}},
+		Creatives: []rtb.Creative{rtb.Creative{ID: "419a", Cat: []string{"IAB2"}, Attr: []int{1}, W: 320, H: 50}}}}
+
+	changes, err := rtb.DiffCampaigns(cp, specs, false)
+	if err != nil || len(changes) != 1 || changes[0].Type != rtb.CampaignCreate {
+		t.FailNow()
+	}
+
+	rtb.ApplyCampaignChanges(cp, changes)
+
+	if cp.matched || len(cp.ReadByTargeting(0, targets)) != 1 {
+		t.Fail()
+	}
+
+	// Creatives are compared field by field, so nothing differs once they're stored
+	if changes, err := rtb.DiffCampaigns(cp, specs, false); err != nil || len(changes) != 0 {
+		t.Fail()
+	}
+
+	specs[0].Creatives[0].Attr = []int{2}
+
+	if changes, err := rtb.DiffCampaigns(cp, specs, false); err != nil || len(changes) != 1 || strings.Join(changes[0].Fields, ",") != "creatives" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,56 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"sync"
+)
+
+// InMemoryListProvider keeps named lists in process
+type InMemoryListProvider struct {
+	mutex sync.RWMutex
+	lists map[string]map[string]bool
+}
+
+func (lp *InMemoryListProvider) AddToList(name string, members []string) {
+	lp.mutex.Lock()
+	defer lp.mutex.Unlock()
+
+	list, ok := lp.lists[name]
+	if !ok {
+		list = make(map[string]bool, len(members))
+		lp.lists[name] = list
+	}
+
+	for _, member := range members {
+		list[member] = true
+	}
+}
+
+func (lp *InMemoryListProvider) ListContains(name string, member string) bool {
+	lp.mutex.RLock()
+	defer lp.mutex.RUnlock()
+
+	return lp.lists[name][member]
+}
+
+func (lp *InMemoryListProvider) ListSize(name string) int64 {
+	lp.mutex.RLock()
+	defer lp.mutex.RUnlock()
+
+	return int64(len(lp.lists[name]))
+}
+
+func (lp *InMemoryListProvider) DeleteList(name string) {
+	lp.mutex.Lock()
+	defer lp.mutex.Unlock()
+
+	delete(lp.lists, name)
+}
+
+func NewInMemoryListProvider() rtb.ListProvider {
+	lp := new(InMemoryListProvider)
+
+	lp.lists = make(map[string]map[string]bool)
+
+	return lp
+}
@@ -0,0 +1,132 @@
+package inmemory
+
+import (
+	"encoding/json"
+	"github.com/evandigby/rtb"
+	"io"
+	"math"
+)
+
+// LogisticRegressionModel predicts the probability of an event from the features of an impression.
+// Models are stored as JSON:
+//
+//	{"bias": -6.2, "weights": {"os=iOS": 0.4, "size=320x50": -0.1}}
+//
+// Features that are not in the model have no weight.
+type LogisticRegressionModel struct {
+	Bias    float64            `json:"bias"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+// TrainingExample defines the features of an impression, and whether the event being predicted happened
+type TrainingExample struct {
+	Features []string
+	Positive bool
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func (m *LogisticRegressionModel) predictFeatures(features []string) float64 {
+	z := m.Bias
+
+	for _, feature := range features {
+		z += m.Weights[feature]
+	}
+
+	return sigmoid(z)
+}
+
+func (m *LogisticRegressionModel) Predict(request *rtb.BidRequest, imp *rtb.Imp, campaign rtb.Campaign) float64 {
+	return m.predictFeatures(rtb.Features(request, imp, campaign))
+}
+
+// Write stores the model as JSON
+func (m *LogisticRegressionModel) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(m)
+}
+
+// ReadLogisticRegressionModel reads a model stored as JSON
+func ReadLogisticRegressionModel(r io.Reader) (*LogisticRegressionModel, error) {
+	m := new(LogisticRegressionModel)
+
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+
+	if m.Weights == nil {
+		m.Weights = make(map[string]float64)
+	}
+
+	return m, nil
+}
+
+// TrainLogisticRegression fits a model with stochastic gradient descent.
+// l2 is the regularization applied to feature weights, which keeps rare features from getting extreme weights.
+func TrainLogisticRegression(examples []TrainingExample, epochs int, learningRate float64, l2 float64) *LogisticRegressionModel {
+	m := new(LogisticRegressionModel)
+	m.Weights = make(map[string]float64)
+
+	for epoch := 0; epoch < epochs; epoch++ {
+		for _, example := range examples {
+			label := float64(0)
+			if example.Positive {
+				label = 1
+			}
+
+			gradient := m.predictFeatures(example.Features) - label
+
+			m.Bias -= learningRate * gradient
+
+			for _, feature := range example.Features {
+				w := m.Weights[feature]
+				m.Weights[feature] = w - learningRate*(gradient+l2*w)
+			}
+		}
+	}
+
+	return m
+}
+
+// ImpressionKey joins bid logs with win, click and conversion logs, which record the bid request id and impression id
+func ImpressionKey(bidRequestId string, impId string) string {
+	return bidRequestId + ":" + impId
+}
+
+// TrainingExamplesFromLogs builds training examples from a bid log.
+// Only impressions whose key is in shown are used (nil uses every bid), and events marks which were positive.
+// For a click model pass wins and clicks; for a conversion model pass clicks and conversions.
+func TrainingExamplesFromLogs(bidLog []*rtb.BidLogItem, shown map[string]bool, events map[string]bool) []TrainingExample {
+	examples := make([]TrainingExample, 0, len(bidLog))
+
+	for _, item := range bidLog {
+		if item.BidRequest == nil || item.BidResponse == nil {
+			continue
+		}
+
+		imps := make(map[string]*rtb.Imp, len(item.BidRequest.Imp))
+		for i := range item.BidRequest.Imp {
+			imps[item.BidRequest.Imp[i].ID] = &item.BidRequest.Imp[i]
+		}
+
+		for _, seatbid := range item.BidResponse.Seatbid {
+			for _, bid := range seatbid.Bid {
+				key := ImpressionKey(item.BidRequest.ID, bid.Impid)
+
+				if shown != nil && !shown[key] {
+					continue
+				}
+
+				features := rtb.Features(item.BidRequest, imps[bid.Impid], nil)
+				if bid.Cid != "" {
+					features = append(features, "campaign="+bid.Cid)
+				}
+
+				examples = append(examples, TrainingExample{Features: features, Positive: events[key]})
+			}
+		}
+	}
+
+	return examples
+}
@@ -0,0 +1,127 @@
+package inmemory
+
+import (
+	"bytes"
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/mocks"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func predictionRequest(os string) *rtb.BidRequest {
+	r := new(rtb.BidRequest)
+	r.Device = &rtb.Device{Os: os}
+	r.Imp = []rtb.Imp{rtb.Imp{ID: "1"}}
+
+	return r
+}
+
+// TestTrainLogisticRegression tests that a trained model predicts the rate each feature was seen with
+// Expected result is predictions close to the observed click through rates
+func TestTrainLogisticRegression(t *testing.T) {
+	examples := make([]TrainingExample, 0, 2000)
+
+	for i := 0; i < 1000; i++ {
+		examples = append(examples, TrainingExample{Features: []string{"os=iOS"}, Positive: i%2 == 0})
+		examples = append(examples, TrainingExample{Features: []string{"os=Android"}, Positive: i%10 == 0})
+	}
+
+	m := TrainLogisticRegression(examples, 20, 0.05, 0)
+
+	ios := m.Predict(predictionRequest("iOS"), nil, nil)
+	android := m.Predict(predictionRequest("Android"), nil, nil)
+
+	if math.Abs(ios-0.5) > 0.05 || math.Abs(android-0.1) > 0.05 {
+		t.Fail()
+	}
+}
+
+// TestLogisticRegressionModelReadWrite tests that a model written to a file reads back the same
+// Expected result is the same bias and weights
+func TestLogisticRegressionModelReadWrite(t *testing.T) {
+	m := &LogisticRegressionModel{Bias: -2, Weights: map[string]float64{"os=iOS": 0.5}}
+
+	var buffer bytes.Buffer
+
+	if err := m.Write(&buffer); err != nil {
+		t.FailNow()
+	}
+
+	read, err := ReadLogisticRegressionModel(&buffer)
+
+	if err != nil {
+		t.FailNow()
+	}
+
+	if read.Bias != m.Bias || read.Weights["os=iOS"] != m.Weights["os=iOS"] {
+		t.Fail()
+	}
+}
+
+// TestTrainingExamplesFromLogs tests that bid logs are joined with win and click logs
+// Expected result is one example per won impression, labelled by whether it was clicked
+func TestTrainingExamplesFromLogs(t *testing.T) {
+	bidLog := make([]*rtb.BidLogItem, 0, 3)
+
+	for i := 0; i < 3; i++ {
+		r := predictionRequest("iOS")
+		r.ID = strconv.Itoa(i)
+
+		response := &rtb.BidResponse{Seatbid: []rtb.Seatbid{rtb.Seatbid{Bid: []rtb.Bid{rtb.Bid{Impid: "1", Cid: "100"}}}}}
+
+		bidLog = append(bidLog, &rtb.BidLogItem{BidRequest: r, BidResponse: response})
+	}
+
+	wins := map[string]bool{ImpressionKey("0", "1"): true, ImpressionKey("1", "1"): true}
+	clicks := map[string]bool{ImpressionKey("1", "1"): true}
+
+	examples := TrainingExamplesFromLogs(bidLog, wins, clicks)
+
+	if len(examples) != 2 {
+		t.FailNow()
+	}
+
+	if examples[0].Positive || !examples[1].Positive {
+		t.Fail()
+	}
+}
+
+// TestBiddingRanksByEffectiveCpm tests that a CPC campaign worth more per impression outranks a CPM campaign
+// Expected result is a bid from the CPC campaign at its effective CPM
+func TestBiddingRanksByEffectiveCpm(t *testing.T) {
+	r := predictionRequest("iOS")
+
+	pacer := mocks.NewMockPacer(map[int64]bool{100: true, 101: true})
+
+	cpmCampaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(1), nil)
+	cpcCampaign := mocks.NewMockGoalCampaign(101, rtb.CpmToMicroCents(5), rtb.DollarsToMicroCents(1), nil, rtb.Goal{Type: rtb.CPCGoal, AmountInMicroCents: rtb.DollarsToMicroCents(1)})
+
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{cpmCampaign, cpcCampaign}, map[int64]int64{100: rtb.DollarsToMicroCents(1), 101: rtb.DollarsToMicroCents(1)}, map[int64]error{100: nil, 101: nil}, nil)
+
+	// Predicts a 0.2% click through rate for every impression
+	clickPredictor := &LogisticRegressionModel{Bias: math.Log(0.002 / 0.998)}
+
+	b := NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{Pacer: pacer, ClickPredictor: clickPredictor}, time.Now().UTC())
+
+	response, _, err := b.Bid()
+
+	if err != nil {
+		t.Fail()
+	}
+
+	if response == nil {
+		t.FailNow()
+	}
+
+	bid := response.Seatbid[0].Bid[0]
+
+	if bid.Cid != "101" {
+		t.Fail()
+	}
+
+	if math.Abs(bid.Price-2) > 0.0001 {
+		t.Fail()
+	}
+}
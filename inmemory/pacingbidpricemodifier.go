@@ -0,0 +1,60 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+)
+
+// PacingBidPriceModifier adjusts bids by how far a campaign is from its target spend.
+// Campaigns ahead of pace bid less, and campaigns behind pace bid more, up to a maximum CPM.
+type PacingBidPriceModifier struct {
+	pacer rtb.SpendTrackingPacer
+
+	gain                  float64
+	minMultiplier         float64
+	maxMultiplier         float64
+	maxBidCpmInMicroCents int64
+}
+
+func (m *PacingBidPriceModifier) ModifyBidCpmInMicroCents(request *rtb.BidRequest, imp *rtb.Imp, campaign rtb.Campaign, bidCpmInMicroCents int64) int64 {
+	dailyBudget := campaign.DailyBudgetInMicroCents()
+	if dailyBudget == 0 {
+		return bidCpmInMicroCents
+	}
+
+	// Positive when behind pace
+	errorFraction := float64(m.pacer.SpendErrorInMicroCents(campaign)) / float64(dailyBudget)
+
+	multiplier := 1 + m.gain*errorFraction
+
+	if multiplier < m.minMultiplier {
+		multiplier = m.minMultiplier
+	} else if multiplier > m.maxMultiplier {
+		multiplier = m.maxMultiplier
+	}
+
+	bid := int64(float64(bidCpmInMicroCents) * multiplier)
+
+	// Never raise a bid past the maximum, but don't lower a campaign that already bids above it either
+	if bid > m.maxBidCpmInMicroCents && bid > bidCpmInMicroCents {
+		if bidCpmInMicroCents > m.maxBidCpmInMicroCents {
+			return bidCpmInMicroCents
+		}
+		return m.maxBidCpmInMicroCents
+	}
+
+	return bid
+}
+
+// NewPacingBidPriceModifier creates a modifier that multiplies bids by 1 + gain * (spend error / daily budget),
+// clamped between minMultiplier and maxMultiplier, and never raised above maxBidCpmInMicroCents.
+func NewPacingBidPriceModifier(pacer rtb.SpendTrackingPacer, gain float64, minMultiplier float64, maxMultiplier float64, maxBidCpmInMicroCents int64) rtb.BidPriceModifier {
+	m := new(PacingBidPriceModifier)
+
+	m.pacer = pacer
+	m.gain = gain
+	m.minMultiplier = minMultiplier
+	m.maxMultiplier = maxMultiplier
+	m.maxBidCpmInMicroCents = maxBidCpmInMicroCents
+
+	return m
+}
@@ -0,0 +1,101 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/mocks"
+	"testing"
+	"time"
+)
+
+// TestPacingBidPriceModifierAheadOfPace tests that a campaign that has spent more than its target bids less
+// Expected result is the bid lowered in proportion to the spend error
+func TestPacingBidPriceModifierAheadOfPace(t *testing.T) {
+	campaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(100), nil)
+
+	// Ten percent of the daily budget ahead of pace
+	pacer := mocks.NewMockSpendTrackingPacer(nil, time.Minute, nil, map[int64]int64{100: -rtb.DollarsToMicroCents(10)})
+
+	m := NewPacingBidPriceModifier(pacer, 2, 0.5, 1.5, rtb.CpmToMicroCents(2))
+
+	bid := m.ModifyBidCpmInMicroCents(nil, nil, campaign, campaign.BidCpmInMicroCents())
+
+	if bid != rtb.CpmToMicroCents(0.8) {
+		t.Fail()
+	}
+}
+
+// TestPacingBidPriceModifierBehindPaceMaxCpm tests that a campaign far behind pace is raised no higher than the max CPM
+// Expected result is the bid raised to the max CPM
+func TestPacingBidPriceModifierBehindPaceMaxCpm(t *testing.T) {
+	campaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(100), nil)
+
+	pacer := mocks.NewMockSpendTrackingPacer(nil, time.Minute, nil, map[int64]int64{100: rtb.DollarsToMicroCents(50)})
+
+	m := NewPacingBidPriceModifier(pacer, 2, 0.5, 1.5, rtb.CpmToMicroCents(1.2))
+
+	bid := m.ModifyBidCpmInMicroCents(nil, nil, campaign, campaign.BidCpmInMicroCents())
+
+	if bid != rtb.CpmToMicroCents(1.2) {
+		t.Fail()
+	}
+}
+
+// TestBiddingModifiedPrice tests that the bidder bids the price returned by the bid price modifier
+// Expected result is a bid at the modified price
+func TestBiddingModifiedPrice(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = make([]rtb.Imp, 1)
+	r.Imp[0] = rtb.Imp{}
+
+	pacer := mocks.NewMockSpendTrackingPacer(map[int64]bool{100: true}, time.Minute, nil, map[int64]int64{100: rtb.DollarsToMicroCents(0.1)})
+
+	campaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(0.5), rtb.DollarsToMicroCents(1), nil)
+
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{campaign}, map[int64]int64{100: rtb.DollarsToMicroCents(1)}, map[int64]error{100: nil}, nil)
+
+	m := NewPacingBidPriceModifier(pacer, 1, 0.5, 1.5, rtb.CpmToMicroCents(2))
+
+	b := NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{Pacer: pacer, BidPriceModifier: m}, time.Now().UTC())
+
+	response, _, err := b.Bid()
+
+	if err != nil {
+		t.Fail()
+	}
+
+	if response == nil {
+		t.FailNow()
+	}
+
+	if response.Seatbid[0].Bid[0].Price != 0.55 {
+		t.Fail()
+	}
+}
+
+// TestBiddingModifiedPriceBelowFloor tests that the bidder does not bid when the modified price falls below the floor
+// Expected result is nil
+func TestBiddingModifiedPriceBelowFloor(t *testing.T) {
+	r := new(rtb.BidRequest)
+	r.Imp = make([]rtb.Imp, 1)
+	r.Imp[0] = rtb.Imp{Bidfloor: 0.45}
+
+	pacer := mocks.NewMockSpendTrackingPacer(map[int64]bool{100: true}, time.Minute, nil, map[int64]int64{100: -rtb.DollarsToMicroCents(0.2)})
+
+	campaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(0.5), rtb.DollarsToMicroCents(1), nil)
+
+	cp := mocks.NewMockCampaignProvider([]rtb.Campaign{campaign}, map[int64]int64{100: rtb.DollarsToMicroCents(1)}, map[int64]error{100: nil}, nil)
+
+	m := NewPacingBidPriceModifier(pacer, 1, 0.5, 1.5, rtb.CpmToMicroCents(2))
+
+	b := NewBidRequestBidderWithOptions(r, cp, BidRequestBidderOptions{Pacer: pacer, BidPriceModifier: m}, time.Now().UTC())
+
+	response, _, err := b.Bid()
+
+	if err != nil {
+		t.Fail()
+	}
+
+	if response != nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,230 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Bounds on the correction applied to the admission probability when actual spend drifts from target spend
+const (
+	minThrottleCorrection     = 0.001
+	maxThrottleCorrection     = 10.0
+	maxThrottleCorrectionStep = 2.0
+)
+
+type throttleState struct {
+	lastUpdate time.Time
+	requests   int64
+
+	// Exponentially weighted moving average of bid opportunities per second
+	requestRate float64
+
+	lastRemainingDailyBudgetInMicroCents int64
+	lastTargetSpendRate                  float64
+
+	correction  float64
+	probability float64
+}
+
+// ProbabilisticPacer admits each bid opportunity with a probability, rather than counting bids per segment.
+// The probability is recalculated every interval from the observed rate of bid opportunities and the spend
+// rate needed to deliver the remaining budget along the spend curve. Because budgets are shared between bidders
+// it is also corrected by how far actual spend is from target spend, so several bidders each running their
+// own pacer converge on the target together.
+type ProbabilisticPacer struct {
+	banker      rtb.Banker
+	curve       *rtb.SpendCurve
+	interval    time.Duration
+	smoothing   float64
+	defaultMode rtb.PacingMode
+
+	now func() time.Time
+
+	mutex  sync.Mutex
+	modes  map[int64]rtb.PacingMode
+	states map[int64]*throttleState
+}
+
+func (p *ProbabilisticPacer) SetPacingMode(campaignId int64, mode rtb.PacingMode) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.modes[campaignId] = mode
+}
+
+func (p *ProbabilisticPacer) PacingMode(campaignId int64) rtb.PacingMode {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.pacingMode(campaignId)
+}
+
+func (p *ProbabilisticPacer) pacingMode(campaignId int64) rtb.PacingMode {
+	if mode, ok := p.modes[campaignId]; ok {
+		return mode
+	}
+
+	return p.defaultMode
+}
+
+func (p *ProbabilisticPacer) Probability(campaignId int64) float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.pacingMode(campaignId) == rtb.PacingASAP {
+		return 1
+	}
+
+	if state, ok := p.states[campaignId]; ok {
+		return state.probability
+	}
+
+	return 1
+}
+
+// targetSpendRate returns the micro cents per second the campaign should be spending right now
+// Scheduled campaigns only spend during their active hours.
+func (p *ProbabilisticPacer) targetSpendRate(campaign rtb.Campaign, remainingDailyBudgetInMicroCents int64, now time.Time) float64 {
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	curve := p.curve
+	if schedule := rtb.CampaignSchedule(campaign); schedule != nil {
+		if curve = curve.Scheduled(now, schedule); curve == nil {
+			return 0
+		}
+	}
+
+	fractionLeft := curve.FractionBetween(now, midnight)
+	if fractionLeft <= 0 {
+		return 0
+	}
+
+	fractionPerSecond := curve.Weight(now) / time.Hour.Seconds()
+
+	return float64(remainingDailyBudgetInMicroCents) * fractionPerSecond / fractionLeft
+}
+
+func (p *ProbabilisticPacer) update(state *throttleState, campaign rtb.Campaign, now time.Time) {
+	seconds := now.Sub(state.lastUpdate).Seconds()
+
+	rate := float64(state.requests) / seconds
+	if state.requestRate == 0 {
+		state.requestRate = rate
+	} else {
+		state.requestRate = p.smoothing*rate + (1-p.smoothing)*state.requestRate
+	}
+
+	state.requests = 0
+	state.lastUpdate = now
+
+	cpi := rtb.MicroCentsPerImpression(campaign.BidCpmInMicroCents())
+	remaining := p.banker.RemainingDailyBudgetInMicroCents(campaign.Id())
+
+	// Account not yet configured, or they actually have no budget. The pacer is not needed.
+	if remaining == 0 || cpi == 0 {
+		state.probability = 1
+		state.lastRemainingDailyBudgetInMicroCents = 0
+		return
+	}
+
+	// Feedback on what was actually spent since the last update, against what we wanted to spend
+	if state.lastRemainingDailyBudgetInMicroCents >= remaining && state.lastTargetSpendRate > 0 {
+		actualSpendRate := float64(state.lastRemainingDailyBudgetInMicroCents-remaining) / seconds
+
+		step := maxThrottleCorrectionStep
+		if actualSpendRate > 0 {
+			step = state.lastTargetSpendRate / actualSpendRate
+		}
+
+		if step > maxThrottleCorrectionStep {
+			step = maxThrottleCorrectionStep
+		} else if step < 1/maxThrottleCorrectionStep {
+			step = 1 / maxThrottleCorrectionStep
+		}
+
+		state.correction *= step
+
+		if state.correction > maxThrottleCorrection {
+			state.correction = maxThrottleCorrection
+		} else if state.correction < minThrottleCorrection {
+			state.correction = minThrottleCorrection
+		}
+	}
+
+	targetSpendRate := p.targetSpendRate(campaign, remaining, now)
+
+	state.lastRemainingDailyBudgetInMicroCents = remaining
+	state.lastTargetSpendRate = targetSpendRate
+
+	if state.requestRate == 0 {
+		state.probability = 1
+		return
+	}
+
+	probability := (targetSpendRate / float64(cpi)) / state.requestRate * state.correction
+
+	if probability > 1 {
+		probability = 1
+	} else if probability < 0 {
+		probability = 0
+	}
+
+	state.probability = probability
+}
+
+func (p *ProbabilisticPacer) CanBid(campaign rtb.Campaign) bool {
+	id := campaign.Id()
+	now := p.now().UTC()
+
+	p.mutex.Lock()
+
+	if p.pacingMode(id) == rtb.PacingASAP {
+		p.mutex.Unlock()
+		return true
+	}
+
+	state, ok := p.states[id]
+	if !ok {
+		state = &throttleState{lastUpdate: now, correction: 1, probability: 1}
+		p.states[id] = state
+	}
+
+	state.requests++
+
+	if now.Sub(state.lastUpdate) >= p.interval {
+		p.update(state, campaign, now)
+	}
+
+	probability := state.probability
+
+	p.mutex.Unlock()
+
+	return rand.Float64() < probability
+}
+
+// NewProbabilisticPacer creates a throttling pacer. A nil curve spends evenly over the day.
+// The admission probability is recalculated every interval; smoothing (0 to 1] weights how quickly the
+// observed request rate follows changes in traffic.
+func NewProbabilisticPacer(banker rtb.Banker, curve *rtb.SpendCurve, interval time.Duration, smoothing float64, defaultMode rtb.PacingMode) rtb.ThrottlingPacer {
+	p := new(ProbabilisticPacer)
+
+	p.banker = banker
+
+	if curve == nil {
+		curve = rtb.NewFlatSpendCurve()
+	}
+	p.curve = curve
+
+	p.interval = interval
+	p.smoothing = smoothing
+	p.defaultMode = defaultMode
+
+	p.now = time.Now
+
+	p.modes = make(map[int64]rtb.PacingMode)
+	p.states = make(map[int64]*throttleState)
+
+	return p
+}
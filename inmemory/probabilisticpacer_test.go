@@ -0,0 +1,58 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/mocks"
+	"testing"
+	"time"
+)
+
+// TestProbabilisticPacerASAP tests that a campaign delivered as soon as possible is always admitted
+// Expected result is every bid opportunity is admitted
+func TestProbabilisticPacerASAP(t *testing.T) {
+	banker := mocks.NewMockBanker(0, nil, rtb.DollarsToMicroCents(1))
+	campaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(1), nil)
+
+	p := NewProbabilisticPacer(banker, nil, time.Second, 1, rtb.PacingEven)
+	p.SetPacingMode(100, rtb.PacingASAP)
+
+	for i := 0; i < 1000; i++ {
+		if !p.CanBid(campaign) {
+			t.FailNow()
+		}
+	}
+
+	if p.Probability(100) != 1 {
+		t.Fail()
+	}
+}
+
+// TestProbabilisticPacerEven tests that the admission probability follows observed traffic and target spend
+// Expected result is a probability of one target impression per second over the observed requests per second
+func TestProbabilisticPacerEven(t *testing.T) {
+	cpi := rtb.MicroCentsPerImpression(rtb.CpmToMicroCents(1))
+
+	// Twelve hours left in the day, with enough budget for one impression per second
+	remaining := cpi * 12 * 60 * 60
+
+	banker := mocks.NewMockBanker(0, nil, remaining)
+	campaign := mocks.NewMockCampaign(100, rtb.CpmToMicroCents(1), rtb.DollarsToMicroCents(100), nil)
+
+	p := NewProbabilisticPacer(banker, nil, time.Second, 1, rtb.PacingEven).(*ProbabilisticPacer)
+
+	now := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 999; i++ {
+		p.CanBid(campaign)
+	}
+
+	now = now.Add(time.Second)
+	p.CanBid(campaign)
+
+	probability := p.Probability(100)
+
+	if probability < 0.0009 || probability > 0.0011 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,58 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+)
+
+// SecondPriceAuction runs a second price auction between our own campaigns, so we never bid more than needed to beat
+// our next best campaign. The winner bids the runner up's price plus an increment, but never less than its floor
+// or more than it was willing to bid. A campaign with no competition in its tier bids what it was willing to bid,
+// since it still has to compete in the exchange's auction.
+type SecondPriceAuction struct {
+	incrementInMicroCents int64
+}
+
+func (a *SecondPriceAuction) Run(bids []rtb.AuctionBid) rtb.AuctionOutcome {
+	winner, runnerUp := rtb.RankAuctionBids(bids)
+
+	if winner < 0 {
+		return rtb.AuctionOutcome{Winner: -1, RunnerUp: -1}
+	}
+
+	price := bids[winner].BidCpmInMicroCents
+
+	if runnerUp >= 0 {
+		secondPrice := bids[runnerUp].BidCpmInMicroCents + a.incrementInMicroCents
+
+		if secondPrice < bids[winner].BidFloorInMicroCents {
+			secondPrice = bids[winner].BidFloorInMicroCents
+		}
+
+		if secondPrice < price {
+			price = secondPrice
+		}
+	}
+
+	return rtb.AuctionOutcome{Winner: winner, RunnerUp: runnerUp, PriceCpmInMicroCents: price}
+}
+
+func NewSecondPriceAuction(incrementInMicroCents int64) rtb.InternalAuction {
+	a := new(SecondPriceAuction)
+
+	a.incrementInMicroCents = incrementInMicroCents
+
+	return a
+}
+
+// highestBidAuction is the auction used when the bidder isn't given one. The winner bids what it was willing to bid.
+type highestBidAuction struct{}
+
+func (a highestBidAuction) Run(bids []rtb.AuctionBid) rtb.AuctionOutcome {
+	winner, runnerUp := rtb.RankAuctionBids(bids)
+
+	if winner < 0 {
+		return rtb.AuctionOutcome{Winner: -1, RunnerUp: -1}
+	}
+
+	return rtb.AuctionOutcome{Winner: winner, RunnerUp: runnerUp, PriceCpmInMicroCents: bids[winner].BidCpmInMicroCents}
+}
@@ -0,0 +1,44 @@
+package inmemory
+
+import (
+	"github.com/evandigby/rtb"
+	"testing"
+)
+
+// TestSecondPriceAuction tests that the winner bids just enough to beat the runner up in its tier
+// Expected result is the runner up's bid plus the increment, held between the floor and the winner's bid
+func TestSecondPriceAuction(t *testing.T) {
+	auction := NewSecondPriceAuction(rtb.CpmToMicroCents(0.01))
+
+	bids := []rtb.AuctionBid{
+		rtb.AuctionBid{BidCpmInMicroCents: rtb.CpmToMicroCents(1)},
+		rtb.AuctionBid{BidCpmInMicroCents: rtb.CpmToMicroCents(3)},
+		rtb.AuctionBid{BidCpmInMicroCents: rtb.CpmToMicroCents(2)},
+	}
+
+	outcome := auction.Run(bids)
+	if outcome.Winner != 1 || outcome.RunnerUp != 2 || outcome.PriceCpmInMicroCents != rtb.CpmToMicroCents(2)+rtb.CpmToMicroCents(0.01) {
+		t.Fail()
+	}
+
+	bids[1].BidFloorInMicroCents = rtb.CpmToMicroCents(2.5)
+	if auction.Run(bids).PriceCpmInMicroCents != rtb.CpmToMicroCents(2.5) {
+		t.Fail()
+	}
+
+	bids[2].BidCpmInMicroCents = rtb.CpmToMicroCents(3)
+	if auction.Run(bids).PriceCpmInMicroCents != rtb.CpmToMicroCents(3) {
+		t.Fail()
+	}
+
+	// A guaranteed campaign has no competition in its tier, and bids what it was willing to bid
+	bids[0].Priority = rtb.PriorityGuaranteed
+	outcome = auction.Run(bids)
+	if outcome.Winner != 0 || outcome.RunnerUp != -1 || outcome.PriceCpmInMicroCents != rtb.CpmToMicroCents(1) {
+		t.Fail()
+	}
+
+	if auction.Run(nil).Winner != -1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,63 @@
+package rtb
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// DefaultListBatchSize is how many members LoadList adds to a list at a time
+const DefaultListBatchSize = 100000
+
+// ListProvider stores named lists of strings, such as first party audiences of device ids
+type ListProvider interface {
+	// AddToList adds members to a list, creating it if it does not exist
+	AddToList(name string, members []string)
+
+	// ListContains returns whether member is in the list. Lists that do not exist contain nothing.
+	ListContains(name string, member string) bool
+
+	// ListSize returns the number of members in a list
+	ListSize(name string) int64
+
+	// DeleteList removes a list and all of its members
+	DeleteList(name string)
+}
+
+// LoadList adds every line read from r to a list, batchSize members at a time.
+// Blank lines are skipped. Members are lower cased if normalize is true, which suits device ids.
+// Returns the number of members read.
+func LoadList(r io.Reader, lists ListProvider, name string, batchSize int, normalize bool) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultListBatchSize
+	}
+
+	scanner := bufio.NewScanner(r)
+	batch := make([]string, 0, batchSize)
+	count := 0
+
+	for scanner.Scan() {
+		member := strings.TrimSpace(scanner.Text())
+		if member == "" {
+			continue
+		}
+
+		if normalize {
+			member = strings.ToLower(member)
+		}
+
+		batch = append(batch, member)
+		count++
+
+		if len(batch) == batchSize {
+			lists.AddToList(name, batch)
+			batch = make([]string, 0, batchSize)
+		}
+	}
+
+	if len(batch) > 0 {
+		lists.AddToList(name, batch)
+	}
+
+	return count, scanner.Err()
+}
@@ -9,6 +9,10 @@ type MockCampaign struct {
 	bidCpmInMicroCents      int64
 	dailyBudgetInMicroCents int64
 	targets                 *map[rtb.TargetType]string
+	goal                    rtb.Goal
+	creatives               []rtb.Creative
+	priority                rtb.PriorityTier
+	status                  rtb.CampaignStatus
 }
 
 func (c *MockCampaign) Id() int64 {
@@ -27,6 +31,35 @@ func (c *MockCampaign) Targets() *map[rtb.TargetType]string {
 	return c.targets
 }
 
+func (c *MockCampaign) TargetList() []rtb.Target {
+	if c.targets == nil {
+		return nil
+	}
+
+	targets := make([]rtb.Target, 0, len(*c.targets))
+	for targetType, value := range *c.targets {
+		targets = append(targets, rtb.Target{Type: targetType, Value: value})
+	}
+
+	return targets
+}
+
+func (c *MockCampaign) Goal() rtb.Goal {
+	return c.goal
+}
+
+func (c *MockCampaign) Creatives() []rtb.Creative {
+	return c.creatives
+}
+
+func (c *MockCampaign) Priority() rtb.PriorityTier {
+	return c.priority
+}
+
+func (c *MockCampaign) Status() rtb.CampaignStatus {
+	return c.status
+}
+
 func NewMockCampaign(id int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets *map[rtb.TargetType]string) rtb.Campaign {
 	c := new(MockCampaign)
 
@@ -37,3 +70,27 @@ func NewMockCampaign(id int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents
 
 	return c
 }
+
+func NewMockGoalCampaign(id int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets *map[rtb.TargetType]string, goal rtb.Goal) rtb.Campaign {
+	c := NewMockCampaign(id, bidCpmInMicroCents, dailyBudgetInMicroCents, targets).(*MockCampaign)
+
+	c.goal = goal
+
+	return c
+}
+
+func NewMockCreativeCampaign(id int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets *map[rtb.TargetType]string, creatives []rtb.Creative) rtb.Campaign {
+	c := NewMockCampaign(id, bidCpmInMicroCents, dailyBudgetInMicroCents, targets).(*MockCampaign)
+
+	c.creatives = creatives
+
+	return c
+}
+
+func NewMockPriorityCampaign(id int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets *map[rtb.TargetType]string, priority rtb.PriorityTier) rtb.Campaign {
+	c := NewMockCampaign(id, bidCpmInMicroCents, dailyBudgetInMicroCents, targets).(*MockCampaign)
+
+	c.priority = priority
+
+	return c
+}
@@ -25,6 +25,38 @@ func (cp *MockCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroCe
 	return cp.campaigns[campaignId]
 }
 
+func (cp *MockCampaignProvider) CreatePausedCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	return cp.campaigns[campaignId]
+}
+
+func (cp *MockCampaignProvider) SetCampaignGoal(campaignId int64, goal rtb.Goal) {
+
+}
+
+func (cp *MockCampaignProvider) SetCampaignCreatives(campaignId int64, creatives []rtb.Creative) {
+
+}
+
+func (cp *MockCampaignProvider) SetCampaignPriority(campaignId int64, priority rtb.PriorityTier) {
+
+}
+
+func (cp *MockCampaignProvider) UpdateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	return cp.campaigns[campaignId]
+}
+
+func (cp *MockCampaignProvider) PauseCampaign(campaignId int64) {
+
+}
+
+func (cp *MockCampaignProvider) ResumeCampaign(campaignId int64) {
+
+}
+
+func (cp *MockCampaignProvider) DeleteCampaign(campaignId int64) {
+
+}
+
 func (cp *MockCampaignProvider) ReadCampaign(campaignId int64) rtb.Campaign {
 	return cp.campaigns[campaignId]
 }
@@ -38,6 +70,15 @@ func (cp *MockCampaignProvider) ListCampaigns() []int64 {
 	return keys
 }
 
+func (cp *MockCampaignProvider) IsCampaignListed(campaignId int64) bool {
+	_, ok := cp.campaigns[campaignId]
+	return ok
+}
+
+func (cp *MockCampaignProvider) Lists() rtb.ListProvider {
+	return nil
+}
+
 // NewMockCampaignProvider creates a mock campaign.
 // debitCampaignResults returns the result mapped to the campaignId
 func NewMockCampaignProvider(readByTargetingResult []rtb.Campaign, debitCampaignResults map[int64]int64, debitCampaignErrors map[int64]error, campaigns map[int64]rtb.Campaign) rtb.CampaignProvider {
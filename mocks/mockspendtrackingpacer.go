@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"github.com/evandigby/rtb"
+	"time"
+)
+
+type MockSpendTrackingPacer struct {
+	MockPacer
+	segment                        time.Duration
+	targetSpendInMicroCentsResults map[int64]int64
+	spendErrorInMicroCentsResults  map[int64]int64
+}
+
+func (p *MockSpendTrackingPacer) Segment() time.Duration {
+	return p.segment
+}
+
+func (p *MockSpendTrackingPacer) TargetSpendInMicroCents(campaign rtb.Campaign) int64 {
+	return p.targetSpendInMicroCentsResults[campaign.Id()]
+}
+
+func (p *MockSpendTrackingPacer) SpendErrorInMicroCents(campaign rtb.Campaign) int64 {
+	return p.spendErrorInMicroCentsResults[campaign.Id()]
+}
+
+func NewMockSpendTrackingPacer(canBidResults map[int64]bool, segment time.Duration, targetSpendInMicroCentsResults map[int64]int64, spendErrorInMicroCentsResults map[int64]int64) rtb.SpendTrackingPacer {
+	p := new(MockSpendTrackingPacer)
+
+	p.canBidResults = canBidResults
+	p.segment = segment
+	p.targetSpendInMicroCentsResults = targetSpendInMicroCentsResults
+	p.spendErrorInMicroCentsResults = spendErrorInMicroCentsResults
+
+	return p
+}
@@ -0,0 +1,17 @@
+package rtb
+
+// MultiImpPolicy defines how a bidder shares the impressions of a request with several impressions between campaigns
+type MultiImpPolicy struct {
+	// OneBidPerCampaign lets each campaign bid on at most one impression of a request
+	OneBidPerCampaign bool
+	// UniqueCreatives never bids the same creative on more than one impression of a request, so a page doesn't show the same ad twice
+	UniqueCreatives bool
+	// AllImpsOnly applies the policy only to requests with allimps set, whose impressions are every impression on the page.
+	// Otherwise every request is treated as a page.
+	AllImpsOnly bool
+}
+
+// Applies returns whether the policy applies to the request
+func (p MultiImpPolicy) Applies(r *BidRequest) bool {
+	return !p.AllImpsOnly || r.Allimps == 1
+}
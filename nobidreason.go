@@ -0,0 +1,49 @@
+package rtb
+
+// NoBidReason explains why a bidder did not bid on a request.
+// The reason for a request with several impressions is the furthest any of them got through the bidder.
+// Reasons are logged by value, so new reasons are added at the end rather than in the order the bidder reaches them.
+type NoBidReason int
+
+const (
+	// NoBidReasonNone means the bidder bid, or has not bid yet
+	NoBidReasonNone NoBidReason = 0
+	// NoBidNoCampaigns means no campaign matched the request's targeting
+	NoBidNoCampaigns NoBidReason = 1
+	// NoBidCreativesBlocked means every matched campaign's creatives were blocked by bcat, badv, bapp or battr
+	NoBidCreativesBlocked NoBidReason = 2
+	// NoBidNoBudget means campaigns were eligible, but the pacer, budgets or the floor stopped all of them
+	NoBidNoBudget NoBidReason = 3
+	// NoBidMultiImpPolicy means every eligible campaign or creative had already bid on another impression of the request
+	NoBidMultiImpPolicy NoBidReason = 4
+)
+
+// How far through the bidder the request got with each reason
+var noBidReasonProgress = map[NoBidReason]int{
+	NoBidReasonNone:       0,
+	NoBidNoCampaigns:      1,
+	NoBidCreativesBlocked: 2,
+	NoBidMultiImpPolicy:   3,
+	NoBidNoBudget:         4,
+}
+
+var noBidReasonNames = map[NoBidReason]string{
+	NoBidReasonNone:       "none",
+	NoBidNoCampaigns:      "no campaigns",
+	NoBidCreativesBlocked: "creatives blocked",
+	NoBidNoBudget:         "no budget",
+	NoBidMultiImpPolicy:   "multi-imp policy",
+}
+
+func (r NoBidReason) String() string {
+	if name, ok := noBidReasonNames[r]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Further returns whether a request got further through the bidder with this reason than with other
+func (r NoBidReason) Further(other NoBidReason) bool {
+	return noBidReasonProgress[r] > noBidReasonProgress[other]
+}
@@ -0,0 +1,304 @@
+// Package conformance tests that a NoDbDataAccess behaves like the redis data access, so campaigns, budgets and pacing
+// work the same on any backend. A backend's tests call Run with a function creating an empty data access.
+package conformance
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	"github.com/evandigby/rtb/redis"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type conformanceTest struct {
+	name string
+	test func(t *testing.T, da nodb.NoDbDataAccess)
+}
+
+var conformanceTests = []conformanceTest{
+	conformanceTest{"Strings", testStrings},
+	conformanceTest{"Hashes", testHashes},
+	conformanceTest{"Sets", testSets},
+	conformanceTest{"SortedSets", testSortedSets},
+	conformanceTest{"BatchRead", testBatchRead},
+	conformanceTest{"Expiration", testExpiration},
+	conformanceTest{"DebitIfNotZero", testDebitIfNotZero},
+	conformanceTest{"Campaigns", testCampaigns},
+}
+
+// Run runs every conformance test against a new data access, which must not share keys with any other
+func Run(t *testing.T, newDataAccess func() nodb.NoDbDataAccess) {
+	for _, ct := range conformanceTests {
+		test := ct.test
+
+		t.Run(ct.name, func(t *testing.T) {
+			da := newDataAccess()
+			defer da.DeleteKeys(da.GetKeys())
+
+			test(t, da)
+		})
+	}
+}
+
+func sorted(values []string) string {
+	sortedValues := append([]string{}, values...)
+	sort.Strings(sortedValues)
+
+	return strings.Join(sortedValues, ",")
+}
+
+// Test setting, reading and deleting strings and integers
+// Expected result is values read back as they were set, and missing or deleted keys read as not found
+func testStrings(t *testing.T, da nodb.NoDbDataAccess) {
+	if ok, _ := da.GetInt64("missing"); ok {
+		t.Fail()
+	}
+
+	da.SetInt64("int", 42)
+	da.SetString("string", "value")
+
+	if ok, val := da.GetInt64("int"); !ok || val != 42 {
+		t.Fail()
+	}
+
+	if ok, val := da.GetString("string"); !ok || val != "value" {
+		t.Fail()
+	}
+
+	if sorted(da.GetKeys()) != "int,string" {
+		t.Fail()
+	}
+
+	da.DeleteKeys([]string{"int", "string"})
+
+	if ok, _ := da.GetString("string"); ok || len(da.GetKeys()) != 0 {
+		t.Fail()
+	}
+}
+
+// Test setting and reading hash fields
+// Expected result is fields read back as they were set, and missing fields and hashes read as zero
+func testHashes(t *testing.T, da nodb.NoDbDataAccess) {
+	if da.HGetInt64("hash", "missing") != 0 {
+		t.Fail()
+	}
+
+	da.HSetInt64("hash", "a", 1)
+	da.HSetInt64("hash", "b", -2)
+	da.HSetInt64("hash", "a", 3)
+
+	if da.HGetInt64("hash", "a") != 3 || da.HGetInt64("hash", "b") != -2 || da.HGetInt64("hash", "c") != 0 {
+		t.Fail()
+	}
+}
+
+// Test adding, removing and replacing set members
+// Expected result is the set holds exactly the members added, and disappears once it's empty
+func testSets(t *testing.T, da nodb.NoDbDataAccess) {
+	da.AddMembersToSet("set", []interface{}{"a", "b", 3})
+	da.AddMembersToSetInBatches("set", []interface{}{"c", "d", "e", "a"}, 2)
+
+	if sorted(da.GetSetMembers("set")) != "3,a,b,c,d,e" || da.SetCardinality("set") != 6 {
+		t.Fail()
+	}
+
+	if !da.IsSetMember("set", "3") || da.IsSetMember("set", "f") || da.IsSetMember("missing", "a") {
+		t.Fail()
+	}
+
+	da.RemoveMembersFromSet("set", []interface{}{"a", 3, "f"})
+
+	if sorted(da.GetSetMembers("set")) != "b,c,d,e" {
+		t.Fail()
+	}
+
+	da.ReplaceSetMembers("set", []interface{}{"x", "y"})
+
+	if sorted(da.GetSetMembers("set")) != "x,y" {
+		t.Fail()
+	}
+
+	da.RemoveMembersFromSet("set", []interface{}{"x", "y"})
+
+	if len(da.GetSetMembers("set")) != 0 || da.SetCardinality("set") != 0 || len(da.GetKeys()) != 0 {
+		t.Fail()
+	}
+
+	da.AddMembersToSet("set", []interface{}{"a"})
+	da.ReplaceSetMembers("set", []interface{}{})
+
+	if len(da.GetKeys()) != 0 {
+		t.Fail()
+	}
+}
+
+// Test the union of sorted sets
+// Expected result is every member once, with its highest score, from highest score to lowest and then in reverse order of
+// the member as a string, so 9 comes before 10
+func testSortedSets(t *testing.T, da nodb.NoDbDataAccess) {
+	if len(da.SortedSetUnion([]string{"missing"})) != 0 {
+		t.Fail()
+	}
+
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{
+		"first":  nodb.SortedSetMember{Score: 100, Member: "a"},
+		"second": nodb.SortedSetMember{Score: 300, Member: "a"},
+	})
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{"first": nodb.SortedSetMember{Score: 200, Member: "b"}})
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{"second": nodb.SortedSetMember{Score: 200, Member: "c"}})
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{"second": nodb.SortedSetMember{Score: 50, Member: int64(7)}})
+
+	if strings.Join(da.SortedSetUnion([]string{"first", "second", "missing"}), ",") != "a,c,b,7" {
+		t.Fail()
+	}
+
+	da.RemoveMemberFromSortedSets([]string{"first", "second"}, "a")
+
+	if strings.Join(da.SortedSetUnion([]string{"first", "second"}), ",") != "c,b,7" {
+		t.Fail()
+	}
+
+	da.RemoveMemberFromSortedSets([]string{"first"}, "b")
+
+	if sorted(da.GetKeys()) != "second" {
+		t.Fail()
+	}
+
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{"ties": nodb.SortedSetMember{Score: 50, Member: int64(10)}})
+	da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{"ties": nodb.SortedSetMember{Score: 50, Member: int64(9)}})
+
+	if strings.Join(da.SortedSetUnion([]string{"ties", "second"}), ",") != "c,9,7,10" {
+		t.Fail()
+	}
+}
+
+// Test reading hashes, sets and strings in a batch
+// Expected result is every value is read in the order of its key, and missing keys read as empty or not found
+func testBatchRead(t *testing.T, da nodb.NoDbDataAccess) {
+	da.HSetInt64("hash", "a", 1)
+	da.AddMembersToSet("set", []interface{}{"x"})
+	da.SetString("string", "value")
+
+	result := da.BatchRead([]string{"missing", "hash"}, []string{"set", "missing"}, []string{"string", "missing"})
+
+	if len(result.Hashes) != 2 || len(result.Hashes[0]) != 0 || result.Hashes[1]["a"] != "1" {
+		t.Fail()
+	}
+
+	if len(result.SetMembers) != 2 || sorted(result.SetMembers[0]) != "x" || len(result.SetMembers[1]) != 0 {
+		t.Fail()
+	}
+
+	if !result.StringsFound[0] || result.Strings[0] != "value" || result.StringsFound[1] {
+		t.Fail()
+	}
+
+	empty := da.BatchRead(nil, nil, nil)
+
+	if len(empty.Hashes) != 0 || len(empty.SetMembers) != 0 || len(empty.Strings) != 0 {
+		t.Fail()
+	}
+}
+
+// Test expiring keys
+// Expected result is keys expired in the past are gone, keys expiring in the future remain, and setting a string clears its expiration
+func testExpiration(t *testing.T, da nodb.NoDbDataAccess) {
+	da.SetString("past", "value")
+	da.AddMembersToSet("pastSet", []interface{}{"a"})
+	da.SetString("future", "value")
+
+	da.ExpireKey("past", time.Now().Add(-time.Hour))
+	da.ExpireKey("pastSet", time.Now().Add(-time.Hour))
+	da.ExpireKey("future", time.Now().Add(time.Hour))
+	da.ExpireKey("missing", time.Now().Add(time.Hour))
+
+	if ok, _ := da.GetString("past"); ok || da.IsSetMember("pastSet", "a") {
+		t.Fail()
+	}
+
+	if ok, _ := da.GetString("future"); !ok {
+		t.Fail()
+	}
+
+	if sorted(da.GetKeys()) != "future" {
+		t.Fail()
+	}
+
+	da.AddMembersToSet("pastSet", []interface{}{"b"})
+
+	if sorted(da.GetSetMembers("pastSet")) != "b" {
+		t.Fail()
+	}
+}
+
+// Test debiting accounts
+// Expected result is a missing account starts at the daily budget, debits succeed while there is enough left, credits always succeed, and the balance is always returned
+func testDebitIfNotZero(t *testing.T, da nodb.NoDbDataAccess) {
+	expiration := time.Now().Add(time.Hour)
+
+	if remaining, err := da.DebitIfNotZero("account", 30, 100, expiration); err != nil || remaining != 70 {
+		t.Fail()
+	}
+
+	if remaining, err := da.DebitIfNotZero("account", 70, 100, expiration); err != nil || remaining != 0 {
+		t.Fail()
+	}
+
+	if remaining, err := da.DebitIfNotZero("account", 1, 100, expiration); err == nil || remaining != 0 {
+		t.Fail()
+	}
+
+	// A negative amount credits the account
+	if remaining, err := da.DebitIfNotZero("account", -50, 100, expiration); err != nil || remaining != 50 {
+		t.Fail()
+	}
+
+	// An account that expired starts again at the daily budget
+	da.SetInt64("expired", 5)
+	da.ExpireKey("expired", time.Now().Add(-time.Hour))
+
+	if remaining, err := da.DebitIfNotZero("expired", 10, 20, expiration); err != nil || remaining != 10 {
+		t.Fail()
+	}
+}
+
+// Test storing, matching and debiting campaigns on the backend
+// Expected result is campaigns are matched by targeting in bid order, and debited until their budget runs out
+func testCampaigns(t *testing.T, da nodb.NoDbDataAccess) {
+	banker := redis.NewRedisBanker(da)
+	cp := redis.NewRedisCampaignProvider(da, banker)
+
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Conformance Targeting"}}
+
+	cp.CreateCampaign(1, 100, 150, targets)
+	cp.CreateCampaign(2, 200, 150, targets)
+	cp.SetCampaignCreatives(2, []rtb.Creative{rtb.Creative{ID: "2a", W: 320, H: 50}})
+
+	campaigns := cp.ReadByTargeting(0, targets)
+
+	if len(campaigns) != 2 || campaigns[0].Id() != 2 || campaigns[1].Id() != 1 || len(campaigns[0].Creatives()) != 1 {
+		t.FailNow()
+	}
+
+	cp.PauseCampaign(2)
+
+	if campaigns := cp.ReadByTargeting(0, targets); len(campaigns) != 1 || campaigns[0].Id() != 1 {
+		t.Fail()
+	}
+
+	expiration := time.Now().Add(time.Hour)
+
+	if _, err := cp.DebitCampaign(1, 100, expiration); err != nil {
+		t.Fail()
+	}
+
+	if remaining, err := cp.DebitCampaign(1, 100, expiration); err == nil || remaining != 50 {
+		t.Fail()
+	}
+
+	if banker.RemainingDailyBudgetInMicroCents(1) != 50 {
+		t.Fail()
+	}
+}
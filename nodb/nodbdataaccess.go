@@ -0,0 +1,47 @@
+// Package nodb defines the key value data access that the campaign providers, bankers and pacers of package redis are
+// built on, so they run on any backend: a redis server (package radix) or a local bolt database (package bolt).
+package nodb
+
+import (
+	"time"
+)
+
+// NoDbDataAccess stores strings, integers, hashes, sets and sorted sets under keys, all within one app domain
+type NoDbDataAccess interface {
+	DeleteKeys(keys []string)
+	HGetInt64(accountKey string, key string) int64
+	HSetInt64(accountKey string, key string, val int64)
+	GetInt64(accountKey string) (success bool, result int64)
+	SetInt64(accountKey string, val int64)
+	GetString(key string) (success bool, result string)
+	SetString(key string, val string)
+	GetSetMembers(setKey string) []string
+	BatchRead(hashKeys []string, setKeys []string, stringKeys []string) BatchReadResult
+	AddMembersToSet(setKey string, members []interface{})
+	AddMembersToSetInBatches(setKey string, members []interface{}, batchSize int)
+	RemoveMembersFromSet(setKey string, members []interface{})
+	ReplaceSetMembers(setKey string, members []interface{})
+	IsSetMember(setKey string, member string) bool
+	SetCardinality(setKey string) int64
+	AddMembersToSortedSets(keyValues map[string]SortedSetMember)
+	RemoveMemberFromSortedSets(keys []string, member interface{})
+	SortedSetUnion(keys []string) []string
+	DebitIfNotZero(accountKey string, amount int64, dailyBudget int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCents int64, err error)
+	ExpireKey(key string, expirationTime time.Time)
+	Publish(channel string, message string)
+	// Should only be used for testing
+	GetKeys() []string
+}
+
+type SortedSetMember struct {
+	Score  int64
+	Member interface{}
+}
+
+// BatchReadResult holds the values read by BatchRead, in the same order as their keys
+type BatchReadResult struct {
+	Hashes       []map[string]string
+	SetMembers   [][]string
+	Strings      []string
+	StringsFound []bool
+}
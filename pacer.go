@@ -14,3 +14,44 @@ type TimeSegmentedPacer interface {
 	Pacer
 	Segment() time.Duration
 }
+
+// Defines a time segmented pacer that steers spend towards a target and can report on its progress
+type SpendTrackingPacer interface {
+	TimeSegmentedPacer
+	// TargetSpendInMicroCents returns how much the campaign should have spent so far today
+	TargetSpendInMicroCents(campaign Campaign) int64
+	// SpendErrorInMicroCents returns the target spend minus the actual spend. Positive when the campaign is behind pace.
+	SpendErrorInMicroCents(campaign Campaign) int64
+}
+
+// PacingMode defines how a pacer should deliver a campaign's daily budget
+type PacingMode int
+
+const (
+	// PacingEven spreads delivery over the rest of the day
+	PacingEven PacingMode = 0
+	// PacingASAP bids on every opportunity until the budget is spent
+	PacingASAP PacingMode = 1
+)
+
+func (m PacingMode) String() string {
+	switch m {
+	case PacingEven:
+		return "even"
+	case PacingASAP:
+		return "asap"
+	}
+
+	return "unknown"
+}
+
+// Defines a pacer that admits each bid opportunity with a probability, and can deliver each campaign in a different mode
+type ThrottlingPacer interface {
+	Pacer
+	// SetPacingMode selects how the campaign is delivered
+	SetPacingMode(campaignId int64, mode PacingMode)
+	// PacingMode returns how the campaign is delivered
+	PacingMode(campaignId int64) PacingMode
+	// Probability returns the current probability that a bid opportunity is admitted for the campaign
+	Probability(campaignId int64) float64
+}
@@ -0,0 +1,83 @@
+package rtb
+
+import (
+	"time"
+)
+
+// PIDController is a proportional-integral-derivative feedback controller.
+// It is not safe to use from multiple goroutines.
+type PIDController struct {
+	// Proportional, integral and derivative gains
+	Kp float64
+	Ki float64
+	Kd float64
+
+	// Output is clamped between Min and Max. The integral term stops accumulating while the output is clamped.
+	Min float64
+	Max float64
+
+	integral    float64
+	lastError   float64
+	lastOutput  float64
+	initialized bool
+}
+
+// Update feeds the current error (target minus actual) into the controller and returns the new output.
+// dt is the time elapsed since the last update.
+func (c *PIDController) Update(err float64, dt time.Duration) float64 {
+	seconds := dt.Seconds()
+
+	derivative := float64(0)
+	if c.initialized && seconds > 0 {
+		derivative = (err - c.lastError) / seconds
+	}
+
+	integral := c.integral + err*seconds
+
+	output := c.Kp*err + c.Ki*integral + c.Kd*derivative
+
+	// Anti-windup: only keep the new integral when it doesn't push us further into saturation
+	if output > c.Max {
+		output = c.Max
+		if err < 0 {
+			c.integral = integral
+		}
+	} else if output < c.Min {
+		output = c.Min
+		if err > 0 {
+			c.integral = integral
+		}
+	} else {
+		c.integral = integral
+	}
+
+	c.lastError = err
+	c.lastOutput = output
+	c.initialized = true
+
+	return output
+}
+
+// Output returns the last output of the controller
+func (c *PIDController) Output() float64 {
+	return c.lastOutput
+}
+
+// Reset clears any accumulated state
+func (c *PIDController) Reset() {
+	c.integral = 0
+	c.lastError = 0
+	c.lastOutput = 0
+	c.initialized = false
+}
+
+func NewPIDController(kp float64, ki float64, kd float64, min float64, max float64) *PIDController {
+	c := new(PIDController)
+	c.Kp = kp
+	c.Ki = ki
+	c.Kd = kd
+	c.Min = min
+	c.Max = max
+
+	return c
+}
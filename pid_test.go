@@ -0,0 +1,45 @@
+package rtb
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPIDControllerProportional ensures a proportional only controller scales the error
+// Expected result is the error multiplied by Kp
+func TestPIDControllerProportional(t *testing.T) {
+	c := NewPIDController(0.5, 0, 0, -10, 10)
+
+	if c.Update(4, time.Second) != 2 {
+		t.Fail()
+	}
+}
+
+// TestPIDControllerIntegral ensures the integral term accumulates a persistent error
+// Expected result is a growing output while the error stays the same
+func TestPIDControllerIntegral(t *testing.T) {
+	c := NewPIDController(0, 1, 0, -10, 10)
+
+	first := c.Update(1, time.Second)
+	second := c.Update(1, time.Second)
+
+	if first != 1 || second != 2 {
+		t.Fail()
+	}
+}
+
+// TestPIDControllerClamped ensures output is clamped and the integral does not wind up while saturated
+// Expected result is output at the maximum, then recovering as soon as the error changes sign
+func TestPIDControllerClamped(t *testing.T) {
+	c := NewPIDController(0, 1, 0, -1, 1)
+
+	for i := 0; i < 10; i++ {
+		if c.Update(5, time.Second) != 1 {
+			t.Fail()
+		}
+	}
+
+	if c.Update(-1, time.Second) >= 1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,136 @@
+package rtb
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Kinds of placement list entries, the first column of a placement list file
+const (
+	PlacementBundle    = "bundle"
+	PlacementAppID     = "app"
+	PlacementDomain    = "domain"
+	PlacementPublisher = "publisher"
+	PlacementTagID     = "tag"
+)
+
+// The kind of list entry each placement target is looked up as
+var placementKinds = map[TargetType]string{
+	AppBundle:   PlacementBundle,
+	AppID:       PlacementAppID,
+	SiteDomain:  PlacementDomain,
+	PublisherID: PlacementPublisher,
+	TagID:       PlacementTagID,
+}
+
+// PlacementEntry returns how a placement is stored in a placement list, e.g. PlacementEntry(PlacementBundle, "com.example.app")
+func PlacementEntry(kind string, value string) string {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	value = strings.TrimSpace(value)
+
+	// Bundles and domains are not case sensitive. Ids may be.
+	if kind == PlacementBundle || kind == PlacementDomain {
+		value = strings.ToLower(value)
+	}
+
+	return kind + ":" + value
+}
+
+// PlacementAllowListTarget returns the constraint limiting a campaign to placements in any of the lists
+func PlacementAllowListTarget(lists ...string) Target {
+	return Target{Type: PlacementAllowList, Value: strings.Join(lists, ",")}
+}
+
+// PlacementBlockListTarget returns the constraint keeping a campaign off placements in any of the lists
+func PlacementBlockListTarget(lists ...string) Target {
+	return Target{Type: PlacementBlockList, Value: strings.Join(lists, ",")}
+}
+
+// placementEntries returns the list entries describing the request's placement. Domains also match their parent domains.
+func placementEntries(targets []Target) []string {
+	entries := make([]string, 0)
+
+	for _, target := range targets {
+		kind, ok := placementKinds[target.Type]
+		if !ok || target.Value == "" {
+			continue
+		}
+
+		entries = append(entries, PlacementEntry(kind, target.Value))
+
+		if target.Type == SiteDomain {
+			domain := target.Value
+			for i := strings.IndexByte(domain, '.'); i >= 0 && strings.Contains(domain[i+1:], "."); i = strings.IndexByte(domain, '.') {
+				domain = domain[i+1:]
+				entries = append(entries, PlacementEntry(kind, domain))
+			}
+		}
+	}
+
+	return entries
+}
+
+// placementListed returns whether any of the request's placement entries are in any of the lists, names separated by ","
+func placementListed(value string, targets []Target, lists ListProvider) bool {
+	entries := placementEntries(targets)
+
+	for _, name := range strings.Split(value, ",") {
+		for _, entry := range entries {
+			if lists.ListContains(name, entry) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// LoadPlacementList adds the placements in a CSV file to a list. Each record is a kind and a value, e.g. "bundle,com.example.app".
+// Lists can be shared by any number of campaigns. A header record ("kind,value") is skipped. Returns the number of placements read.
+func LoadPlacementList(r io.Reader, lists ListProvider, name string) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	batch := make([]string, 0, DefaultListBatchSize)
+	count := 0
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return count, err
+		}
+
+		kind := strings.ToLower(strings.TrimSpace(record[0]))
+
+		if kind == "kind" && count == 0 {
+			continue
+		}
+
+		switch kind {
+		case PlacementBundle, PlacementAppID, PlacementDomain, PlacementPublisher, PlacementTagID:
+		default:
+			return count, errors.New("unknown placement kind: " + record[0])
+		}
+
+		batch = append(batch, PlacementEntry(kind, record[1]))
+		count++
+
+		if len(batch) == DefaultListBatchSize {
+			lists.AddToList(name, batch)
+			batch = make([]string, 0, DefaultListBatchSize)
+		}
+	}
+
+	if len(batch) > 0 {
+		lists.AddToList(name, batch)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,24 @@
+package rtb
+
+import (
+	"testing"
+)
+
+// TestPlacementEntries ensures a request's placement is described by every identifier it has
+// Expected result is the lower cased domain and its parent domains, the publisher id and the tag id
+func TestPlacementEntries(t *testing.T) {
+	r := &BidRequest{Site: &Site{Domain: "WWW.News.Example.com", Publisher: &Publisher{ID: "Pub1"}}, Imp: []Imp{Imp{Tagid: "Top"}}}
+
+	entries := placementEntries(append(r.Targeting(), r.Imp[0].Targeting()...))
+	expected := []string{"domain:www.news.example.com", "domain:news.example.com", "domain:example.com", "publisher:Pub1", "tag:Top"}
+
+	if len(entries) != len(expected) {
+		t.FailNow()
+	}
+
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Fail()
+		}
+	}
+}
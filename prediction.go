@@ -0,0 +1,69 @@
+package rtb
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Predictor defines a type that can predict the probability of an event for an impression.
+// A click predictor predicts the probability the impression is clicked, and a conversion predictor
+// predicts the probability a click converts.
+type Predictor interface {
+	Predict(request *BidRequest, imp *Imp, campaign Campaign) float64
+}
+
+// Features returns the features of an impression used for prediction, as "name=value" strings
+func Features(r *BidRequest, imp *Imp, campaign Campaign) []string {
+	features := make([]string, 0, 6)
+
+	if campaign != nil {
+		features = append(features, "campaign="+strconv.FormatInt(campaign.Id(), 10))
+	}
+
+	if r.Device != nil {
+		if r.Device.Os != "" {
+			features = append(features, "os="+r.Device.Os)
+		}
+
+		if r.Device.Geo != nil && r.Device.Geo.Country != "" {
+			features = append(features, "country="+r.Device.Geo.Country)
+		}
+	}
+
+	if r.App != nil && r.App.Bundle != "" {
+		features = append(features, "bundle="+r.App.Bundle)
+	} else if r.Site != nil && r.Site.Domain != "" {
+		features = append(features, "domain="+r.Site.Domain)
+	}
+
+	if imp != nil && imp.Banner != nil && imp.Banner.W != 0 && imp.Banner.H != 0 {
+		features = append(features, fmt.Sprintf("size=%dx%d", imp.Banner.W, imp.Banner.H))
+	}
+
+	return features
+}
+
+// EffectiveCpmInMicroCents converts what a campaign pays for into what an impression is worth to it,
+// given the predicted click through rate and conversion rate.
+// Campaigns with CPC or CPA goals never bid more than their BidCpmInMicroCents, unless it is zero.
+func EffectiveCpmInMicroCents(campaign Campaign, clickThroughRate float64, conversionRate float64) int64 {
+	goal := campaign.Goal()
+	maxCpm := campaign.BidCpmInMicroCents()
+
+	var ecpm int64
+
+	switch goal.Type {
+	case CPCGoal:
+		ecpm = int64(float64(goal.AmountInMicroCents) * clickThroughRate * float64(CPMConversionFactor))
+	case CPAGoal:
+		ecpm = int64(float64(goal.AmountInMicroCents) * clickThroughRate * conversionRate * float64(CPMConversionFactor))
+	default:
+		return maxCpm
+	}
+
+	if maxCpm > 0 && ecpm > maxCpm {
+		return maxCpm
+	}
+
+	return ecpm
+}
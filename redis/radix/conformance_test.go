@@ -0,0 +1,18 @@
+package radix_test
+
+import (
+	"github.com/evandigby/rtb/nodb"
+	"github.com/evandigby/rtb/nodb/conformance"
+	"github.com/evandigby/rtb/redis/radix"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test the redis data access against the conformance suite
+// Expected result is every conformance test passes
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func() nodb.NoDbDataAccess {
+		return radix.NewRedisDataAccess("tcp", "localhost:6379", "conformance"+strconv.FormatInt(time.Now().UnixNano(), 36), 2)
+	})
+}
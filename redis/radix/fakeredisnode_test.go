@@ -0,0 +1,345 @@
+package radix
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedisError is sent to the client as an error reply
+type fakeRedisError string
+
+// fakeRedisStatus is sent to the client as a status reply
+type fakeRedisStatus string
+
+// fakeRedisNode is a redis server speaking just enough of the protocol to test failovers: strings, pub/sub, cluster
+// slots and sentinel master lookups. Tests change how it answers through handle.
+type fakeRedisNode struct {
+	listener net.Listener
+
+	mutex  sync.Mutex
+	values map[string]string
+	// Connections accepted so far
+	accepted int
+	conns    []*fakeRedisConn
+	// Answers a command instead of the node, when it returns true
+	handle func(args []string) (interface{}, bool)
+}
+
+type fakeRedisConn struct {
+	conn       net.Conn
+	mutex      sync.Mutex
+	subscribed map[string]bool
+}
+
+func newFakeRedisNode(t *testing.T) *fakeRedisNode {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &fakeRedisNode{listener: listener, values: make(map[string]string)}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			c := &fakeRedisConn{conn: conn, subscribed: make(map[string]bool)}
+
+			n.mutex.Lock()
+			n.accepted++
+			n.conns = append(n.conns, c)
+			n.mutex.Unlock()
+
+			go n.serve(c)
+		}
+	}()
+
+	return n
+}
+
+func (n *fakeRedisNode) addr() string {
+	return n.listener.Addr().String()
+}
+
+func (n *fakeRedisNode) hostPort() (string, int64) {
+	host, port, _ := net.SplitHostPort(n.addr())
+	p, _ := strconv.ParseInt(port, 10, 64)
+
+	return host, p
+}
+
+// Close stops the node and drops its connections, like a crashed server
+func (n *fakeRedisNode) Close() {
+	n.listener.Close()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for _, c := range n.conns {
+		c.conn.Close()
+	}
+}
+
+func (n *fakeRedisNode) value(key string) (string, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	value, ok := n.values[key]
+
+	return value, ok
+}
+
+func (n *fakeRedisNode) connections() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.accepted
+}
+
+func (n *fakeRedisNode) setHandler(handle func(args []string) (interface{}, bool)) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.handle = handle
+}
+
+// publish sends a message to the node's subscribers of a channel
+func (n *fakeRedisNode) publish(channel string, message string) {
+	n.mutex.Lock()
+	conns := append([]*fakeRedisConn{}, n.conns...)
+	n.mutex.Unlock()
+
+	for _, c := range conns {
+		c.mutex.Lock()
+		if c.subscribed[channel] {
+			writeFakeReply(c.conn, []interface{}{"message", channel, message})
+		}
+		c.mutex.Unlock()
+	}
+}
+
+func (n *fakeRedisNode) serve(c *fakeRedisConn) {
+	defer c.conn.Close()
+
+	reader := bufio.NewReader(c.conn)
+
+	for {
+		args, err := readFakeCommand(reader)
+		if err != nil {
+			return
+		}
+
+		reply := n.answer(c, args)
+
+		c.mutex.Lock()
+		writeFakeReply(c.conn, reply)
+		c.mutex.Unlock()
+	}
+}
+
+func (n *fakeRedisNode) answer(c *fakeRedisConn, args []string) interface{} {
+	n.mutex.Lock()
+	handle := n.handle
+	n.mutex.Unlock()
+
+	if handle != nil {
+		if reply, ok := handle(args); ok {
+			return reply
+		}
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return fakeRedisStatus("PONG")
+	case "GET":
+		if value, ok := n.values[args[1]]; ok {
+			return value
+		}
+
+		return nil
+	case "SET":
+		n.values[args[1]] = args[2]
+
+		return fakeRedisStatus("OK")
+	case "DEL":
+		deleted := int64(0)
+
+		for _, key := range args[1:] {
+			if _, ok := n.values[key]; ok {
+				delete(n.values, key)
+				deleted++
+			}
+		}
+
+		return deleted
+	case "PUBLISH":
+		return int64(0)
+	case "SUBSCRIBE":
+		c.mutex.Lock()
+		c.subscribed[args[1]] = true
+		c.mutex.Unlock()
+
+		return []interface{}{"subscribe", args[1], int64(1)}
+	}
+
+	return fakeRedisError("ERR unknown command '" + args[0] + "'")
+}
+
+func readFakeCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("Expected a command.")
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || count < 1 {
+		return nil, errors.New("Expected a command.")
+	}
+
+	args := make([]string, count)
+
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		arg := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(arg[:length])
+	}
+
+	return args, nil
+}
+
+func writeFakeReply(w io.Writer, reply interface{}) {
+	io.WriteString(w, encodeFakeReply(reply))
+}
+
+func encodeFakeReply(reply interface{}) string {
+	switch r := reply.(type) {
+	case nil:
+		return "$-1\r\n"
+	case fakeRedisStatus:
+		return "+" + string(r) + "\r\n"
+	case fakeRedisError:
+		return "-" + string(r) + "\r\n"
+	case int64:
+		return ":" + strconv.FormatInt(r, 10) + "\r\n"
+	case string:
+		return "$" + strconv.Itoa(len(r)) + "\r\n" + r + "\r\n"
+	case []interface{}:
+		encoded := "*" + strconv.Itoa(len(r)) + "\r\n"
+
+		for _, elem := range r {
+			encoded += encodeFakeReply(elem)
+		}
+
+		return encoded
+	}
+
+	panic("Can't encode a fake redis reply.")
+}
+
+// fakeRedisCluster assigns slots to fake nodes. Nodes answer CLUSTER SLOTS, and redirect commands on keys they don't serve.
+type fakeRedisCluster struct {
+	mutex   sync.Mutex
+	masters []*fakeRedisNode
+}
+
+func newFakeRedisCluster(nodes ...*fakeRedisNode) *fakeRedisCluster {
+	c := &fakeRedisCluster{masters: make([]*fakeRedisNode, clusterSlots)}
+
+	// The slots are split evenly between the nodes
+	for slot := range c.masters {
+		c.masters[slot] = nodes[slot*len(nodes)/clusterSlots]
+	}
+
+	for _, node := range nodes {
+		node.setHandler(c.handler(node))
+	}
+
+	return c
+}
+
+// move makes node the master of the slots in [start, end]
+func (c *fakeRedisCluster) move(start int, end int, node *fakeRedisNode) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for slot := start; slot <= end; slot++ {
+		c.masters[slot] = node
+	}
+}
+
+func (c *fakeRedisCluster) master(slot uint16) *fakeRedisNode {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.masters[slot]
+}
+
+func (c *fakeRedisCluster) slots() interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	slots := []interface{}{}
+
+	for start := 0; start < clusterSlots; {
+		end := start
+		for end+1 < clusterSlots && c.masters[end+1] == c.masters[start] {
+			end++
+		}
+
+		host, port := c.masters[start].hostPort()
+		slots = append(slots, []interface{}{int64(start), int64(end), []interface{}{host, port}})
+
+		start = end + 1
+	}
+
+	return slots
+}
+
+func (c *fakeRedisCluster) handler(node *fakeRedisNode) func(args []string) (interface{}, bool) {
+	return func(args []string) (interface{}, bool) {
+		command := strings.ToUpper(args[0])
+
+		if command == "CLUSTER" {
+			return c.slots(), true
+		}
+
+		if (command == "GET" || command == "SET" || command == "DEL") && len(args) > 1 {
+			slot := keySlot(args[1])
+
+			if master := c.master(slot); master != node {
+				return fakeRedisError("MOVED " + strconv.Itoa(int(slot)) + " " + master.addr()), true
+			}
+		}
+
+		return nil, false
+	}
+}
@@ -0,0 +1,133 @@
+package radix
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	"github.com/fzzy/radix/extra/pubsub"
+	"github.com/fzzy/radix/redis"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const campaignChangesChannel = "campaigns:changed"
+
+// How long a subscriber waits for a message before checking whether it was closed
+const subscriberTimeout = time.Second
+
+// How long a subscriber waits before reconnecting after losing its connection
+const subscriberRetryInterval = time.Second
+
+// subscriberSource opens connections for subscribers to the server a data access publishes on.
+// Only redis data accesses can, and for a sentinel or cluster, the server is found the same way as for publishing.
+type subscriberSource interface {
+	withDomainChannel(channel string) string
+	dialSubscriber(channel string, timeout time.Duration) (*redis.Client, error)
+}
+
+// RedisCampaignChangeNotifier announces campaign changes over redis pub/sub.
+// Each subscriber holds its own connection, since a subscribed connection can't be used for anything else.
+type RedisCampaignChangeNotifier struct {
+	da      nodb.NoDbDataAccess
+	source  subscriberSource
+	channel string
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (n *RedisCampaignChangeNotifier) NotifyChanged(campaignId int64) {
+	n.da.Publish(campaignChangesChannel, strconv.FormatInt(campaignId, 10))
+}
+
+func (n *RedisCampaignChangeNotifier) isClosed() bool {
+	select {
+	case <-n.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *RedisCampaignChangeNotifier) wait(d time.Duration) {
+	select {
+	case <-n.closed:
+	case <-time.After(d):
+	}
+}
+
+// receive delivers notifications from one connection until it fails or the notifier is closed
+func (n *RedisCampaignChangeNotifier) receive(client *redis.Client, changed func(campaignId int64)) {
+	defer client.Close()
+
+	sub := pubsub.NewSubClient(client)
+
+	if reply := sub.Subscribe(n.channel); reply.Err != nil {
+		log.Println("Campaign change subscription failed:", reply.Err)
+		return
+	}
+
+	// Anything could have changed while we weren't subscribed
+	changed(0)
+
+	for !n.isClosed() {
+		reply := sub.Receive()
+
+		if reply.Err != nil {
+			if reply.Timeout() {
+				continue
+			}
+
+			log.Println("Campaign change subscription lost:", reply.Err)
+			return
+		}
+
+		if reply.Type != pubsub.MessageReply {
+			continue
+		}
+
+		if campaignId, err := strconv.ParseInt(reply.Message, 10, 64); err == nil {
+			changed(campaignId)
+		}
+	}
+}
+
+func (n *RedisCampaignChangeNotifier) Subscribe(changed func(campaignId int64)) {
+	go func() {
+		for !n.isClosed() {
+			client, err := n.source.dialSubscriber(campaignChangesChannel, subscriberTimeout)
+
+			if err == nil {
+				n.receive(client, changed)
+			} else {
+				log.Println("Campaign change subscriber could not connect:", err)
+			}
+
+			n.wait(subscriberRetryInterval)
+		}
+	}()
+}
+
+func (n *RedisCampaignChangeNotifier) Close() {
+	n.closeOnce.Do(func() { close(n.closed) })
+}
+
+// NewRedisCampaignChangeNotifier creates a notifier on the redis server, sentinel master or cluster da was created for.
+// Notifiers of different app domains don't hear each other. Panics if da isn't a redis data access.
+func NewRedisCampaignChangeNotifier(da nodb.NoDbDataAccess) rtb.CampaignChangeNotifier {
+	source, ok := da.(subscriberSource)
+
+	if !ok {
+		panic("Campaign changes can only be announced over a redis data access.")
+	}
+
+	n := new(RedisCampaignChangeNotifier)
+
+	n.da = da
+	n.source = source
+	n.channel = source.withDomainChannel(campaignChangesChannel)
+	n.closed = make(chan struct{})
+
+	return n
+}
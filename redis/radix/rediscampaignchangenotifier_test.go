@@ -0,0 +1,43 @@
+package radix
+
+import (
+	"testing"
+	"time"
+)
+
+// Test subscribing to campaign changes on a sentinel managed master
+// Expected result is the subscriber connects to the master, not the sentinel, and hears the changes published there
+func TestCampaignChangeNotifierSubscribesToSentinelMaster(t *testing.T) {
+	master := newFakeRedisNode(t)
+	defer master.Close()
+
+	s := newFakeSentinel(t, "master", master)
+	defer s.Close()
+
+	n := NewRedisCampaignChangeNotifier(NewRedisSentinelDataAccess("tcp", s.addr(), "master", "app", 1))
+	defer n.Close()
+
+	changes := make(chan int64, 10)
+	n.Subscribe(func(campaignId int64) { changes <- campaignId })
+
+	// Everything is reloaded once subscribed
+	select {
+	case campaignId := <-changes:
+		if campaignId != 0 {
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.FailNow()
+	}
+
+	master.publish("app:"+campaignChangesChannel, "331")
+
+	select {
+	case campaignId := <-changes:
+		if campaignId != 331 {
+			t.Fail()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fail()
+	}
+}
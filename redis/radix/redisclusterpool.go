@@ -0,0 +1,328 @@
+package radix
+
+import (
+	"errors"
+	"github.com/evandigby/rtb/nodb"
+	"github.com/fzzy/radix/extra/pool"
+	"github.com/fzzy/radix/redis"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The number of hash slots in a redis cluster
+const clusterSlots = 16384
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum redis cluster uses to assign keys to slots
+func crc16(data string) uint16 {
+	var crc uint16
+
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc = crc << 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// keySlot returns the cluster slot of a key. Only the hash tag, the part between the first { and the following }, is hashed if there is one.
+func keySlot(key string) uint16 {
+	if start := strings.Index(key, "{"); start >= 0 {
+		if end := strings.Index(key[start+1:], "}"); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return crc16(key) % clusterSlots
+}
+
+// clusterKeyTag returns the part of a key that decides its slot in a cluster.
+// Every target index set shares one tag, since SortedSetUnion reads them together. A campaign's hash, targets and creatives
+// share the campaign's tag. Every other key, e.g. a banker or pacer account or a frequency count, is its own tag.
+func clusterKeyTag(key string) string {
+	if strings.HasPrefix(key, "targets:") {
+		return "targets"
+	}
+
+	if strings.HasPrefix(key, "campaign:") {
+		if end := strings.Index(key[len("campaign:"):], ":"); end >= 0 {
+			return key[:len("campaign:")+end]
+		}
+	}
+
+	return key
+}
+
+// clusterRedirect tells whether an error means a slot is now served by another node. If the error names the slot's new
+// master, it returns the slot and the master's address, and otherwise a slot of -1.
+func clusterRedirect(err error) (slot int, addr string, redirected bool) {
+	if err == nil {
+		return -1, "", false
+	}
+
+	if cmdErr, ok := err.(*redis.CmdError); ok {
+		message := cmdErr.Error()
+
+		if strings.HasPrefix(message, "MOVED ") {
+			if fields := strings.Fields(message); len(fields) == 3 {
+				if slot, err := strconv.Atoi(fields[1]); err == nil && slot >= 0 && slot < clusterSlots {
+					return slot, fields[2], true
+				}
+			}
+
+			return -1, "", true
+		}
+
+		// The node was demoted to a replica by a failover, or the cluster is being reconfigured
+		return -1, "", strings.HasPrefix(message, "READONLY") || strings.HasPrefix(message, "CLUSTERDOWN")
+	}
+
+	// The node went away
+	if _, ok := err.(net.Error); ok || err == io.EOF {
+		return -1, "", true
+	}
+
+	return -1, "", false
+}
+
+// redisClusterPool hands out connections to the master serving a key's slot in a redis cluster.
+// When a slot moves, e.g. because its master failed over, the command fails and the next connection goes to the new master.
+type redisClusterPool struct {
+	network  string
+	seeds    []string
+	poolSize int
+
+	mutex sync.Mutex
+	// The address of the master serving each slot, or nil when the cluster has to be asked again
+	masters []string
+	nodes   map[string]*pool.Pool
+	owners  map[*redis.Client]*pool.Pool
+}
+
+// loadSlots asks the cluster which master serves each slot. Must be called with the mutex held.
+func (p *redisClusterPool) loadSlots() error {
+	var lastErr error = errors.New("No cluster nodes to ask for the slots' masters.")
+
+	// Seeds can fail over too, so the masters we know of are asked as well
+	addrs := append([]string{}, p.seeds...)
+	for addr := range p.nodes {
+		addrs = append(addrs, addr)
+	}
+
+	for _, addr := range addrs {
+		client, err := redis.Dial(p.network, addr)
+
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		reply := client.Cmd("CLUSTER", "SLOTS")
+		client.Close()
+
+		if reply.Err != nil {
+			lastErr = reply.Err
+			continue
+		}
+
+		masters := make([]string, clusterSlots)
+
+		for _, slots := range reply.Elems {
+			if len(slots.Elems) < 3 || len(slots.Elems[2].Elems) < 2 {
+				continue
+			}
+
+			start, _ := slots.Elems[0].Int64()
+			end, _ := slots.Elems[1].Int64()
+
+			host, _ := slots.Elems[2].Elems[0].Str()
+			port, _ := slots.Elems[2].Elems[1].Int64()
+
+			// An empty host means the node we asked
+			if host == "" {
+				host, _, _ = net.SplitHostPort(addr)
+			}
+
+			master := net.JoinHostPort(host, strconv.FormatInt(port, 10))
+
+			for slot := start; slot <= end && slot < clusterSlots; slot++ {
+				masters[slot] = master
+			}
+		}
+
+		p.masters = masters
+		return nil
+	}
+
+	return lastErr
+}
+
+// master returns the address of the master serving a key's slot. Must be called with the mutex held.
+func (p *redisClusterPool) master(key string) (string, error) {
+	if p.masters == nil {
+		if err := p.loadSlots(); err != nil {
+			return "", err
+		}
+	}
+
+	slot := keySlot(key)
+
+	if p.masters[slot] == "" {
+		return "", errors.New("No cluster node serves slot " + strconv.Itoa(int(slot)) + ".")
+	}
+
+	return p.masters[slot], nil
+}
+
+// Server returns the address of the master serving a key's slot, or an empty string if it isn't known
+func (p *redisClusterPool) Server(key string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	addr, _ := p.master(key)
+
+	return addr
+}
+
+func (p *redisClusterPool) Get(key string) (*redis.Client, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	addr, err := p.master(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodePool, ok := p.nodes[addr]
+
+	if !ok {
+		if nodePool, err = pool.NewPool(p.network, addr, p.poolSize); err != nil {
+			return nil, err
+		}
+
+		p.nodes[addr] = nodePool
+	}
+
+	client, err := nodePool.Get()
+
+	if err == nil {
+		p.owners[client] = nodePool
+	}
+
+	return client, err
+}
+
+func (p *redisClusterPool) Dial(key string, timeout time.Duration) (*redis.Client, error) {
+	p.mutex.Lock()
+	addr, err := p.master(key)
+	p.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return redis.DialTimeout(p.network, addr, timeout)
+}
+
+// redirect records that a slot moved to addr. If the new master isn't known, the pool of the node that failed is
+// dropped, and the cluster is asked for the slots' masters again.
+func (p *redisClusterPool) redirect(owner *pool.Pool, slot int, addr string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if slot >= 0 && addr != "" && p.masters != nil {
+		p.masters[slot] = addr
+		return
+	}
+
+	for nodeAddr, nodePool := range p.nodes {
+		if nodePool == owner {
+			nodePool.Empty()
+			delete(p.nodes, nodeAddr)
+		}
+	}
+
+	p.masters = nil
+}
+
+// isCurrent tells whether a node's pool is still in use. Must be called with the mutex held.
+func (p *redisClusterPool) isCurrent(owner *pool.Pool) bool {
+	for _, nodePool := range p.nodes {
+		if nodePool == owner {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *redisClusterPool) CarefullyPut(client *redis.Client, potentialErr *error) {
+	p.mutex.Lock()
+	owner := p.owners[client]
+	delete(p.owners, client)
+	current := owner != nil && p.isCurrent(owner)
+	p.mutex.Unlock()
+
+	var err error
+	if potentialErr != nil {
+		err = *potentialErr
+	}
+
+	if slot, addr, redirected := clusterRedirect(err); redirected {
+		client.Close()
+		p.redirect(owner, slot, addr)
+		return
+	}
+
+	// Connections to a node that has since failed aren't reused
+	if !current {
+		client.Close()
+		return
+	}
+
+	owner.CarefullyPut(client, potentialErr)
+}
+
+func newRedisClusterPool(network string, seeds []string, poolSize int) (connectionPool, error) {
+	p := new(redisClusterPool)
+
+	p.network = network
+	p.seeds = seeds
+	p.poolSize = poolSize
+	p.nodes = make(map[string]*pool.Pool)
+	p.owners = make(map[*redis.Client]*pool.Pool)
+
+	if err := p.loadSlots(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// NewRedisClusterDataAccess creates a data access on the redis cluster that any of seeds belongs to.
+// Keys are spread over the cluster's masters by their hash tag (see clusterKeyTag), which includes the app domain. Every
+// script and transaction works on a single key, or on keys sharing a tag, so none of them cross slots. The target index
+// sets share one tag, so they are on a single master.
+func NewRedisClusterDataAccess(network string, seeds []string, appDomain string, poolSize int) nodb.NoDbDataAccess {
+	pool, err := newRedisClusterPool(network, seeds, poolSize)
+
+	if err != nil {
+		panic(err)
+	}
+
+	da := newRedisDataAccess(network, strings.Join(seeds, ","), appDomain, pool)
+	da.clustered = true
+
+	return da
+}
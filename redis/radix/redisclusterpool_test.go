@@ -0,0 +1,223 @@
+package radix
+
+import (
+	"errors"
+	"github.com/fzzy/radix/redis"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Test assigning keys to cluster slots
+// Expected result is the slots match redis cluster's, and keys with the same hash tag share a slot
+func TestKeySlot(t *testing.T) {
+	if keySlot("foo") != 12182 || keySlot("123456789") != 12739 {
+		t.Fail()
+	}
+
+	if keySlot("{user1000}.following") != keySlot("{user1000}.followers") || keySlot("{user1000}.following") != keySlot("user1000") {
+		t.Fail()
+	}
+
+	// An empty hash tag hashes the whole key
+	if keySlot("foo{}{bar}") != 8363 {
+		t.Fail()
+	}
+}
+
+// Test tagging keys for a cluster
+// Expected result is a campaign's keys share a slot, target index sets share a slot, and accounts don't share one slot
+func TestClusterKeyTag(t *testing.T) {
+	da := newRedisDataAccess("tcp", "", "app", nil)
+	da.clustered = true
+
+	if slot := keySlot(da.withDomain("campaign:1f")); slot != keySlot(da.withDomain("campaign:1f:targets")) || slot != keySlot(da.withDomain("campaign:1f:creatives")) {
+		t.Fail()
+	}
+
+	if keySlot(da.withDomain("targets:country:CAN")) != keySlot(da.withDomain("targets:os:ios")) {
+		t.Fail()
+	}
+
+	accounts := make(map[uint16]bool)
+	for account := 0; account < 10; account++ {
+		accounts[keySlot(da.withDomain("banker:account:"+strconv.Itoa(account)))] = true
+	}
+
+	if len(accounts) < 2 {
+		t.Fail()
+	}
+
+	if key := da.withDomain("campaign:1f:targets"); key != "{app:campaign:1f}:targets" || da.withoutDomain(key) != "campaign:1f:targets" {
+		t.Fail()
+	}
+}
+
+// Test recognizing errors that mean a slot moved to another node
+// Expected result is redirects and lost connections reset the pool, and other command errors don't
+func TestClusterRedirect(t *testing.T) {
+	if slot, addr, redirected := clusterRedirect(&redis.CmdError{Err: errors.New("MOVED 3999 127.0.0.1:6381")}); !redirected || slot != 3999 || addr != "127.0.0.1:6381" {
+		t.Fail()
+	}
+
+	if slot, addr, redirected := clusterRedirect(&redis.CmdError{Err: errors.New("READONLY You can't write against a read only replica.")}); !redirected || slot != -1 || addr != "" {
+		t.Fail()
+	}
+
+	if _, _, redirected := clusterRedirect(io.EOF); !redirected {
+		t.Fail()
+	}
+
+	if _, _, redirected := clusterRedirect(&redis.CmdError{Err: errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")}); redirected {
+		t.Fail()
+	}
+
+	if _, _, redirected := clusterRedirect(nil); redirected {
+		t.Fail()
+	}
+}
+
+// keyServedBy returns a key whose slot is served by node
+func keyServedBy(da *RedisDataAccess, cluster *fakeRedisCluster, node *fakeRedisNode) string {
+	for i := 0; ; i++ {
+		key := "lists:" + strconv.Itoa(i)
+
+		if cluster.master(keySlot(da.withDomain(key))) == node {
+			return key
+		}
+	}
+}
+
+// failsOnce runs f, and returns whether it panicked
+func failsOnce(f func()) (failed bool) {
+	defer func() {
+		failed = recover() != nil
+	}()
+
+	f()
+
+	return false
+}
+
+// Test reading and writing keys served by different masters of a cluster
+// Expected result is each key is written to its slot's master, and a batch read gets keys from both masters
+func TestClusterRoutesBySlot(t *testing.T) {
+	a, b := newFakeRedisNode(t), newFakeRedisNode(t)
+	defer a.Close()
+	defer b.Close()
+
+	cluster := newFakeRedisCluster(a, b)
+	da := NewRedisClusterDataAccess("tcp", []string{a.addr()}, "app", 1).(*RedisDataAccess)
+
+	onA, onB := keyServedBy(da, cluster, a), keyServedBy(da, cluster, b)
+
+	da.SetString(onA, "a")
+	da.SetString(onB, "b")
+
+	if value, ok := a.value(da.withDomain(onA)); !ok || value != "a" {
+		t.Fail()
+	}
+
+	if value, ok := b.value(da.withDomain(onB)); !ok || value != "b" {
+		t.Fail()
+	}
+
+	result := da.BatchRead(nil, nil, []string{onA, onB, "lists:missing"})
+
+	if result.Strings[0] != "a" || result.Strings[1] != "b" || !result.StringsFound[0] || !result.StringsFound[1] || result.StringsFound[2] {
+		t.Fail()
+	}
+}
+
+// Test a slot moving to another master
+// Expected result is the command on the old master fails with MOVED, and the next one goes to the new master
+func TestClusterFollowsMovedSlot(t *testing.T) {
+	a, b := newFakeRedisNode(t), newFakeRedisNode(t)
+	defer a.Close()
+	defer b.Close()
+
+	cluster := newFakeRedisCluster(a)
+	da := NewRedisClusterDataAccess("tcp", []string{a.addr()}, "app", 1).(*RedisDataAccess)
+
+	key := "lists:moved"
+	slot := int(keySlot(da.withDomain(key)))
+
+	da.SetString(key, "before")
+
+	cluster.move(slot, slot, b)
+	b.setHandler(cluster.handler(b))
+
+	if !failsOnce(func() { da.SetString(key, "after") }) {
+		t.Fail()
+	}
+
+	da.SetString(key, "after")
+
+	if value, ok := b.value(da.withDomain(key)); !ok || value != "after" {
+		t.Fail()
+	}
+
+	if value, _ := a.value(da.withDomain(key)); value != "before" {
+		t.Fail()
+	}
+}
+
+// Test a master being demoted to a replica by a failover
+// Expected result is the write to the old master fails with READONLY, and the next one goes to the master the cluster reports
+func TestClusterFollowsFailover(t *testing.T) {
+	a, b := newFakeRedisNode(t), newFakeRedisNode(t)
+	defer a.Close()
+	defer b.Close()
+
+	cluster := newFakeRedisCluster(a)
+	da := NewRedisClusterDataAccess("tcp", []string{a.addr()}, "app", 1).(*RedisDataAccess)
+
+	da.SetString("lists:failover", "before")
+
+	cluster.move(0, clusterSlots-1, b)
+	b.setHandler(cluster.handler(b))
+	a.setHandler(func(args []string) (interface{}, bool) {
+		if strings.ToUpper(args[0]) == "SET" {
+			return fakeRedisError("READONLY You can't write against a read only replica."), true
+		}
+
+		return cluster.handler(a)(args)
+	})
+
+	if !failsOnce(func() { da.SetString("lists:failover", "after") }) {
+		t.Fail()
+	}
+
+	da.SetString("lists:failover", "after")
+
+	if value, ok := b.value(da.withDomain("lists:failover")); !ok || value != "after" {
+		t.Fail()
+	}
+}
+
+// Test a master going away
+// Expected result is the command on the lost connection fails, and the next one goes to the master the other seed reports
+func TestClusterFollowsLostMaster(t *testing.T) {
+	a, b := newFakeRedisNode(t), newFakeRedisNode(t)
+	defer b.Close()
+
+	cluster := newFakeRedisCluster(a)
+	b.setHandler(cluster.handler(b))
+	da := NewRedisClusterDataAccess("tcp", []string{a.addr(), b.addr()}, "app", 1).(*RedisDataAccess)
+
+	da.SetString("lists:lost", "before")
+
+	a.Close()
+	cluster.move(0, clusterSlots-1, b)
+
+	if !failsOnce(func() { da.SetString("lists:lost", "after") }) {
+		t.Fail()
+	}
+
+	da.SetString("lists:lost", "after")
+
+	if value, ok := b.value(da.withDomain("lists:lost")); !ok || value != "after" {
+		t.Fail()
+	}
+}
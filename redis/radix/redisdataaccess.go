@@ -0,0 +1,727 @@
+// Package radix implements NoDbDataAccess with the radix client, on a redis server, a sentinel managed master or a
+// redis cluster, and announces campaign changes over redis pub/sub.
+package radix
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	"github.com/fzzy/radix/extra/pool"
+	"github.com/fzzy/radix/redis"
+	"strings"
+	"time"
+)
+
+// connectionPool hands out connections to the redis server holding a key.
+// Connections are put back with the last error they returned, so broken connections can be replaced.
+type connectionPool interface {
+	// Server returns the address of the server holding a key, so commands on keys held by the same server can be pipelined
+	Server(key string) string
+	Get(key string) (*redis.Client, error)
+	CarefullyPut(client *redis.Client, potentialErr *error)
+	// Dial opens a connection to the server holding a key outside of the pool, e.g. for a subscriber
+	Dial(key string, timeout time.Duration) (*redis.Client, error)
+}
+
+// serverPool hands out connections to a single server, which holds every key
+type serverPool struct {
+	*pool.Pool
+	network string
+	addr    string
+}
+
+func (p *serverPool) Server(key string) string {
+	return p.addr
+}
+
+func (p *serverPool) Get(key string) (*redis.Client, error) {
+	return p.Pool.Get()
+}
+
+func (p *serverPool) Dial(key string, timeout time.Duration) (*redis.Client, error) {
+	return redis.DialTimeout(p.network, p.addr, timeout)
+}
+
+type RedisDataAccess struct {
+	appDomain string
+	network   string
+	addr      string
+
+	// Prepended to every key, unless the keys are tagged for a cluster
+	keyPrefix string
+	// Whether the keys are spread over a cluster by their hash tag
+	clustered bool
+
+	pool connectionPool
+}
+
+// withDomain returns the key in the app domain. For a cluster, the app domain and the key's tag are the hash tag.
+func (da *RedisDataAccess) withDomain(accountKey string) string {
+	if !da.clustered {
+		return da.keyPrefix + accountKey
+	}
+
+	tag := clusterKeyTag(accountKey)
+
+	return "{" + da.appDomain + ":" + tag + "}" + accountKey[len(tag):]
+}
+
+func (da *RedisDataAccess) withoutDomain(key string) string {
+	if !da.clustered {
+		return strings.TrimPrefix(key, da.keyPrefix)
+	}
+
+	key = strings.TrimPrefix(key, "{"+da.appDomain+":")
+
+	return strings.Replace(key, "}", "", 1)
+}
+
+// keyCommand is a command on a single key in the app domain
+type keyCommand struct {
+	name string
+	key  string
+	args []interface{}
+}
+
+// pipeline runs the commands in one round trip per server holding their keys, and returns their replies in order
+func (da *RedisDataAccess) pipeline(commands []keyCommand) []*redis.Reply {
+	replies := make([]*redis.Reply, len(commands))
+	servers := make([]string, 0, 1)
+	byServer := make(map[string][]int)
+
+	for i, command := range commands {
+		server := da.pool.Server(command.key)
+
+		if _, ok := byServer[server]; !ok {
+			servers = append(servers, server)
+		}
+
+		byServer[server] = append(byServer[server], i)
+	}
+
+	for _, server := range servers {
+		indexes := byServer[server]
+
+		client, err := da.pool.Get(commands[indexes[0]].key)
+
+		if err != nil {
+			panic(err)
+		}
+
+		for _, i := range indexes {
+			client.Append(commands[i].name, append([]interface{}{commands[i].key}, commands[i].args...)...)
+		}
+
+		// Every reply has to be read, or the next command on this connection reads a stale one
+		for _, i := range indexes {
+			replies[i] = client.GetReply()
+
+			if replies[i].Err != nil && err == nil {
+				err = replies[i].Err
+			}
+		}
+
+		da.pool.CarefullyPut(client, &err)
+	}
+
+	return replies
+}
+
+func (da *RedisDataAccess) DeleteKeys(keys []string) {
+	commands := make([]keyCommand, len(keys))
+
+	for i, key := range keys {
+		commands[i] = keyCommand{name: "DEL", key: da.withDomain(key)}
+	}
+
+	da.pipeline(commands)
+}
+
+func (da *RedisDataAccess) HGetInt64(accountKey string, key string) int64 {
+	client, err := da.pool.Get(da.withDomain(accountKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	reply := client.Cmd("HGET", da.withDomain(accountKey), key)
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+
+	// Fields that were never set read as zero
+	if reply.Type == redis.NilReply {
+		return 0
+	}
+
+	val, err := reply.Int64()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return val
+}
+
+func (da *RedisDataAccess) HSetInt64(accountKey string, key string, val int64) {
+	client, err := da.pool.Get(da.withDomain(accountKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	reply := client.Cmd("HSET", da.withDomain(accountKey), key, val)
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+}
+
+func (da *RedisDataAccess) GetInt64(accountKey string) (success bool, result int64) {
+	client, err := da.pool.Get(da.withDomain(accountKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	reply := client.Cmd("GET", da.withDomain(accountKey))
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+
+	if reply.Type == redis.NilReply {
+		return false, 0
+	} else {
+		val, err := reply.Int64()
+
+		if err != nil {
+			panic(err)
+		}
+
+		return true, val
+	}
+}
+
+func (da *RedisDataAccess) SetInt64(accountKey string, val int64) {
+	client, err := da.pool.Get(da.withDomain(accountKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+	err = client.Cmd("SET", da.withDomain(accountKey), val).Err
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (da *RedisDataAccess) GetString(key string) (success bool, result string) {
+	client, err := da.pool.Get(da.withDomain(key))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	reply := client.Cmd("GET", da.withDomain(key))
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+
+	if reply.Type == redis.NilReply {
+		return false, ""
+	}
+
+	val, err := reply.Str()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return true, val
+}
+
+func (da *RedisDataAccess) SetString(key string, val string) {
+	client, err := da.pool.Get(da.withDomain(key))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+	err = client.Cmd("SET", da.withDomain(key), val).Err
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (da *RedisDataAccess) GetSetMembers(setKey string) []string {
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+	reply, err := client.Cmd("SMEMBERS", da.withDomain(setKey)).List()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return reply
+}
+
+// BatchRead reads whole hashes (HGETALL), set members (SMEMBERS) and strings (GET) in one pipelined round trip.
+// Missing hashes and sets read as empty, and missing strings as not found.
+func (da *RedisDataAccess) BatchRead(hashKeys []string, setKeys []string, stringKeys []string) nodb.BatchReadResult {
+	result := nodb.BatchReadResult{
+		Hashes:       make([]map[string]string, len(hashKeys)),
+		SetMembers:   make([][]string, len(setKeys)),
+		Strings:      make([]string, len(stringKeys)),
+		StringsFound: make([]bool, len(stringKeys)),
+	}
+
+	commands := make([]keyCommand, 0, len(hashKeys)+len(setKeys)+len(stringKeys))
+
+	for _, key := range hashKeys {
+		commands = append(commands, keyCommand{name: "HGETALL", key: da.withDomain(key)})
+	}
+
+	for _, key := range setKeys {
+		commands = append(commands, keyCommand{name: "SMEMBERS", key: da.withDomain(key)})
+	}
+
+	for _, key := range stringKeys {
+		commands = append(commands, keyCommand{name: "GET", key: da.withDomain(key)})
+	}
+
+	replies := da.pipeline(commands)
+
+	var err error
+
+	for i := range hashKeys {
+		if result.Hashes[i], err = replies[i].Hash(); err != nil {
+			panic(err)
+		}
+	}
+
+	replies = replies[len(hashKeys):]
+
+	for i := range setKeys {
+		if result.SetMembers[i], err = replies[i].List(); err != nil {
+			panic(err)
+		}
+	}
+
+	replies = replies[len(setKeys):]
+
+	for i, reply := range replies {
+		if reply.Err != nil {
+			panic(reply.Err)
+		}
+
+		if reply.Type == redis.NilReply {
+			continue
+		}
+
+		if result.Strings[i], err = reply.Str(); err != nil {
+			panic(err)
+		}
+
+		result.StringsFound[i] = true
+	}
+
+	return result
+}
+
+func (da *RedisDataAccess) AddMembersToSet(setKey string, members []interface{}) {
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+	values := make([]interface{}, 0, len(members)+1)
+
+	values = append(values, da.withDomain(setKey))
+
+	values = append(values, members)
+	reply := client.Cmd("SADD", values)
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+
+	//PipelineQueueEmptyError
+	for reply.Err == nil {
+		reply = client.GetReply()
+	}
+}
+
+// AddMembersToSetInBatches adds members to a set with one SADD per batch, pipelined so large sets load in a single round trip
+func (da *RedisDataAccess) AddMembersToSetInBatches(setKey string, members []interface{}, batchSize int) {
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	key := da.withDomain(setKey)
+	batches := 0
+
+	for start := 0; start < len(members); start += batchSize {
+		end := start + batchSize
+		if end > len(members) {
+			end = len(members)
+		}
+
+		client.Append("SADD", key, members[start:end])
+		batches++
+	}
+
+	for i := 0; i < batches; i++ {
+		if reply := client.GetReply(); reply.Err != nil {
+			err = reply.Err
+			panic(err)
+		}
+	}
+}
+
+func (da *RedisDataAccess) RemoveMembersFromSet(setKey string, members []interface{}) {
+	if len(members) == 0 {
+		return
+	}
+
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	reply := client.Cmd("SREM", da.withDomain(setKey), members)
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+}
+
+// ReplaceSetMembers replaces the members of a set in a transaction, so readers never see it empty or half written
+func (da *RedisDataAccess) ReplaceSetMembers(setKey string, members []interface{}) {
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	key := da.withDomain(setKey)
+	replies := 3
+
+	client.Append("MULTI")
+	client.Append("DEL", key)
+	if len(members) > 0 {
+		client.Append("SADD", key, members)
+		replies++
+	}
+	client.Append("EXEC")
+
+	for i := 0; i < replies; i++ {
+		if reply := client.GetReply(); reply.Err != nil {
+			err = reply.Err
+			panic(err)
+		}
+	}
+}
+
+func (da *RedisDataAccess) IsSetMember(setKey string, member string) bool {
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	isMember, err := client.Cmd("SISMEMBER", da.withDomain(setKey), member).Bool()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return isMember
+}
+
+func (da *RedisDataAccess) SetCardinality(setKey string) int64 {
+	client, err := da.pool.Get(da.withDomain(setKey))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	cardinality, err := client.Cmd("SCARD", da.withDomain(setKey)).Int64()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return cardinality
+}
+
+func (da *RedisDataAccess) AddMembersToSortedSets(keyValues map[string]nodb.SortedSetMember) {
+	commands := make([]keyCommand, 0, len(keyValues))
+
+	for key, value := range keyValues {
+		commands = append(commands, keyCommand{name: "ZADD", key: da.withDomain(key), args: []interface{}{value.Score, value.Member}})
+	}
+
+	for _, reply := range da.pipeline(commands) {
+		if reply.Err != nil {
+			panic(reply.Err)
+		}
+	}
+}
+
+// RemoveMemberFromSortedSets removes a member from each of the sorted sets in one round trip
+func (da *RedisDataAccess) RemoveMemberFromSortedSets(keys []string, member interface{}) {
+	commands := make([]keyCommand, len(keys))
+
+	for i, key := range keys {
+		commands[i] = keyCommand{name: "ZREM", key: da.withDomain(key), args: []interface{}{member}}
+	}
+
+	for _, reply := range da.pipeline(commands) {
+		if reply.Err != nil {
+			panic(reply.Err)
+		}
+	}
+}
+
+// unionKey returns a scratch key of its own for one union, on the same server (and cluster slot) as key
+func unionKey(key string) string {
+	id := make([]byte, 8)
+
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+
+	return key + ":union:" + hex.EncodeToString(id)
+}
+
+// SortedSetUnion returns the members of all of the sorted sets, from highest score to lowest, and members with the same
+// score in reverse order. On a cluster, the sets must share a hash tag.
+// The union is stored by ZUNIONSTORE, which runs natively instead of reading and sorting every member in a script, so a
+// large union holds up other clients for as little time as possible. Target sets hold the campaigns targeting one value,
+// expected to be at most a few thousand each, with tens of sets per request; see BenchmarkSortedSetUnion.
+// Each union is stored in a scratch key of its own, deleted in the same transaction, so concurrent unions never share one.
+func (da *RedisDataAccess) SortedSetUnion(keys []string) []string {
+	if len(keys) == 0 {
+		return []string{}
+	}
+
+	keysWithDomain := make([]interface{}, len(keys))
+
+	for i, key := range keys {
+		keysWithDomain[i] = da.withDomain(key)
+	}
+
+	server := keysWithDomain[0].(string)
+	output := unionKey(server)
+
+	client, err := da.pool.Get(server)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	client.Append("MULTI")
+	client.Append("ZUNIONSTORE", output, len(keys), keysWithDomain, "AGGREGATE", "MAX")
+	client.Append("ZREVRANGE", output, 0, -1)
+	client.Append("DEL", output)
+	client.Append("EXEC")
+
+	var reply *redis.Reply
+
+	for i := 0; i < 5; i++ {
+		if reply = client.GetReply(); reply.Err != nil {
+			err = reply.Err
+			panic(err)
+		}
+	}
+
+	if len(reply.Elems) != 3 {
+		err = errors.New("Sorted set union transaction was not executed.")
+		panic(err)
+	}
+
+	if reply.Elems[0].Err != nil {
+		err = reply.Elems[0].Err
+		panic(err)
+	}
+
+	result, err := reply.Elems[1].List()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return result
+}
+
+func (da *RedisDataAccess) ExpireKey(key string, expirationTime time.Time) {
+	client, err := da.pool.Get(da.withDomain(key))
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+	reply := client.Cmd("EXPIREAT", da.withDomain(key), expirationTime.Unix())
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+}
+
+// DebitIfNotZero debits an account if it has enough left, starting it at dailyBudget if it doesn't exist yet.
+// Starting, debiting and reading the balance happen in a single script, so they are atomic.
+func (da *RedisDataAccess) DebitIfNotZero(accountKey string, amount int64, dailyBudget int64, dailyBudgetExpiration time.Time) (remainingDailyBudgetInMicroCents int64, err error) {
+	client, connErr := da.pool.Get(da.withDomain(accountKey))
+
+	if connErr != nil {
+		panic(connErr)
+	}
+
+	defer da.pool.CarefullyPut(client, &connErr)
+
+	reply := evalScript(client, debitIfNotZeroScript, []string{da.withDomain(accountKey)}, amount, dailyBudget, dailyBudgetExpiration.Unix())
+
+	if reply.Err != nil {
+		connErr = reply.Err
+		return 0, rtb.NewTransactionError(reply.Err.Error(), false)
+	}
+
+	if len(reply.Elems) != 2 {
+		return 0, rtb.NewTransactionError("Unexpected reply from the debit script.", false)
+	}
+
+	debited, _ := reply.Elems[0].Int64()
+	remainingDailyBudgetInMicroCents, _ = reply.Elems[1].Int64()
+
+	if debited == 0 {
+		return remainingDailyBudgetInMicroCents, rtb.NewTransactionError("Insufficient daily funds.", true)
+	}
+
+	return remainingDailyBudgetInMicroCents, nil
+}
+
+// Should only be used for testing. On a cluster, only returns the keys on one of the masters.
+func (da *RedisDataAccess) GetKeys() []string {
+	pattern := da.keyPrefix + "*"
+	if da.clustered {
+		pattern = "{" + da.appDomain + ":*"
+	}
+
+	client, err := da.pool.Get(pattern)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+	reply, err := client.Cmd("KEYS", pattern).List()
+
+	if err != nil {
+		panic(err)
+	}
+
+	withoutDomain := make([]string, len(reply))
+	for i, val := range reply {
+		withoutDomain[i] = da.withoutDomain(val)
+	}
+
+	return withoutDomain
+}
+
+// withDomainChannel returns the channel in the app domain. Channels aren't keys, so they never need a hash tag.
+func (da *RedisDataAccess) withDomainChannel(channel string) string {
+	return da.appDomain + ":" + channel
+}
+
+// dialSubscriber opens a connection for a subscriber to a channel of the app domain, to the server the channel is published on
+func (da *RedisDataAccess) dialSubscriber(channel string, timeout time.Duration) (*redis.Client, error) {
+	return da.pool.Dial(da.withDomainChannel(channel), timeout)
+}
+
+func (da *RedisDataAccess) Publish(channel string, message string) {
+	// A cluster delivers messages published on any master
+	channel = da.withDomainChannel(channel)
+
+	client, err := da.pool.Get(channel)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer da.pool.CarefullyPut(client, &err)
+
+	reply := client.Cmd("PUBLISH", channel, message)
+
+	if reply.Err != nil {
+		err = reply.Err
+		panic(err)
+	}
+}
+
+func newRedisDataAccess(network string, addr string, appDomain string, pool connectionPool) *RedisDataAccess {
+	da := new(RedisDataAccess)
+	da.network = network
+	da.addr = addr
+	da.appDomain = appDomain
+	da.keyPrefix = appDomain + ":"
+
+	da.pool = pool
+	return da
+}
+
+func NewRedisDataAccess(network string, addr string, appDomain string, poolSize int) nodb.NoDbDataAccess {
+	nodePool, err := pool.NewPool(network, addr, poolSize)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return newRedisDataAccess(network, addr, appDomain, &serverPool{nodePool, network, addr})
+}
@@ -0,0 +1,168 @@
+package radix
+
+import (
+	"github.com/evandigby/rtb/nodb"
+	"github.com/fzzy/radix/redis"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test that a data access recovers when its connection is dropped by the server, e.g. because of a failover
+// Expected result is the first command on the dropped connection fails, and the next one succeeds on a new connection
+func TestReconnectAfterConnectionLoss(t *testing.T) {
+	da := NewRedisDataAccess("tcp", "localhost:6379", "reconnect"+strconv.FormatInt(time.Now().UnixNano(), 36), 1)
+	defer da.DeleteKeys([]string{"reconnect"})
+
+	da.SetString("reconnect", "before")
+
+	client, err := da.(*RedisDataAccess).pool.Get("reconnect")
+	if err != nil {
+		t.FailNow()
+	}
+
+	id, err := client.Cmd("CLIENT", "ID").Int64()
+	da.(*RedisDataAccess).pool.CarefullyPut(client, &err)
+
+	if err != nil {
+		t.FailNow()
+	}
+
+	admin, err := redis.Dial("tcp", "localhost:6379")
+	if err != nil {
+		t.FailNow()
+	}
+	defer admin.Close()
+
+	if reply := admin.Cmd("CLIENT", "KILL", "ID", id); reply.Err != nil {
+		t.FailNow()
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fail()
+			}
+		}()
+
+		da.SetString("reconnect", "during")
+	}()
+
+	da.SetString("reconnect", "after")
+
+	if ok, value := da.GetString("reconnect"); !ok || value != "after" {
+		t.Fail()
+	}
+}
+
+// Test many concurrent unions of different sorted sets, from several connections
+// Expected result is every union returns exactly the members of its own sets, in score order
+func TestSortedSetUnionConcurrency(t *testing.T) {
+	da := NewRedisDataAccess("tcp", "localhost:6379", "union"+strconv.FormatInt(time.Now().UnixNano(), 36), 8)
+
+	const workers = 16
+	const iterations = 50
+
+	keys := make([]string, 0, workers*2)
+
+	for w := 0; w < workers; w++ {
+		prefix := "union:" + strconv.Itoa(w)
+
+		da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{
+			prefix + ":a": nodb.SortedSetMember{Score: 3, Member: prefix + ":high"},
+			prefix + ":b": nodb.SortedSetMember{Score: 1, Member: prefix + ":low"},
+		})
+		da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{prefix + ":b": nodb.SortedSetMember{Score: 2, Member: prefix + ":high"}})
+
+		keys = append(keys, prefix+":a", prefix+":b")
+	}
+
+	defer da.DeleteKeys(keys)
+
+	failures := make(chan string, workers*iterations)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			prefix := "union:" + strconv.Itoa(w)
+			expected := prefix + ":high," + prefix + ":low"
+
+			for i := 0; i < iterations; i++ {
+				if result := strings.Join(da.SortedSetUnion([]string{prefix + ":a", prefix + ":b"}), ","); result != expected {
+					failures <- result
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(failures)
+
+	for result := range failures {
+		t.Error("Union returned another union's members:", result)
+	}
+}
+
+// Test a debit after the server lost its scripts, e.g. because it restarted
+// Expected result is the script is sent again and the debit succeeds
+func TestDebitIfNotZeroAfterScriptFlush(t *testing.T) {
+	da := NewRedisDataAccess("tcp", "localhost:6379", "flush"+strconv.FormatInt(time.Now().UnixNano(), 36), 1)
+	defer da.DeleteKeys([]string{"account"})
+
+	dailyBudgetExpiration := time.Now().UTC().AddDate(0, 0, 1)
+
+	if _, err := da.DebitIfNotZero("account", 10, 100, dailyBudgetExpiration); err != nil {
+		t.FailNow()
+	}
+
+	admin, err := redis.Dial("tcp", "localhost:6379")
+	if err != nil {
+		t.FailNow()
+	}
+	defer admin.Close()
+
+	if reply := admin.Cmd("SCRIPT", "FLUSH"); reply.Err != nil {
+		t.FailNow()
+	}
+
+	result, err := da.DebitIfNotZero("account", 10, 100, dailyBudgetExpiration)
+
+	if err != nil || result != 80 {
+		t.Fail()
+	}
+}
+
+// Benchmark a target union of the size expected per request: 20 target sets of 2000 campaigns each, half of them shared
+func BenchmarkSortedSetUnion(b *testing.B) {
+	da := NewRedisDataAccess("tcp", "localhost:6379", "benchunion"+strconv.FormatInt(time.Now().UnixNano(), 36), 1)
+
+	keys := make([]string, 20)
+
+	for i := range keys {
+		keys[i] = "targets:" + strconv.Itoa(i)
+
+		for member := 0; member < 2000; member++ {
+			id := int64(member)
+			if member >= 1000 {
+				id = int64(i*1000 + member)
+			}
+
+			da.AddMembersToSortedSets(map[string]nodb.SortedSetMember{keys[i]: nodb.SortedSetMember{Score: id % 500, Member: id}})
+		}
+	}
+
+	defer da.DeleteKeys(keys)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		da.SortedSetUnion(keys)
+	}
+}
@@ -0,0 +1,69 @@
+package radix
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/fzzy/radix/redis"
+	"strings"
+)
+
+// redisScript is a lua script that is run by its SHA1 digest, so it's only sent to a server that doesn't have it yet
+type redisScript struct {
+	source string
+	sha    string
+}
+
+func newRedisScript(source string) *redisScript {
+	s := new(redisScript)
+
+	digest := sha1.Sum([]byte(source))
+
+	s.source = source
+	s.sha = hex.EncodeToString(digest[:])
+
+	return s
+}
+
+// isNoScript tells whether a reply failed because the server doesn't have the script, e.g. after a restart, failover or SCRIPT FLUSH
+func isNoScript(reply *redis.Reply) bool {
+	return reply.Type == redis.ErrorReply && reply.Err != nil && strings.HasPrefix(reply.Err.Error(), "NOSCRIPT")
+}
+
+// evalScript runs a script with EVALSHA, sending the source with EVAL if the server doesn't have it
+func evalScript(client *redis.Client, script *redisScript, keys []string, args ...interface{}) *redis.Reply {
+	keysAndArgs := make([]interface{}, 0, len(keys)+len(args))
+
+	for _, key := range keys {
+		keysAndArgs = append(keysAndArgs, key)
+	}
+
+	keysAndArgs = append(keysAndArgs, args...)
+
+	reply := client.Cmd("EVALSHA", script.sha, len(keys), keysAndArgs)
+
+	if isNoScript(reply) {
+		reply = client.Cmd("EVAL", script.source, len(keys), keysAndArgs)
+	}
+
+	return reply
+}
+
+// KEYS[1] is the account key
+// ARGV[1] is the amount to debit
+// ARGV[2] is the balance a missing account starts with
+// ARGV[3] is when a missing account expires, in unix seconds
+// Returns whether the account was debited (1 or 0), and its balance afterwards
+var debitIfNotZeroScript = newRedisScript(`
+	local balance = redis.call('GET', KEYS[1])
+
+	if not balance then
+		balance = ARGV[2]
+		redis.call('SET', KEYS[1], balance)
+		redis.call('EXPIREAT', KEYS[1], ARGV[3])
+	end
+
+	if tonumber(balance) >= tonumber(ARGV[1]) then
+		return {1, redis.call('DECRBY', KEYS[1], ARGV[1])}
+	end
+
+	return {0, tonumber(balance)}`)
@@ -0,0 +1,81 @@
+package radix
+
+import (
+	"errors"
+	"github.com/evandigby/rtb/nodb"
+	"github.com/fzzy/radix/extra/sentinel"
+	"github.com/fzzy/radix/redis"
+	"net"
+	"strings"
+	"time"
+)
+
+// redisSentinelPool hands out connections to the master sentinel currently reports for a name.
+// After a failover, connections to the old master are closed as they fail, and new ones go to the new master.
+type redisSentinelPool struct {
+	client     *sentinel.Client
+	network    string
+	addr       string
+	masterName string
+}
+
+func (p *redisSentinelPool) Server(key string) string {
+	return p.masterName
+}
+
+func (p *redisSentinelPool) Get(key string) (*redis.Client, error) {
+	return p.client.GetMaster(p.masterName)
+}
+
+// Dial opens a connection to the master the sentinel currently reports
+func (p *redisSentinelPool) Dial(key string, timeout time.Duration) (*redis.Client, error) {
+	client, err := redis.DialTimeout(p.network, p.addr, timeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	master, err := client.Cmd("SENTINEL", "get-master-addr-by-name", p.masterName).List()
+	client.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(master) != 2 {
+		return nil, errors.New("The sentinel doesn't know master " + p.masterName + ".")
+	}
+
+	return redis.DialTimeout(p.network, net.JoinHostPort(master[0], master[1]), timeout)
+}
+
+func (p *redisSentinelPool) CarefullyPut(client *redis.Client, potentialErr *error) {
+	if potentialErr != nil && *potentialErr != nil {
+		cmdErr, ok := (*potentialErr).(*redis.CmdError)
+
+		// Command errors leave the connection usable, unless the master was demoted to a replica
+		if !ok || strings.HasPrefix(cmdErr.Error(), "READONLY") {
+			client.Close()
+			return
+		}
+	}
+
+	p.client.PutMaster(p.masterName, client)
+}
+
+// NewRedisSentinelDataAccess creates a data access on the master named masterName, as reported by the sentinel at network and addr
+func NewRedisSentinelDataAccess(network string, addr string, masterName string, appDomain string, poolSize int) nodb.NoDbDataAccess {
+	client, err := sentinel.NewClient(network, addr, poolSize, masterName)
+
+	if err != nil {
+		panic(err)
+	}
+
+	p := new(redisSentinelPool)
+	p.client = client
+	p.network = network
+	p.addr = addr
+	p.masterName = masterName
+
+	return newRedisDataAccess(network, addr, appDomain, p)
+}
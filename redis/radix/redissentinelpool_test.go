@@ -0,0 +1,136 @@
+package radix
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSentinel answers master lookups for one name with whichever node is currently the master
+type fakeSentinel struct {
+	*fakeRedisNode
+
+	mutex  sync.Mutex
+	name   string
+	master *fakeRedisNode
+}
+
+func newFakeSentinel(t *testing.T, name string, master *fakeRedisNode) *fakeSentinel {
+	s := &fakeSentinel{fakeRedisNode: newFakeRedisNode(t), name: name, master: master}
+
+	s.setHandler(func(args []string) (interface{}, bool) {
+		if strings.ToUpper(args[0]) != "SENTINEL" || len(args) < 3 || args[2] != s.name {
+			return nil, false
+		}
+
+		s.mutex.Lock()
+		host, port := s.master.hostPort()
+		s.mutex.Unlock()
+
+		if strings.EqualFold(args[1], "get-master-addr-by-name") {
+			return []interface{}{host, strconv.FormatInt(port, 10)}, true
+		}
+
+		return []interface{}{"name", s.name, "ip", host, "port", strconv.FormatInt(port, 10)}, true
+	})
+
+	return s
+}
+
+// failover makes node the master, and announces it to the sentinel's subscribers
+func (s *fakeSentinel) failover(node *fakeRedisNode) {
+	s.mutex.Lock()
+	oldHost, oldPort := s.master.hostPort()
+	newHost, newPort := node.hostPort()
+	s.master = node
+	s.mutex.Unlock()
+
+	s.publish("+switch-master", strings.Join([]string{s.name, oldHost, strconv.FormatInt(oldPort, 10), newHost, strconv.FormatInt(newPort, 10)}, " "))
+}
+
+// Test a sentinel promoting another master, while the old one is demoted to a replica
+// Expected result is writes fail until the new master is announced, and then go to the new master
+func TestSentinelFollowsFailover(t *testing.T) {
+	a, b := newFakeRedisNode(t), newFakeRedisNode(t)
+	defer a.Close()
+	defer b.Close()
+
+	s := newFakeSentinel(t, "master", a)
+	defer s.Close()
+
+	da := NewRedisSentinelDataAccess("tcp", s.addr(), "master", "app", 1).(*RedisDataAccess)
+
+	da.SetString("failover", "before")
+
+	a.setHandler(func(args []string) (interface{}, bool) {
+		if strings.ToUpper(args[0]) == "SET" {
+			return fakeRedisError("READONLY You can't write against a read only replica."), true
+		}
+
+		return nil, false
+	})
+
+	s.failover(b)
+
+	written := false
+	for i := 0; i < 100 && !written; i++ {
+		if written = !failsOnce(func() { da.SetString("failover", "after") }); !written {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if value, ok := b.value(da.withDomain("failover")); !written || !ok || value != "after" {
+		t.Fail()
+	}
+
+	if value, _ := a.value(da.withDomain("failover")); value != "before" {
+		t.Fail()
+	}
+}
+
+// Test putting back connections after command errors
+// Expected result is a connection is reused after an ordinary command error, and replaced after a READONLY error
+func TestSentinelPoolReplacesDemotedConnections(t *testing.T) {
+	a := newFakeRedisNode(t)
+	defer a.Close()
+
+	s := newFakeSentinel(t, "master", a)
+	defer s.Close()
+
+	da := NewRedisSentinelDataAccess("tcp", s.addr(), "master", "app", 1)
+
+	da.SetString("demoted", "before")
+	connections := a.connections()
+
+	a.setHandler(func(args []string) (interface{}, bool) {
+		return fakeRedisError("WRONGTYPE Operation against a key holding the wrong kind of value"), true
+	})
+
+	if !failsOnce(func() { da.SetString("demoted", "wrong") }) {
+		t.Fail()
+	}
+
+	a.setHandler(nil)
+	da.SetString("demoted", "reused")
+
+	if a.connections() != connections {
+		t.Fail()
+	}
+
+	a.setHandler(func(args []string) (interface{}, bool) {
+		return fakeRedisError("READONLY You can't write against a read only replica."), true
+	})
+
+	if !failsOnce(func() { da.SetString("demoted", "readonly") }) {
+		t.Fail()
+	}
+
+	a.setHandler(nil)
+	da.SetString("demoted", "replaced")
+
+	if a.connections() != connections+1 {
+		t.Fail()
+	}
+}
@@ -1,13 +1,15 @@
 package redis
 
 import (
+	"github.com/evandigby/rtb/nodb"
+	"github.com/evandigby/rtb/redis/radix"
 	"math/rand"
 	"os"
 	"testing"
 	"time"
 )
 
-var testDataAccess NoDbDataAccess
+var testDataAccess nodb.NoDbDataAccess
 
 // From: http://stackoverflow.com/questions/22892120/how-to-generate-a-random-string-of-a-fixed-length-in-golang
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
@@ -20,21 +22,21 @@ func randSeq(n int) string {
 	return string(b)
 }
 
-func AssertAllKeysGone(t *testing.T, da NoDbDataAccess) {
+func AssertAllKeysGone(t *testing.T, da nodb.NoDbDataAccess) {
 	keys := testDataAccess.GetKeys()
 	if len(testDataAccess.GetKeys()) > 0 {
 		t.Fatalf("Test did not clean itself up.", keys)
 	}
 }
 
-func DeleteAllKeys(da NoDbDataAccess) {
+func DeleteAllKeys(da nodb.NoDbDataAccess) {
 	testDataAccess.DeleteKeys(testDataAccess.GetKeys())
 }
 
 func TestMain(m *testing.M) {
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	testDataAccess = NewRedisDataAccess("tcp", "localhost:6379", randSeq(10), 1)
+	testDataAccess = radix.NewRedisDataAccess("tcp", "localhost:6379", randSeq(10), 1)
 
 	result := m.Run()
 
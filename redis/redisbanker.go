@@ -2,12 +2,13 @@ package redis
 
 import (
 	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
 	"strconv"
 	"time"
 )
 
 type RedisBanker struct {
-	da NoDbDataAccess
+	da nodb.NoDbDataAccess
 }
 
 func (b *RedisBanker) accountKey(account int64) string {
@@ -44,7 +45,7 @@ func (b *RedisBanker) SetRemainingDailyBudgetInMicroCents(account int64, amount
 	b.da.ExpireKey(accountKey, dailyBudgetExpiration)
 }
 
-func NewRedisBanker(da NoDbDataAccess) rtb.Banker {
+func NewRedisBanker(da nodb.NoDbDataAccess) rtb.Banker {
 	b := new(RedisBanker)
 	b.da = da
 
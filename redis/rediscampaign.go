@@ -1,7 +1,9 @@
 package redis
 
 import (
+	"encoding/json"
 	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
 	"strconv"
 	"strings"
 )
@@ -17,9 +19,18 @@ type RedisCampaign struct {
 	dailyBudgetInMicroCents       int64
 	dailyBudgetInMicroCentsLoaded bool
 	targets                       *map[rtb.TargetType]string
+	targetList                    []rtb.Target
 	targetsLoaded                 bool
-
-	da NoDbDataAccess
+	goal                          rtb.Goal
+	goalLoaded                    bool
+	creatives                     []rtb.Creative
+	creativesLoaded               bool
+	priority                      rtb.PriorityTier
+	priorityLoaded                bool
+	status                        rtb.CampaignStatus
+	statusLoaded                  bool
+
+	da nodb.NoDbDataAccess
 }
 
 func (c *RedisCampaign) loadBidCpmInMicroCents() {
@@ -32,12 +43,45 @@ func (c *RedisCampaign) loadDailyBudgetInMicroCents() {
 	c.dailyBudgetInMicroCentsLoaded = true
 }
 
-func (c *RedisCampaign) loadTargets() {
-	reply := c.da.GetSetMembers(c.targetSetKey)
+func (c *RedisCampaign) loadGoal() {
+	c.goal.Type = rtb.GoalType(c.da.HGetInt64(c.accountKey, "goalType"))
+	c.goal.AmountInMicroCents = c.da.HGetInt64(c.accountKey, "goalInMicroCents")
+	c.goalLoaded = true
+}
+
+func (c *RedisCampaign) loadPriority() {
+	c.priority = rtb.PriorityTier(c.da.HGetInt64(c.accountKey, "priority"))
+	c.priorityLoaded = true
+}
+
+func (c *RedisCampaign) loadStatus() {
+	c.status = rtb.CampaignStatus(c.da.HGetInt64(c.accountKey, "status"))
+	c.statusLoaded = true
+}
+
+func (c *RedisCampaign) creativesKey() string {
+	return c.accountKey + ":creatives"
+}
+
+func (c *RedisCampaign) setCreatives(found bool, value string) {
+	if found {
+		if err := json.Unmarshal([]byte(value), &c.creatives); err != nil {
+			panic(err)
+		}
+	}
+
+	c.creativesLoaded = true
+}
 
-	targets := make(map[rtb.TargetType]string, len(reply))
-	for _, target := range reply {
-		kvSplit := strings.SplitN(target, ":", 2)
+func (c *RedisCampaign) loadCreatives() {
+	c.setCreatives(c.da.GetString(c.creativesKey()))
+}
+
+// parseTargetMembers parses the members of a campaign's target set
+func parseTargetMembers(members []string) []rtb.Target {
+	targets := make([]rtb.Target, 0, len(members))
+	for _, member := range members {
+		kvSplit := strings.SplitN(member, ":", 2)
 
 		if len(kvSplit) < 2 {
 			panic("not enough strings")
@@ -49,13 +93,67 @@ func (c *RedisCampaign) loadTargets() {
 			panic(err)
 		}
 
-		targets[rtb.TargetType(key)] = kvSplit[1]
+		targets = append(targets, rtb.Target{Type: rtb.TargetType(key), Value: kvSplit[1]})
+	}
+
+	return targets
+}
+
+func (c *RedisCampaign) loadTargets() {
+	c.setTargets(parseTargetMembers(c.da.GetSetMembers(c.targetSetKey)))
+}
+
+func (c *RedisCampaign) setTargets(targetList []rtb.Target) {
+	c.targetList = targetList
+
+	targets := make(map[rtb.TargetType]string, len(c.targetList))
+	for _, target := range c.targetList {
+		targets[target.Type] = target.Value
 	}
 
 	c.targets = &targets
 	c.targetsLoaded = true
 }
 
+// hashInt64 reads a field of a campaign's hash. Fields that were never set read as zero.
+func hashInt64(hash map[string]string, field string) int64 {
+	value, ok := hash[field]
+
+	if !ok {
+		return 0
+	}
+
+	val, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return val
+}
+
+// hydrate sets every field from values read in a batch, so none of them are loaded one at a time
+func (c *RedisCampaign) hydrate(hash map[string]string, targetMembers []string, creativesFound bool, creatives string) {
+	c.bidCpmInMicroCents = hashInt64(hash, "bidCpmInMicroCents")
+	c.bidCpmInMicroCentsLoaded = true
+
+	c.dailyBudgetInMicroCents = hashInt64(hash, "dailyBudgetInMicroCents")
+	c.dailyBudgetInMicroCentsLoaded = true
+
+	c.goal.Type = rtb.GoalType(hashInt64(hash, "goalType"))
+	c.goal.AmountInMicroCents = hashInt64(hash, "goalInMicroCents")
+	c.goalLoaded = true
+
+	c.priority = rtb.PriorityTier(hashInt64(hash, "priority"))
+	c.priorityLoaded = true
+
+	c.status = rtb.CampaignStatus(hashInt64(hash, "status"))
+	c.statusLoaded = true
+
+	c.setTargets(parseTargetMembers(targetMembers))
+	c.setCreatives(creativesFound, creatives)
+}
+
 func (c *RedisCampaign) Id() int64 {
 	return c.campaignId
 }
@@ -81,7 +179,42 @@ func (c *RedisCampaign) Targets() *map[rtb.TargetType]string {
 	return c.targets
 }
 
-func NewRedisCampaign(da NoDbDataAccess, id int64, accountKey string, targetSetKey string) rtb.Campaign {
+func (c *RedisCampaign) TargetList() []rtb.Target {
+	if !c.targetsLoaded {
+		c.loadTargets()
+	}
+	return c.targetList
+}
+
+func (c *RedisCampaign) Goal() rtb.Goal {
+	if !c.goalLoaded {
+		c.loadGoal()
+	}
+	return c.goal
+}
+
+func (c *RedisCampaign) Creatives() []rtb.Creative {
+	if !c.creativesLoaded {
+		c.loadCreatives()
+	}
+	return c.creatives
+}
+
+func (c *RedisCampaign) Priority() rtb.PriorityTier {
+	if !c.priorityLoaded {
+		c.loadPriority()
+	}
+	return c.priority
+}
+
+func (c *RedisCampaign) Status() rtb.CampaignStatus {
+	if !c.statusLoaded {
+		c.loadStatus()
+	}
+	return c.status
+}
+
+func NewRedisCampaign(da nodb.NoDbDataAccess, id int64, accountKey string, targetSetKey string) rtb.Campaign {
 	c := new(RedisCampaign)
 
 	c.da = da
@@ -1,7 +1,9 @@
 package redis
 
 import (
+	"encoding/json"
 	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
 	"strconv"
 	"time"
 )
@@ -13,15 +15,20 @@ type RedisCampaignProvider struct {
 	campaignSetKey string
 
 	banker rtb.Banker
-	da     NoDbDataAccess
+	da     nodb.NoDbDataAccess
+	lists  rtb.ListProvider
 }
 
 func (cp *RedisCampaignProvider) ReadByTargeting(bidFloorInMicroCents int64, targets []rtb.Target) []rtb.Campaign {
-	keys := TargetKeysForTargets("targets:", targets)
+	keys := TargetKeysForTargets("targets:", rtb.IndexedTargets(targets))
+
+	if len(keys) == 0 {
+		return []rtb.Campaign{}
+	}
 
 	reply := cp.da.SortedSetUnion(keys)
 
-	campaigns := make([]rtb.Campaign, 0, len(reply))
+	ids := make([]int64, 0, len(reply))
 
 	for _, campaignId := range reply {
 		id, err := strconv.ParseInt(campaignId, 10, 64)
@@ -29,8 +36,34 @@ func (cp *RedisCampaignProvider) ReadByTargeting(bidFloorInMicroCents int64, tar
 			panic(err)
 		}
 
-		campaign := cp.ReadCampaign(id)
-		campaigns = append(campaigns, campaign)
+		ids = append(ids, id)
+	}
+
+	return rtb.FilterByConstraints(cp.readCampaigns(ids), targets, cp.lists)
+}
+
+// readCampaigns reads every field of each campaign in a single round trip
+func (cp *RedisCampaignProvider) readCampaigns(ids []int64) []rtb.Campaign {
+	loaded := make([]*RedisCampaign, len(ids))
+	hashKeys := make([]string, len(ids))
+	setKeys := make([]string, len(ids))
+	stringKeys := make([]string, len(ids))
+
+	for i, id := range ids {
+		loaded[i] = cp.ReadCampaign(id).(*RedisCampaign)
+
+		hashKeys[i] = loaded[i].accountKey
+		setKeys[i] = loaded[i].targetSetKey
+		stringKeys[i] = loaded[i].creativesKey()
+	}
+
+	result := cp.da.BatchRead(hashKeys, setKeys, stringKeys)
+
+	campaigns := make([]rtb.Campaign, len(ids))
+
+	for i, campaign := range loaded {
+		campaign.hydrate(result.Hashes[i], result.SetMembers[i], result.StringsFound[i], result.Strings[i])
+		campaigns[i] = campaign
 	}
 
 	return campaigns
@@ -48,29 +81,71 @@ func TargetKeysForTargets(prepend string, targets []rtb.Target) []string {
 	values := make([]string, 0, len(targets))
 
 	for _, target := range targets {
-		key := prepend + strconv.FormatInt(int64(target.Type), 10) + ":" + target.Value
-		values = append(values, key)
+		values = append(values, rtb.TargetKey(prepend, target))
 	}
 
 	return values
 }
 
-func (cp *RedisCampaignProvider) addTargetsToCampaign(targetKey string, targets []rtb.Target) {
+func targetMembers(targets []rtb.Target) []interface{} {
 	targetKeys := TargetKeysForTargets("", targets)
 	members := make([]interface{}, len(targetKeys))
 	for i, v := range targetKeys {
 		members[i] = v
 	}
 
-	cp.da.AddMembersToSet(targetKey, members)
+	return members
 }
 
-func (cp *RedisCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+func (cp *RedisCampaignProvider) addTargetsToCampaign(targetKey string, targets []rtb.Target) {
+	if len(targets) == 0 {
+		return
+	}
+
+	cp.da.AddMembersToSet(targetKey, targetMembers(targets))
+}
+
+// storedTargets reads every target in a campaign's target set. Unlike Campaign.Targets, it keeps each value of a type.
+func (cp *RedisCampaignProvider) storedTargets(targetKey string) []rtb.Target {
+	return parseTargetMembers(cp.da.GetSetMembers(targetKey))
+}
+
+// addToIndex adds the campaign to the sorted set of each of its indexed targets, or updates its score if it's already there
+func (cp *RedisCampaignProvider) addToIndex(campaignId int64, bidCpmInMicroCents int64, targets []rtb.Target) {
+	members := make(map[string]nodb.SortedSetMember, len(targets))
+
+	// Constraints are only stored with the campaign, they are checked after the index is read
+	for _, target := range rtb.IndexedTargets(targets) {
+		targetKey := rtb.TargetKey("targets:", target)
+		members[targetKey] = nodb.SortedSetMember{Member: campaignId, Score: bidCpmInMicroCents}
+	}
+
+	if len(members) > 0 {
+		cp.da.AddMembersToSortedSets(members)
+	}
+}
+
+func (cp *RedisCampaignProvider) removeFromIndex(campaignId int64, targets []rtb.Target) {
+	cp.da.RemoveMemberFromSortedSets(TargetKeysForTargets("targets:", rtb.IndexedTargets(targets)), campaignId)
+}
+
+func (cp *RedisCampaignProvider) status(accountKey string) rtb.CampaignStatus {
+	return rtb.CampaignStatus(cp.da.HGetInt64(accountKey, "status"))
+}
+
+func (cp *RedisCampaignProvider) isListed(campaignId int64) bool {
+	return cp.da.IsSetMember(cp.campaignSetKey, strconv.FormatInt(campaignId, 10))
+}
+
+// createCampaign stores a campaign with a status. It's only added to the target index once everything else is stored,
+// and only if it's active.
+func (cp *RedisCampaignProvider) createCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target, status rtb.CampaignStatus) rtb.Campaign {
 	accountKey := "campaign:" + strconv.FormatInt(campaignId, 16)
 	targetKey := accountKey + ":targets"
 
 	campaign := NewRedisCampaign(cp.da, campaignId, accountKey, targetKey)
 
+	cp.da.HSetInt64(accountKey, "status", int64(status))
 	cp.da.HSetInt64(accountKey, "bidCpmInMicroCents", bidCpmInMicroCents)
 	cp.da.HSetInt64(accountKey, "dailyBudgetInMicroCents", dailyBudgetInMicroCents)
 
@@ -78,16 +153,112 @@ func (cp *RedisCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroC
 
 	cp.da.AddMembersToSet(cp.campaignSetKey, []interface{}{campaignId})
 
-	members := make(map[string]SortedSetMember, len(targets))
+	if status == rtb.CampaignActive {
+		cp.addToIndex(campaignId, bidCpmInMicroCents, targets)
+	}
 
-	for _, target := range targets {
-		targetKey := "targets:" + strconv.FormatInt(int64(target.Type), 10) + ":" + target.Value
-		members[targetKey] = SortedSetMember{Member: campaignId, Score: bidCpmInMicroCents}
+	return campaign
+}
+
+func (cp *RedisCampaignProvider) CreateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	return cp.createCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets, rtb.CampaignActive)
+}
+
+func (cp *RedisCampaignProvider) CreatePausedCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	return cp.createCampaign(campaignId, bidCpmInMicroCents, dailyBudgetInMicroCents, targets, rtb.CampaignPaused)
+}
+
+func (cp *RedisCampaignProvider) UpdateCampaign(campaignId int64, bidCpmInMicroCents int64, dailyBudgetInMicroCents int64, targets []rtb.Target) rtb.Campaign {
+	// Archived campaigns are no longer listed
+	if !cp.isListed(campaignId) {
+		return nil
 	}
 
-	cp.da.AddMembersToSortedSets(members)
+	accountKey := cp.campaignAccountKey(campaignId)
+	targetKey := cp.campaignTargetKey(accountKey)
 
-	return campaign
+	previousTargets := cp.storedTargets(targetKey)
+
+	cp.da.HSetInt64(accountKey, "bidCpmInMicroCents", bidCpmInMicroCents)
+	cp.da.HSetInt64(accountKey, "dailyBudgetInMicroCents", dailyBudgetInMicroCents)
+	cp.da.ReplaceSetMembers(targetKey, targetMembers(targets))
+
+	if cp.status(accountKey) == rtb.CampaignActive {
+		// Add first, so the campaign is never missing from targets it still has. Adding also updates the CPM.
+		cp.addToIndex(campaignId, bidCpmInMicroCents, targets)
+
+		current := make(map[rtb.Target]bool, len(targets))
+		for _, target := range targets {
+			current[target] = true
+		}
+
+		stale := make([]rtb.Target, 0, len(previousTargets))
+		for _, target := range previousTargets {
+			if !current[target] {
+				stale = append(stale, target)
+			}
+		}
+
+		cp.removeFromIndex(campaignId, stale)
+	}
+
+	return NewRedisCampaign(cp.da, campaignId, accountKey, targetKey)
+}
+
+func (cp *RedisCampaignProvider) PauseCampaign(campaignId int64) {
+	accountKey := cp.campaignAccountKey(campaignId)
+
+	if !cp.isListed(campaignId) || cp.status(accountKey) != rtb.CampaignActive {
+		return
+	}
+
+	cp.da.HSetInt64(accountKey, "status", int64(rtb.CampaignPaused))
+	cp.removeFromIndex(campaignId, cp.storedTargets(cp.campaignTargetKey(accountKey)))
+}
+
+func (cp *RedisCampaignProvider) ResumeCampaign(campaignId int64) {
+	accountKey := cp.campaignAccountKey(campaignId)
+
+	if !cp.isListed(campaignId) || cp.status(accountKey) != rtb.CampaignPaused {
+		return
+	}
+
+	cp.da.HSetInt64(accountKey, "status", int64(rtb.CampaignActive))
+	cp.addToIndex(campaignId, cp.da.HGetInt64(accountKey, "bidCpmInMicroCents"), cp.storedTargets(cp.campaignTargetKey(accountKey)))
+}
+
+func (cp *RedisCampaignProvider) DeleteCampaign(campaignId int64) {
+	accountKey := cp.campaignAccountKey(campaignId)
+
+	if !cp.isListed(campaignId) {
+		return
+	}
+
+	// The campaign's settings are kept, so it can still be read
+	cp.da.HSetInt64(accountKey, "status", int64(rtb.CampaignArchived))
+	cp.removeFromIndex(campaignId, cp.storedTargets(cp.campaignTargetKey(accountKey)))
+	cp.da.RemoveMembersFromSet(cp.campaignSetKey, []interface{}{campaignId})
+}
+
+func (cp *RedisCampaignProvider) SetCampaignGoal(campaignId int64, goal rtb.Goal) {
+	accountKey := cp.campaignAccountKey(campaignId)
+
+	cp.da.HSetInt64(accountKey, "goalType", int64(goal.Type))
+	cp.da.HSetInt64(accountKey, "goalInMicroCents", goal.AmountInMicroCents)
+}
+
+func (cp *RedisCampaignProvider) SetCampaignCreatives(campaignId int64, creatives []rtb.Creative) {
+	js, err := json.Marshal(creatives)
+
+	if err != nil {
+		panic(err)
+	}
+
+	cp.da.SetString(cp.campaignAccountKey(campaignId)+":creatives", string(js))
+}
+
+func (cp *RedisCampaignProvider) SetCampaignPriority(campaignId int64, priority rtb.PriorityTier) {
+	cp.da.HSetInt64(cp.campaignAccountKey(campaignId), "priority", int64(priority))
 }
 
 func (cp *RedisCampaignProvider) ListCampaigns() []int64 {
@@ -106,6 +277,10 @@ func (cp *RedisCampaignProvider) ListCampaigns() []int64 {
 	return keys
 }
 
+func (cp *RedisCampaignProvider) IsCampaignListed(campaignId int64) bool {
+	return cp.isListed(campaignId)
+}
+
 func (cp *RedisCampaignProvider) campaignAccountKey(campaignId int64) string {
 	return "campaign:" + strconv.FormatInt(campaignId, 16)
 }
@@ -121,9 +296,14 @@ func (cp *RedisCampaignProvider) ReadCampaign(campaignId int64) rtb.Campaign {
 	return NewRedisCampaign(cp.da, campaignId, accountKey, targetKey)
 }
 
-func NewRedisCampaignProvider(da NoDbDataAccess, banker rtb.Banker) rtb.CampaignProvider {
+func (cp *RedisCampaignProvider) Lists() rtb.ListProvider {
+	return cp.lists
+}
+
+func NewRedisCampaignProvider(da nodb.NoDbDataAccess, banker rtb.Banker) rtb.CampaignProvider {
 	cp := new(RedisCampaignProvider)
 	cp.da = da
+	cp.lists = NewRedisListProvider(da)
 
 	cp.banker = banker
 	cp.campaignSetKey = "campaigns"
@@ -298,3 +298,248 @@ func TestDebitCampaignAccountWithEnoughRemainingDailyBudgetInMicroCents(t *testi
 		t.Fail()
 	}
 }
+
+// Test setting a campaign's goal and reading it back
+// Expected result is the campaign returns the goal it was set to
+func TestSetCampaignGoal(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(314)
+	target := rtb.Target{Type: rtb.Placement, Value: "Words With Friends 2 iPad"}
+	goal := rtb.Goal{Type: rtb.CPCGoal, AmountInMicroCents: rtb.DollarsToMicroCents(0.5)}
+
+	c := cp.CreateCampaign(campaignId, 100, 100, []rtb.Target{target})
+
+	if c.Goal().Type != rtb.CPMGoal {
+		t.Fail()
+	}
+
+	cp.SetCampaignGoal(campaignId, goal)
+
+	if cp.ReadCampaign(campaignId).Goal() != goal {
+		t.Fail()
+	}
+}
+
+// Test creating a radius targeted campaign and reading it back by location
+// Expected result is the campaign is returned inside the radius, and not outside it
+func TestReadCampaignByTargetingGeoRadius(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(315)
+	targets := rtb.GeoRadiusTargets(rtb.GeoCircle{Center: rtb.LatLon{Lat: 43.6532, Lon: -79.3832}, RadiusInKm: 10})
+
+	cp.CreateCampaign(campaignId, 100, 100, targets)
+
+	inside := (&rtb.Geo{Lat: 43.66, Lon: -79.39}).Targeting()
+	outside := (&rtb.Geo{Lat: 43.75, Lon: -79.25}).Targeting()
+
+	campaigns := cp.ReadByTargeting(0, inside)
+
+	if len(campaigns) != 1 || campaigns[0].Id() != campaignId {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, outside)) != 0 {
+		t.Fail()
+	}
+}
+
+// Test creating a campaign targeting an OS version range and reading it back by device
+// Expected result is the campaign is returned for a device in range, and not for one out of range
+func TestReadCampaignByTargetingOSVersionRange(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(316)
+	targets := []rtb.Target{rtb.Target{Type: rtb.OS, Value: "Unique OS 1"}, rtb.OSVersionRangeTarget(">=14.2", "<17")}
+
+	cp.CreateCampaign(campaignId, 100, 100, targets)
+
+	inRange := (&rtb.Device{Os: "Unique OS 1", Osv: "16.1"}).Targeting()
+	outOfRange := (&rtb.Device{Os: "Unique OS 1", Osv: "17.0.1"}).Targeting()
+
+	if len(cp.ReadByTargeting(0, inRange)) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, outOfRange)) != 0 {
+		t.Fail()
+	}
+}
+
+// Test reading a campaign targeting a data provider's segment and a first party audience list
+// Expected result is the campaign is matched for a device in the audience, regardless of the case of its id, and not for one outside it
+func TestReadCampaignByTargetingAudience(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(317)
+	targets := []rtb.Target{rtb.SegmentTarget("Unique Provider 1", "auto intenders"), rtb.AudienceTarget("Unique Audience 1")}
+
+	cp.CreateCampaign(campaignId, 100, 100, targets)
+	cp.Lists().AddToList("Unique Audience 1", []string{"6d92078a-8246-4ba4-ae5b-76104861e7dc"})
+
+	user := &rtb.User{Data: []rtb.UserData{rtb.UserData{ID: "Unique Provider 1", Segment: []rtb.Segment{rtb.Segment{ID: "auto intenders"}}}}}
+
+	inAudience := &rtb.BidRequest{User: user, Device: &rtb.Device{Ifa: "6D92078A-8246-4BA4-AE5B-76104861E7DC"}}
+	notInAudience := &rtb.BidRequest{User: user, Device: &rtb.Device{Ifa: "00000000-0000-0000-0000-000000000000"}}
+
+	if len(cp.ReadByTargeting(0, inAudience.Targeting())) != 1 {
+		t.Fail()
+	}
+
+	if len(cp.ReadByTargeting(0, notInAudience.Targeting())) != 0 {
+		t.Fail()
+	}
+}
+
+// Test setting a campaign's creatives
+// Expected result is the creatives are read back with the campaign, with their advertiser domains and size
+func TestSetCampaignCreatives(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(318)
+
+	cp.CreateCampaign(campaignId, 100, 100, []rtb.Target{})
+	cp.SetCampaignCreatives(campaignId, []rtb.Creative{rtb.Creative{ID: "318a", Adomain: []string{"example.com"}, Cat: []string{"IAB2"}, W: 320, H: 50}})
+
+	creatives := cp.ReadCampaign(campaignId).Creatives()
+
+	if len(creatives) != 1 || creatives[0].ID != "318a" || creatives[0].Adomain[0] != "example.com" || creatives[0].W != 320 {
+		t.Fail()
+	}
+}
+
+// Test pausing, resuming and deleting a campaign
+// Expected result is the campaign is only matched by targeting while active, and is no longer listed once deleted
+func TestCampaignStatusLifecycle(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(319)
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 319"}}
+
+	cp.CreateCampaign(campaignId, 100, 100, targets)
+
+	cp.PauseCampaign(campaignId)
+	if len(cp.ReadByTargeting(0, targets)) != 0 || cp.ReadCampaign(campaignId).Status() != rtb.CampaignPaused {
+		t.Fail()
+	}
+
+	cp.ResumeCampaign(campaignId)
+	if len(cp.ReadByTargeting(0, targets)) != 1 {
+		t.Fail()
+	}
+
+	cp.DeleteCampaign(campaignId)
+	if len(cp.ReadByTargeting(0, targets)) != 0 || cp.ReadCampaign(campaignId).Status() != rtb.CampaignArchived {
+		t.Fail()
+	}
+
+	for _, id := range cp.ListCampaigns() {
+		if id == campaignId {
+			t.Fail()
+		}
+	}
+
+	if cp.UpdateCampaign(campaignId, 100, 100, targets) != nil {
+		t.Fail()
+	}
+}
+
+// Test updating a campaign's targets and CPM
+// Expected result is the campaign is removed from the index of targets it no longer has, and scored by its new CPM
+func TestUpdateCampaign(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(320)
+	oldTargets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 320"}, rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 321"}}
+	newTargets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 321"}, rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 322"}}
+
+	cp.CreateCampaign(campaignId, 100, 100, oldTargets)
+	cp.CreateCampaign(campaignId+1, 200, 100, newTargets[1:])
+
+	c := cp.UpdateCampaign(campaignId, 300, 100, newTargets)
+	if c == nil || c.BidCpmInMicroCents() != 300 {
+		t.FailNow()
+	}
+
+	if len(cp.ReadByTargeting(0, oldTargets[:1])) != 0 || len(cp.ReadByTargeting(0, newTargets[:1])) != 1 {
+		t.Fail()
+	}
+
+	campaigns := cp.ReadByTargeting(0, newTargets[1:])
+	if len(campaigns) != 2 || campaigns[0].Id() != campaignId {
+		t.Fail()
+	}
+}
+
+// Test that campaigns matched by targeting are read in full up front
+// Expected result is every field of each campaign matches what was stored, including ones that were never set
+func TestReadByTargetingHydratesCampaigns(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(322)
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Hydrated Targeting 322"}, rtb.Target{Type: rtb.Country, Value: "CAN"}}
+	goal := rtb.Goal{Type: rtb.CPCGoal, AmountInMicroCents: 500}
+
+	cp.CreateCampaign(campaignId, 200, 1000, targets)
+	cp.SetCampaignGoal(campaignId, goal)
+	cp.SetCampaignPriority(campaignId, rtb.PriorityGuaranteed)
+	cp.SetCampaignCreatives(campaignId, []rtb.Creative{rtb.Creative{ID: "322a", W: 320, H: 50}})
+	cp.CreateCampaign(campaignId+1, 100, 1000, targets[:1])
+
+	campaigns := cp.ReadByTargeting(0, targets[:1])
+
+	if len(campaigns) != 2 || campaigns[0].Id() != campaignId || campaigns[1].Id() != campaignId+1 {
+		t.FailNow()
+	}
+
+	c := campaigns[0]
+
+	if c.BidCpmInMicroCents() != 200 || c.DailyBudgetInMicroCents() != 1000 || c.Goal() != goal || c.Priority() != rtb.PriorityGuaranteed || c.Status() != rtb.CampaignActive {
+		t.Fail()
+	}
+
+	if len(c.TargetList()) != 2 || (*c.Targets())[rtb.Country] != "CAN" {
+		t.Fail()
+	}
+
+	if len(c.Creatives()) != 1 || c.Creatives()[0].ID != "322a" {
+		t.Fail()
+	}
+
+	c = campaigns[1]
+
+	if c.BidCpmInMicroCents() != 100 || c.Goal() != (rtb.Goal{}) || c.Priority() != rtb.PriorityOpen || len(c.Creatives()) != 0 || len(c.TargetList()) != 1 {
+		t.Fail()
+	}
+}
+
+// Test creating a paused campaign
+// Expected result is the campaign is stored paused and only matched by targeting once it is resumed
+func TestCreatePausedCampaign(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	cp := NewRedisCampaignProvider(testDataAccess, b)
+
+	campaignId := int64(330)
+	targets := []rtb.Target{rtb.Target{Type: rtb.Placement, Value: "Unique Targeting 330"}}
+
+	cp.CreatePausedCampaign(campaignId, 100, 100, targets)
+
+	if len(cp.ReadByTargeting(0, targets)) != 0 || cp.ReadCampaign(campaignId).Status() != rtb.CampaignPaused {
+		t.Fail()
+	}
+
+	cp.ResumeCampaign(campaignId)
+
+	if len(cp.ReadByTargeting(0, targets)) != 1 {
+		t.Fail()
+	}
+}
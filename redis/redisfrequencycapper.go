@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	"strconv"
+	"time"
+)
+
+// RedisFrequencyCapper counts impressions per campaign and user with the same script as budgets: each count starts at the cap
+// and is debited once per impression, until it expires a period after the user's first impression.
+type RedisFrequencyCapper struct {
+	maxImpressions int64
+	period         time.Duration
+
+	da nodb.NoDbDataAccess
+}
+
+func (c *RedisFrequencyCapper) countKey(campaignId int64, userId string) string {
+	return "frequency:" + strconv.FormatInt(campaignId, 16) + ":" + userId
+}
+
+func (c *RedisFrequencyCapper) Allow(campaignId int64, userId string) bool {
+	_, err := c.da.DebitIfNotZero(c.countKey(campaignId, userId), 1, c.maxImpressions, time.Now().UTC().Add(c.period))
+
+	return err == nil
+}
+
+// NewRedisFrequencyCapper allows each user at most maxImpressions of a campaign per period
+func NewRedisFrequencyCapper(da nodb.NoDbDataAccess, maxImpressions int64, period time.Duration) rtb.FrequencyCapper {
+	c := new(RedisFrequencyCapper)
+
+	c.da = da
+	c.maxImpressions = maxImpressions
+	c.period = period
+
+	return c
+}
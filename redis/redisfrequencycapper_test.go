@@ -0,0 +1,27 @@
+package redis
+
+import (
+	"testing"
+	"time"
+)
+
+// Test counting impressions against a frequency cap
+// Expected result is each user is allowed the capped number of impressions, independently of other users and campaigns
+func TestFrequencyCapper(t *testing.T) {
+	c := NewRedisFrequencyCapper(testDataAccess, 2, time.Hour)
+
+	if !c.Allow(323, "user-a") || !c.Allow(323, "user-a") {
+		t.Fail()
+	}
+
+	if c.Allow(323, "user-a") {
+		t.Fail()
+	}
+
+	if !c.Allow(323, "user-b") || !c.Allow(324, "user-a") {
+		t.Fail()
+	}
+
+	capper := c.(*RedisFrequencyCapper)
+	testDataAccess.DeleteKeys([]string{capper.countKey(323, "user-a"), capper.countKey(323, "user-b"), capper.countKey(324, "user-a")})
+}
@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+)
+
+// Members are added with one SADD per batch of this many, so no single command blocks redis for long
+const listBatchSize = 1000
+
+// Stores each named list as a redis set
+type RedisListProvider struct {
+	da nodb.NoDbDataAccess
+}
+
+func (lp *RedisListProvider) listKey(name string) string {
+	return "lists:" + name
+}
+
+func (lp *RedisListProvider) AddToList(name string, members []string) {
+	if len(members) == 0 {
+		return
+	}
+
+	values := make([]interface{}, len(members))
+	for i, member := range members {
+		values[i] = member
+	}
+
+	lp.da.AddMembersToSetInBatches(lp.listKey(name), values, listBatchSize)
+}
+
+func (lp *RedisListProvider) ListContains(name string, member string) bool {
+	return lp.da.IsSetMember(lp.listKey(name), member)
+}
+
+func (lp *RedisListProvider) ListSize(name string) int64 {
+	return lp.da.SetCardinality(lp.listKey(name))
+}
+
+func (lp *RedisListProvider) DeleteList(name string) {
+	lp.da.DeleteKeys([]string{lp.listKey(name)})
+}
+
+func NewRedisListProvider(da nodb.NoDbDataAccess) rtb.ListProvider {
+	lp := new(RedisListProvider)
+
+	lp.da = da
+
+	return lp
+}
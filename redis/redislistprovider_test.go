@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"github.com/evandigby/rtb"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestLoadList tests bulk loading a list that takes more than one batch
+// Expected result is every member is in the list, and the list is gone once deleted
+func TestLoadList(t *testing.T) {
+	lp := NewRedisListProvider(testDataAccess)
+
+	ids := make([]string, 2500)
+	for i := range ids {
+		ids[i] = "DEVICE-" + strconv.Itoa(i)
+	}
+
+	count, err := rtb.LoadList(strings.NewReader(strings.Join(ids, "\n")), lp, "test audience", 0, true)
+	if err != nil || count != len(ids) {
+		t.FailNow()
+	}
+
+	if lp.ListSize("test audience") != int64(len(ids)) {
+		t.Fail()
+	}
+
+	if !lp.ListContains("test audience", "device-2499") || lp.ListContains("test audience", "device-2500") {
+		t.Fail()
+	}
+
+	lp.DeleteList("test audience")
+
+	if lp.ListSize("test audience") != 0 {
+		t.Fail()
+	}
+}
@@ -2,6 +2,7 @@ package redis
 
 import (
 	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
 	"strconv"
 	"time"
 )
@@ -9,7 +10,7 @@ import (
 // Implements a simple time segmented pacer. Dividing the daily budget into time segments
 // based on the segment duration provided
 type RedisPacer struct {
-	da     NoDbDataAccess
+	da     nodb.NoDbDataAccess
 	banker rtb.Banker
 	cp     rtb.CampaignProvider
 
@@ -38,7 +39,19 @@ func (p *RedisPacer) CanBid(campaign rtb.Campaign) bool {
 
 	// TODO: This definitely only needs to be calculated at the end of each segment. Not every time we request a bid.
 	durationToMidnight := midnight.Sub(now)
+
+	// Scheduled campaigns spread their budget over the active time left in the day
+	if schedule := rtb.CampaignSchedule(campaign); schedule != nil {
+		durationToMidnight = schedule.ActiveDuration(now, midnight)
+		if durationToMidnight == 0 {
+			return false
+		}
+	}
+
 	segmentsToMidnight := int64(durationToMidnight / p.segment)
+	if segmentsToMidnight < 1 {
+		segmentsToMidnight = 1
+	}
 	remaining := (budget / cpi) / segmentsToMidnight
 
 	remainingBudget, err := p.da.DebitIfNotZero(key, 1, remaining, time.Now().UTC().Add(p.segment))
@@ -56,7 +69,7 @@ func (p *RedisPacer) Segment() time.Duration {
 	return p.segment
 }
 
-func NewRedisPacer(cp rtb.CampaignProvider, da NoDbDataAccess, banker rtb.Banker, segment time.Duration) rtb.Pacer {
+func NewRedisPacer(cp rtb.CampaignProvider, da nodb.NoDbDataAccess, banker rtb.Banker, segment time.Duration) rtb.Pacer {
 	p := new(RedisPacer)
 
 	p.da = da
@@ -0,0 +1,196 @@
+package redis
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/nodb"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Implements a time segmented pacer that follows an intraday spend curve.
+// At the start of every segment a PID controller compares actual spend against the curve's target and
+// adjusts how much the campaign is allowed to spend in that segment. The segment allowance is shared
+// between bidders through redis.
+type RedisTimeSegmentedPacer struct {
+	da     nodb.NoDbDataAccess
+	banker rtb.Banker
+	curve  *rtb.SpendCurve
+
+	segment time.Duration
+
+	kp float64
+	ki float64
+	kd float64
+
+	mutex       sync.Mutex
+	controllers map[int64]*rtb.PIDController
+	segments    map[int64]time.Time
+	// The day campaigns that stopped bidding were last evicted
+	evicted time.Time
+
+	now func() time.Time
+}
+
+func (p *RedisTimeSegmentedPacer) paceAccountKey(account int64) string {
+	return "pacer:segmented:account:" + strconv.FormatInt(account, 16)
+}
+
+func (p *RedisTimeSegmentedPacer) spentInMicroCents(campaign rtb.Campaign) (spent int64, configured bool) {
+	remaining := p.banker.RemainingDailyBudgetInMicroCents(campaign.Id())
+
+	if remaining == 0 {
+		return 0, false
+	}
+
+	return campaign.DailyBudgetInMicroCents() - remaining, true
+}
+
+// campaignCurve returns the spend curve for the campaign today, limited to its schedule. Returns nil if the campaign
+// has no active hours today.
+func (p *RedisTimeSegmentedPacer) campaignCurve(campaign rtb.Campaign, now time.Time) *rtb.SpendCurve {
+	if schedule := rtb.CampaignSchedule(campaign); schedule != nil {
+		return p.curve.Scheduled(now, schedule)
+	}
+
+	return p.curve
+}
+
+// targetSpendInMicroCents returns how much of the daily budget the campaign's curve (see campaignCurve) should have spent by now
+func (p *RedisTimeSegmentedPacer) targetSpendInMicroCents(campaign rtb.Campaign, curve *rtb.SpendCurve, now time.Time) int64 {
+	if curve == nil {
+		return 0
+	}
+
+	return int64(float64(campaign.DailyBudgetInMicroCents()) * curve.Fraction(now))
+}
+
+// evict forgets the controllers of campaigns that haven't bid for a day, e.g. archived campaigns, once a day.
+// Must be called with the mutex held.
+func (p *RedisTimeSegmentedPacer) evict(segmentStart time.Time) {
+	day := segmentStart.Truncate(24 * time.Hour)
+
+	if !p.evicted.Before(day) {
+		return
+	}
+
+	for id, last := range p.segments {
+		if last.Before(segmentStart.Add(-24 * time.Hour)) {
+			delete(p.segments, id)
+			delete(p.controllers, id)
+		}
+	}
+
+	p.evicted = day
+}
+
+// adjustment returns the controller output for the segment starting at segmentStart, as a fraction of the daily budget.
+// The controller is only updated once per segment, and starts over every day so yesterday's error doesn't carry into today's budget.
+func (p *RedisTimeSegmentedPacer) adjustment(id int64, segmentStart time.Time, errorFraction float64) float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.evict(segmentStart)
+
+	controller, ok := p.controllers[id]
+	if !ok {
+		controller = rtb.NewPIDController(p.kp, p.ki, p.kd, -1, 1)
+		p.controllers[id] = controller
+	}
+
+	if last, ok := p.segments[id]; !ok || last.Before(segmentStart) {
+		if ok && !last.Truncate(24*time.Hour).Equal(segmentStart.Truncate(24*time.Hour)) {
+			controller.Reset()
+		}
+
+		p.segments[id] = segmentStart
+		return controller.Update(errorFraction, p.segment)
+	}
+
+	return controller.Output()
+}
+
+func (p *RedisTimeSegmentedPacer) CanBid(campaign rtb.Campaign) bool {
+	id := campaign.Id()
+	cpi := rtb.MicroCentsPerImpression(campaign.BidCpmInMicroCents())
+	dailyBudget := campaign.DailyBudgetInMicroCents()
+
+	spent, configured := p.spentInMicroCents(campaign)
+
+	// Account not yet configured, or they actually have no budget. The pacer is not needed.
+	if !configured || cpi == 0 || dailyBudget == 0 {
+		return true // Allow it to pass and configure it
+	}
+
+	now := p.now().UTC()
+	segmentStart := now.Truncate(p.segment)
+	segmentEnd := segmentStart.Add(p.segment)
+
+	curve := p.campaignCurve(campaign, now)
+	if curve == nil {
+		return false
+	}
+
+	target := p.targetSpendInMicroCents(campaign, curve, now)
+	errorFraction := float64(target-spent) / float64(dailyBudget)
+
+	planned := curve.FractionBetween(segmentStart, segmentEnd)
+	allowanceFraction := planned + p.adjustment(id, segmentStart, errorFraction)
+
+	if allowanceFraction <= 0 {
+		return false
+	}
+
+	allowance := int64(allowanceFraction * float64(dailyBudget))
+	remaining := dailyBudget - spent
+
+	if allowance > remaining {
+		allowance = remaining
+	}
+
+	_, err := p.da.DebitIfNotZero(p.paceAccountKey(id), 1, allowance/cpi, segmentEnd)
+
+	return err == nil
+}
+
+func (p *RedisTimeSegmentedPacer) Segment() time.Duration {
+	return p.segment
+}
+
+func (p *RedisTimeSegmentedPacer) TargetSpendInMicroCents(campaign rtb.Campaign) int64 {
+	now := p.now().UTC()
+
+	return p.targetSpendInMicroCents(campaign, p.campaignCurve(campaign, now), now)
+}
+
+func (p *RedisTimeSegmentedPacer) SpendErrorInMicroCents(campaign rtb.Campaign) int64 {
+	spent, _ := p.spentInMicroCents(campaign)
+
+	return p.TargetSpendInMicroCents(campaign) - spent
+}
+
+// NewRedisTimeSegmentedPacer creates a pacer following the spend curve. A nil curve spends evenly over the day.
+// kp, ki and kd are the controller gains applied to the spend error, measured as a fraction of the daily budget.
+func NewRedisTimeSegmentedPacer(da nodb.NoDbDataAccess, banker rtb.Banker, curve *rtb.SpendCurve, segment time.Duration, kp float64, ki float64, kd float64) rtb.SpendTrackingPacer {
+	p := new(RedisTimeSegmentedPacer)
+
+	p.da = da
+	p.banker = banker
+	p.segment = segment
+
+	if curve == nil {
+		curve = rtb.NewFlatSpendCurve()
+	}
+	p.curve = curve
+
+	p.kp = kp
+	p.ki = ki
+	p.kd = kd
+
+	p.controllers = make(map[int64]*rtb.PIDController)
+	p.segments = make(map[int64]time.Time)
+
+	p.now = time.Now
+
+	return p
+}
@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"github.com/evandigby/rtb"
+	"github.com/evandigby/rtb/mocks"
+	"testing"
+	"time"
+)
+
+// Test bidding through a time segmented pacer following a flat curve, with the controller turned off
+// Expected result is the campaign is admitted until the current hour's share of its budget is used, then denied
+func TestTimeSegmentedPacerCanBid(t *testing.T) {
+	b := NewRedisBanker(testDataAccess)
+	p := NewRedisTimeSegmentedPacer(testDataAccess, b, nil, time.Hour, 0, 0, 0).(*RedisTimeSegmentedPacer)
+
+	// Halfway through the current hour, so the segment's allowance expires in the future
+	now := time.Now().UTC().Truncate(time.Hour).Add(30 * time.Minute)
+	p.now = func() time.Time { return now }
+
+	// 10 micro cents per impression, and an hour of a flat curve allows 7500 / 24 = 312.5 micro cents
+	campaign := mocks.NewMockCampaign(326, 10000, 7500, nil)
+	b.SetRemainingDailyBudgetInMicroCents(326, 7500, now.AddDate(0, 0, 1))
+
+	admitted := 0
+	for i := 0; i < 100 && p.CanBid(campaign); i++ {
+		admitted++
+	}
+
+	if admitted != 31 {
+		t.Fail()
+	}
+
+	b.DeleteAccount(326)
+	testDataAccess.DeleteKeys([]string{p.paceAccountKey(326)})
+}
+
+// Test bidding through a time segmented pacer in an hour its curve doesn't spend in
+// Expected result is the campaign is denied
+func TestTimeSegmentedPacerCanBidOutsideCurve(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Hour).Add(30 * time.Minute)
+
+	var weights [rtb.HoursPerDay]float64
+	weights[(now.Hour()+12)%rtb.HoursPerDay] = 1
+
+	b := NewRedisBanker(testDataAccess)
+	p := NewRedisTimeSegmentedPacer(testDataAccess, b, rtb.NewSpendCurve(weights), time.Hour, 0, 0, 0).(*RedisTimeSegmentedPacer)
+	p.now = func() time.Time { return now }
+
+	campaign := mocks.NewMockCampaign(327, 10000, 7500, nil)
+	b.SetRemainingDailyBudgetInMicroCents(327, 7500, now.AddDate(0, 0, 1))
+
+	if p.CanBid(campaign) {
+		t.Fail()
+	}
+
+	b.DeleteAccount(327)
+	testDataAccess.DeleteKeys([]string{p.paceAccountKey(327)})
+}
+
+// Test the target spend and spend error of a campaign halfway through its curve
+// Expected result is the target is half the daily budget, and the error is the target minus what was spent
+func TestTimeSegmentedPacerSpend(t *testing.T) {
+	// Half the budget is spent in the first hour of the day, and half in the last
+	var weights [rtb.HoursPerDay]float64
+	weights[0] = 1
+	weights[rtb.HoursPerDay-1] = 1
+
+	b := NewRedisBanker(testDataAccess)
+	p := NewRedisTimeSegmentedPacer(testDataAccess, b, rtb.NewSpendCurve(weights), time.Hour, 0, 0, 0).(*RedisTimeSegmentedPacer)
+
+	noon := time.Now().UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)
+	p.now = func() time.Time { return noon }
+
+	campaign := mocks.NewMockCampaign(328, 10000, 2400, nil)
+	b.SetRemainingDailyBudgetInMicroCents(328, 2100, time.Now().UTC().AddDate(0, 0, 1))
+
+	if p.TargetSpendInMicroCents(campaign) != 1200 {
+		t.Fail()
+	}
+
+	if p.SpendErrorInMicroCents(campaign) != 900 {
+		t.Fail()
+	}
+
+	b.DeleteAccount(328)
+}
+
+// Test the controller of a time segmented pacer across midnight
+// Expected result is the error accumulated yesterday doesn't adjust the first segment of today
+func TestTimeSegmentedPacerResetsDaily(t *testing.T) {
+	p := NewRedisTimeSegmentedPacer(testDataAccess, NewRedisBanker(testDataAccess), nil, time.Hour, 0, 1, 0).(*RedisTimeSegmentedPacer)
+
+	lastHour := time.Date(2015, 3, 1, 23, 0, 0, 0, time.UTC)
+
+	if p.adjustment(329, lastHour, 0.0001) <= 0 {
+		t.Fail()
+	}
+
+	if p.adjustment(329, lastHour.Add(time.Hour), 0) != 0 {
+		t.Fail()
+	}
+}
+
+// Test the controllers of campaigns that stop bidding, e.g. because they were archived
+// Expected result is a campaign's controller is forgotten once it hasn't been updated for a day, and others are kept
+func TestTimeSegmentedPacerEvictsIdleCampaigns(t *testing.T) {
+	p := NewRedisTimeSegmentedPacer(testDataAccess, NewRedisBanker(testDataAccess), nil, time.Hour, 0, 1, 0).(*RedisTimeSegmentedPacer)
+
+	morning := time.Date(2015, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	p.adjustment(330, morning, 0.1)
+	p.adjustment(331, morning.Add(12*time.Hour), 0.1)
+
+	// The next day, before 330 has been idle for a day
+	p.adjustment(331, morning.Add(20*time.Hour), 0.1)
+
+	if _, ok := p.controllers[330]; !ok {
+		t.Fail()
+	}
+
+	// The day after, once 330 has been idle for over a day
+	p.adjustment(331, morning.Add(44*time.Hour), 0.1)
+
+	if _, ok := p.controllers[330]; ok {
+		t.Fail()
+	}
+
+	if _, ok := p.segments[330]; ok {
+		t.Fail()
+	}
+
+	if _, ok := p.controllers[331]; !ok {
+		t.Fail()
+	}
+}
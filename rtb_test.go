@@ -47,3 +47,51 @@ func TestMicroCentsPerImpression(t *testing.T) {
 		t.Fail()
 	}
 }
+
+type goalCampaign struct {
+	bidCpmInMicroCents int64
+	goal               Goal
+	creatives          []Creative
+}
+
+func (c *goalCampaign) Id() int64                       { return 1 }
+func (c *goalCampaign) BidCpmInMicroCents() int64       { return c.bidCpmInMicroCents }
+func (c *goalCampaign) DailyBudgetInMicroCents() int64  { return 0 }
+func (c *goalCampaign) Targets() *map[TargetType]string { return nil }
+func (c *goalCampaign) TargetList() []Target            { return nil }
+func (c *goalCampaign) Goal() Goal                      { return c.goal }
+func (c *goalCampaign) Creatives() []Creative           { return c.creatives }
+func (c *goalCampaign) Priority() PriorityTier          { return PriorityOpen }
+func (c *goalCampaign) Status() CampaignStatus          { return CampaignActive }
+
+// TestEffectiveCpmCPC ensures a cost per click goal is converted to a CPM using the click through rate
+// Expected result is the cost per click times the click through rate times one thousand
+func TestEffectiveCpmCPC(t *testing.T) {
+	c := &goalCampaign{goal: Goal{Type: CPCGoal, AmountInMicroCents: DollarsToMicroCents(0.5)}}
+
+	if EffectiveCpmInMicroCents(c, 0.002, 0) != CpmToMicroCents(1) {
+		t.Fail()
+	}
+}
+
+// TestEffectiveCpmCPAMax ensures a cost per action goal is capped at the campaign's bid CPM
+// Expected result is the bid CPM
+func TestEffectiveCpmCPAMax(t *testing.T) {
+	c := &goalCampaign{bidCpmInMicroCents: CpmToMicroCents(2), goal: Goal{Type: CPAGoal, AmountInMicroCents: DollarsToMicroCents(100)}}
+
+	if EffectiveCpmInMicroCents(c, 0.01, 0.1) != CpmToMicroCents(2) {
+		t.Fail()
+	}
+}
+
+// TestNoBidReasonOrder ensures no-bid reasons keep their logged values, and are ordered by how far the request got
+// Expected result is no budget is still 3, and is further than the multi-imp policy even though its value is lower
+func TestNoBidReasonOrder(t *testing.T) {
+	if NoBidNoBudget != 3 || NoBidMultiImpPolicy != 4 {
+		t.Fail()
+	}
+
+	if !NoBidNoBudget.Further(NoBidMultiImpPolicy) || NoBidMultiImpPolicy.Further(NoBidNoBudget) || !NoBidNoCampaigns.Further(NoBidReasonNone) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,216 @@
+package rtb
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HoursPerWeek is the number of hours in a schedule
+const HoursPerWeek = 7 * HoursPerDay
+
+// UserTimezone is the timezone name of schedules that are evaluated in the user's local time
+const UserTimezone = "user"
+
+// Schedule defines the hours of the week a campaign is active.
+// Hours are numbered from midnight Sunday, in the schedule's timezone.
+type Schedule struct {
+	hours [HoursPerWeek / 8]byte
+	// nil when the schedule is evaluated in the user's local time
+	location *time.Location
+}
+
+// Parsed schedules, by value, so constraints aren't decoded (and timezones aren't loaded) on every request
+var parsedSchedules = struct {
+	sync.RWMutex
+	schedules map[string]*Schedule
+}{schedules: make(map[string]*Schedule)}
+
+// NewSchedule creates a schedule with no active hours. A nil location evaluates the schedule in the user's local time.
+// Schedules are stored by timezone name, so location must be one time.LoadLocation can load.
+func NewSchedule(location *time.Location) *Schedule {
+	s := new(Schedule)
+	s.location = location
+
+	return s
+}
+
+// SetHour sets whether the campaign is active during an hour of a day of the week
+func (s *Schedule) SetHour(day time.Weekday, hour int, active bool) {
+	index := int(day)*HoursPerDay + hour
+
+	if active {
+		s.hours[index/8] |= 1 << uint(index%8)
+	} else {
+		s.hours[index/8] &^= 1 << uint(index%8)
+	}
+}
+
+// SetHours makes the campaign active from the start of hour from until the start of hour to, on each of the days
+func (s *Schedule) SetHours(days []time.Weekday, from int, to int) {
+	for _, day := range days {
+		for hour := from; hour < to; hour++ {
+			s.SetHour(day, hour, true)
+		}
+	}
+}
+
+// Hour returns whether the campaign is active during an hour of a day of the week
+func (s *Schedule) Hour(day time.Weekday, hour int) bool {
+	index := int(day)*HoursPerDay + hour
+
+	return s.hours[index/8]&(1<<uint(index%8)) != 0
+}
+
+// ActiveAt returns whether the campaign is active at t. utcOffsetInMinutes is the user's offset from UTC,
+// and is only used by schedules evaluated in the user's local time.
+func (s *Schedule) ActiveAt(t time.Time, utcOffsetInMinutes int) bool {
+	if s.location != nil {
+		t = t.In(s.location)
+	} else {
+		t = t.UTC().Add(time.Duration(utcOffsetInMinutes) * time.Minute)
+	}
+
+	return s.Hour(t.Weekday(), t.Hour())
+}
+
+// nextHour returns the start of the hour after t in the schedule's timezone. Some timezones are offset from UTC by a
+// fraction of an hour (e.g. Asia/Kolkata), so their hours don't start when UTC hours do.
+func (s *Schedule) nextHour(t time.Time) time.Time {
+	if s.location != nil {
+		t = t.In(s.location)
+	} else {
+		t = t.UTC()
+	}
+
+	intoHour := time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	return t.Add(time.Hour - intoHour)
+}
+
+// ActiveDuration returns how much of the time between from and to the campaign is active.
+// Schedules in the user's local time are treated as UTC.
+func (s *Schedule) ActiveDuration(from time.Time, to time.Time) time.Duration {
+	active := time.Duration(0)
+
+	for t := from; t.Before(to); {
+		next := s.nextHour(t)
+		if next.After(to) {
+			next = to
+		}
+
+		if s.ActiveAt(t, 0) {
+			active += next.Sub(t)
+		}
+
+		t = next
+	}
+
+	return active
+}
+
+// String returns the schedule as stored in a Daypart target: the timezone name and the hours as hex, separated by "|"
+func (s *Schedule) String() string {
+	name := UserTimezone
+	if s.location != nil {
+		name = s.location.String()
+	}
+
+	return name + "|" + hex.EncodeToString(s.hours[:])
+}
+
+// ParseSchedule parses a schedule stored in a Daypart target
+func ParseSchedule(value string) (*Schedule, error) {
+	parsedSchedules.RLock()
+	s, ok := parsedSchedules.schedules[value]
+	parsedSchedules.RUnlock()
+
+	if ok {
+		return s, nil
+	}
+
+	fields := strings.SplitN(value, "|", 2)
+	if len(fields) != 2 {
+		return nil, errors.New("schedule must be a timezone and hours separated by |")
+	}
+
+	var location *time.Location
+	if fields[0] != UserTimezone {
+		var err error
+		if location, err = time.LoadLocation(fields[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	hours, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hours) != HoursPerWeek/8 {
+		return nil, errors.New("schedule must have " + strconv.Itoa(HoursPerWeek) + " hours")
+	}
+
+	s = NewSchedule(location)
+	copy(s.hours[:], hours)
+
+	parsedSchedules.Lock()
+	parsedSchedules.schedules[value] = s
+	parsedSchedules.Unlock()
+
+	return s, nil
+}
+
+// ScheduleTarget returns the constraint limiting a campaign to the schedule
+func ScheduleTarget(s *Schedule) Target {
+	return Target{Type: Daypart, Value: s.String()}
+}
+
+// CampaignSchedule returns the campaign's schedule, or nil if it is always active
+func CampaignSchedule(campaign Campaign) *Schedule {
+	targets := campaign.Targets()
+	if targets == nil {
+		return nil
+	}
+
+	value, ok := (*targets)[Daypart]
+	if !ok {
+		return nil
+	}
+
+	s, err := ParseSchedule(value)
+	if err != nil {
+		return nil
+	}
+
+	return s
+}
+
+// RequestTimeTarget returns the target describing when a request was received, used to check schedules
+func RequestTimeTarget(now time.Time) Target {
+	return Target{Type: RequestTime, Value: strconv.FormatInt(now.Unix(), 10)}
+}
+
+func scheduleSatisfied(value string, targets []Target) bool {
+	s, err := ParseSchedule(value)
+	if err != nil {
+		return false
+	}
+
+	now := time.Now()
+	if requestTime, ok := requestValue(targets, RequestTime); ok {
+		if seconds, err := strconv.ParseInt(requestTime, 10, 64); err == nil {
+			now = time.Unix(seconds, 0)
+		}
+	}
+
+	utcOffset := 0
+	if offset, ok := requestValue(targets, UTCOffset); ok {
+		utcOffset, _ = strconv.Atoi(offset)
+	}
+
+	return s.ActiveAt(now, utcOffset)
+}
@@ -0,0 +1,108 @@
+package rtb
+
+import (
+	"testing"
+	"time"
+)
+
+// TestScheduleString ensures a schedule survives being stored in a target
+// Expected result is the parsed schedule has the same timezone and hours
+func TestScheduleString(t *testing.T) {
+	location, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSchedule(location)
+	s.SetHours([]time.Weekday{time.Saturday, time.Sunday}, 8, 20)
+
+	parsed, err := ParseSchedule(s.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.String() != s.String() || !parsed.Hour(time.Sunday, 8) || parsed.Hour(time.Sunday, 20) || parsed.Hour(time.Monday, 10) {
+		t.Fail()
+	}
+
+	if _, err := ParseSchedule("user|00"); err == nil {
+		t.Fail()
+	}
+}
+
+// TestScheduleUserTimezone ensures schedules in the user's local time use the request's UTC offset
+// Expected result is 14:00 UTC is active for a user at UTC-5 with a 9 to 10 schedule, and inactive for a user at UTC
+func TestScheduleUserTimezone(t *testing.T) {
+	s := NewSchedule(nil)
+	s.SetHour(time.Monday, 9, true)
+
+	now := time.Date(2015, 6, 1, 14, 30, 0, 0, time.UTC)
+
+	if !s.ActiveAt(now, -300) || s.ActiveAt(now, 0) {
+		t.Fail()
+	}
+
+	targets := []Target{RequestTimeTarget(now), Target{Type: UTCOffset, Value: "-300"}}
+	if !ConstraintSatisfied(ScheduleTarget(s), targets, nil) {
+		t.Fail()
+	}
+}
+
+// TestScheduleActiveDuration ensures only active time is counted, including partial hours
+// Expected result is 90 minutes between 9:30 and 17:00 with a 9 to 11 schedule
+func TestScheduleActiveDuration(t *testing.T) {
+	s := NewSchedule(time.UTC)
+	s.SetHours([]time.Weekday{time.Monday}, 9, 11)
+
+	from := time.Date(2015, 6, 1, 9, 30, 0, 0, time.UTC)
+	to := time.Date(2015, 6, 1, 17, 0, 0, 0, time.UTC)
+
+	if s.ActiveDuration(from, to) != 90*time.Minute {
+		t.Fail()
+	}
+}
+
+// TestScheduleActiveDurationHalfHourOffset ensures hours are stepped in the schedule's timezone when it's offset from UTC by
+// half an hour
+// Expected result is the 30 minutes of a 9 to 10 Kolkata schedule that fall between 3:00 and 4:00 UTC (8:30 to 9:30 local)
+func TestScheduleActiveDurationHalfHourOffset(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.FailNow()
+	}
+
+	s := NewSchedule(kolkata)
+	s.SetHours([]time.Weekday{time.Monday}, 9, 10)
+
+	from := time.Date(2015, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	if s.ActiveDuration(from, from.Add(time.Hour)) != 30*time.Minute {
+		t.Fail()
+	}
+
+	if s.ActiveDuration(from, from.Add(2*time.Hour)) != time.Hour {
+		t.Fail()
+	}
+}
+
+// TestScheduledSpendCurve ensures a scheduled curve only spends during active hours
+// Expected result is the whole budget spent between 9 and 11, and no curve on a day with no active hours
+func TestScheduledSpendCurve(t *testing.T) {
+	s := NewSchedule(time.UTC)
+	s.SetHours([]time.Weekday{time.Monday}, 9, 11)
+
+	monday := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewFlatSpendCurve().Scheduled(monday, s)
+	if c == nil {
+		t.FailNow()
+	}
+
+	if c.Fraction(monday.Add(9*time.Hour)) != 0 || c.Fraction(monday.Add(10*time.Hour)) != 0.5 || c.Fraction(monday.Add(11*time.Hour)) != 1 {
+		t.Fail()
+	}
+
+	if NewFlatSpendCurve().Scheduled(monday.Add(24*time.Hour), s) != nil {
+		t.Fail()
+	}
+}
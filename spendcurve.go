@@ -0,0 +1,124 @@
+package rtb
+
+import (
+	"time"
+)
+
+// HoursPerDay is the number of weights in a spend curve
+const HoursPerDay = 24
+
+// SpendCurve defines how a daily budget should be spread over the hours of a (UTC) day
+type SpendCurve struct {
+	// Normalized so that all weights add up to 1
+	weights [HoursPerDay]float64
+}
+
+// NewSpendCurve creates a spend curve from relative hourly weights. Weights do not need to add up to anything in particular.
+// Negative weights are treated as zero. If every weight is zero the curve is flat.
+func NewSpendCurve(hourlyWeights [HoursPerDay]float64) *SpendCurve {
+	c := new(SpendCurve)
+
+	total := float64(0)
+	for _, w := range hourlyWeights {
+		if w > 0 {
+			total += w
+		}
+	}
+
+	for i, w := range hourlyWeights {
+		if total == 0 {
+			c.weights[i] = 1.0 / HoursPerDay
+		} else if w > 0 {
+			c.weights[i] = w / total
+		}
+	}
+
+	return c
+}
+
+// NewFlatSpendCurve creates a spend curve that spreads the budget evenly over the day
+func NewFlatSpendCurve() *SpendCurve {
+	return NewSpendCurve([HoursPerDay]float64{})
+}
+
+// LearnSpendCurve creates a spend curve shaped like historical traffic, given the times requests were seen
+func LearnSpendCurve(requestTimes []time.Time) *SpendCurve {
+	var counts [HoursPerDay]float64
+
+	for _, t := range requestTimes {
+		counts[t.UTC().Hour()]++
+	}
+
+	return NewSpendCurve(counts)
+}
+
+// HourlyWeights returns the normalized weight of each hour of the day
+func (c *SpendCurve) HourlyWeights() [HoursPerDay]float64 {
+	return c.weights
+}
+
+// Weight returns the normalized weight of the hour containing t
+func (c *SpendCurve) Weight(t time.Time) float64 {
+	return c.weights[t.UTC().Hour()]
+}
+
+// Fraction returns the fraction of the daily budget that should have been spent by t.
+// Spend is assumed to be spread evenly within each hour.
+func (c *SpendCurve) Fraction(t time.Time) float64 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	elapsed := t.Sub(midnight)
+
+	hour := int(elapsed / time.Hour)
+	fraction := float64(0)
+
+	for i := 0; i < hour; i++ {
+		fraction += c.weights[i]
+	}
+
+	if hour < HoursPerDay {
+		intoHour := float64(elapsed-time.Duration(hour)*time.Hour) / float64(time.Hour)
+		fraction += c.weights[hour] * intoHour
+	}
+
+	return fraction
+}
+
+// FractionBetween returns the fraction of the daily budget that should be spent between from and to
+func (c *SpendCurve) FractionBetween(from time.Time, to time.Time) float64 {
+	start := c.Fraction(from)
+	end := c.Fraction(to)
+
+	// Crossed midnight, the remainder of the first day plus the start of the next
+	if !to.UTC().Truncate(24 * time.Hour).Equal(from.UTC().Truncate(24 * time.Hour)) {
+		end += 1
+	}
+
+	return end - start
+}
+
+// Scheduled returns the curve for the (UTC) day containing t, with the weight of each hour scaled by how much
+// of it the schedule is active. Returns nil if the schedule has no active hours that day.
+func (c *SpendCurve) Scheduled(t time.Time, schedule *Schedule) *SpendCurve {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	var weights [HoursPerDay]float64
+	active := false
+
+	for hour := 0; hour < HoursPerDay; hour++ {
+		start := midnight.Add(time.Duration(hour) * time.Hour)
+		activeFraction := float64(schedule.ActiveDuration(start, start.Add(time.Hour))) / float64(time.Hour)
+
+		weights[hour] = c.weights[hour] * activeFraction
+		if weights[hour] > 0 {
+			active = true
+		}
+	}
+
+	if !active {
+		return nil
+	}
+
+	return NewSpendCurve(weights)
+}
@@ -0,0 +1,82 @@
+package rtb
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func closeEnough(a float64, b float64) bool {
+	return math.Abs(a-b) < 0.000001
+}
+
+// TestFlatSpendCurveFraction ensures a flat curve spends evenly over the day
+// Expected result is half the budget spent by noon
+func TestFlatSpendCurveFraction(t *testing.T) {
+	c := NewFlatSpendCurve()
+
+	noon := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if !closeEnough(c.Fraction(noon), 0.5) {
+		t.Fail()
+	}
+}
+
+// TestSpendCurveFractionWithinHour ensures spend is interpolated within an hour
+// Expected result is half of the only weighted hour spent half way through it
+func TestSpendCurveFractionWithinHour(t *testing.T) {
+	var weights [HoursPerDay]float64
+	weights[10] = 5
+
+	c := NewSpendCurve(weights)
+
+	if !closeEnough(c.Fraction(time.Date(2015, 3, 1, 9, 59, 0, 0, time.UTC)), 0) {
+		t.Fail()
+	}
+
+	if !closeEnough(c.Fraction(time.Date(2015, 3, 1, 10, 30, 0, 0, time.UTC)), 0.5) {
+		t.Fail()
+	}
+
+	if !closeEnough(c.Fraction(time.Date(2015, 3, 1, 11, 0, 0, 0, time.UTC)), 1) {
+		t.Fail()
+	}
+}
+
+// TestSpendCurveFractionBetweenMidnight ensures a segment crossing midnight is measured correctly
+// Expected result is the last half hour of the day plus the first half hour of the next
+func TestSpendCurveFractionBetweenMidnight(t *testing.T) {
+	c := NewFlatSpendCurve()
+
+	from := time.Date(2015, 3, 1, 23, 30, 0, 0, time.UTC)
+	to := time.Date(2015, 3, 2, 0, 30, 0, 0, time.UTC)
+
+	if !closeEnough(c.FractionBetween(from, to), 1.0/HoursPerDay) {
+		t.Fail()
+	}
+}
+
+// TestLearnSpendCurve ensures a learned curve follows the traffic it learned from
+// Expected result is hourly weights proportional to the number of requests seen in each hour
+func TestLearnSpendCurve(t *testing.T) {
+	times := []time.Time{
+		time.Date(2015, 3, 1, 8, 5, 0, 0, time.UTC),
+		time.Date(2015, 3, 1, 8, 45, 0, 0, time.UTC),
+		time.Date(2015, 3, 2, 8, 10, 0, 0, time.UTC),
+		time.Date(2015, 3, 1, 20, 0, 0, 0, time.UTC),
+	}
+
+	weights := LearnSpendCurve(times).HourlyWeights()
+
+	if !closeEnough(weights[8], 0.75) {
+		t.Fail()
+	}
+
+	if !closeEnough(weights[20], 0.25) {
+		t.Fail()
+	}
+
+	if weights[0] != 0 {
+		t.Fail()
+	}
+}
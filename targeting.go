@@ -0,0 +1,134 @@
+package rtb
+
+import (
+	"strconv"
+)
+
+// Targets come in three kinds:
+//   - Indexed targets are looked up in the campaign provider's target index. A campaign matches a request when
+//     any of its indexed targets is one of the request's targets.
+//   - Constraints are stored with the campaign but not indexed. They never match a campaign on their own, but a
+//     matched campaign is dropped when the request doesn't satisfy all of its constraints.
+//   - Request only targets describe the request, and are only used to check constraints.
+
+// IsConstraint returns whether targets of this type restrict the requests a campaign matches
+func (t TargetType) IsConstraint() bool {
+	switch t {
+	case GeoRadius, GeoPolygon, OSVersionRange, Daypart, Audience, PlacementAllowList, PlacementBlockList, CategoryExclude:
+		return true
+	}
+
+	return false
+}
+
+// IsRequestOnly returns whether targets of this type only describe requests, and are never set on campaigns
+func (t TargetType) IsRequestOnly() bool {
+	switch t {
+	case GeoPoint, OSVersion, RequestTime, UTCOffset, DeviceID, AppBundle, AppID, SiteDomain, PublisherID, TagID:
+		return true
+	}
+
+	return false
+}
+
+// IsIndexed returns whether targets of this type are looked up in the target index
+func (t TargetType) IsIndexed() bool {
+	return !t.IsConstraint() && !t.IsRequestOnly()
+}
+
+// IndexedTargets returns only the targets that are looked up in the target index
+func IndexedTargets(targets []Target) []Target {
+	indexed := make([]Target, 0, len(targets))
+
+	for _, target := range targets {
+		if target.Type.IsIndexed() {
+			indexed = append(indexed, target)
+		}
+	}
+
+	return indexed
+}
+
+// TargetKey returns a key uniquely identifying the target, prefixed by prepend
+func TargetKey(prepend string, target Target) string {
+	return prepend + strconv.FormatInt(int64(target.Type), 10) + ":" + target.Value
+}
+
+func requestValue(targets []Target, targetType TargetType) (string, bool) {
+	for _, target := range targets {
+		if target.Type == targetType {
+			return target.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// ConstraintSatisfied returns whether a request with the targets passed in satisfies a campaign's constraint.
+// lists is used to check list membership, and may be nil if there are no lists.
+func ConstraintSatisfied(constraint Target, targets []Target, lists ListProvider) bool {
+	switch constraint.Type {
+	case GeoRadius, GeoPolygon:
+		value, ok := requestValue(targets, GeoPoint)
+		if !ok {
+			return false
+		}
+
+		point, ok := parseLatLon(value)
+		if !ok {
+			return false
+		}
+
+		if constraint.Type == GeoRadius {
+			return geoRadiusSatisfied(constraint.Value, point)
+		}
+		return geoPolygonSatisfied(constraint.Value, point)
+	case OSVersionRange:
+		value, ok := requestValue(targets, OSVersion)
+
+		return ok && VersionSatisfies(value, constraint.Value)
+	case Daypart:
+		return scheduleSatisfied(constraint.Value, targets)
+	case Audience:
+		return audienceSatisfied(constraint.Value, targets, lists)
+	case PlacementAllowList:
+		return lists != nil && placementListed(constraint.Value, targets, lists)
+	case PlacementBlockList:
+		return lists == nil || !placementListed(constraint.Value, targets, lists)
+	case CategoryExclude:
+		return categoryExcludeSatisfied(constraint.Value, targets)
+	}
+
+	return true
+}
+
+// SatisfiesConstraints returns whether a request with the targets passed in satisfies all of the campaign's constraints
+func SatisfiesConstraints(campaign Campaign, targets []Target, lists ListProvider) bool {
+	campaignTargets := campaign.Targets()
+
+	if campaignTargets == nil {
+		return true
+	}
+
+	for targetType, value := range *campaignTargets {
+		if targetType.IsConstraint() && !ConstraintSatisfied(Target{Type: targetType, Value: value}, targets, lists) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterByConstraints returns the campaigns whose constraints are satisfied by a request with the targets passed in.
+// Order is preserved.
+func FilterByConstraints(campaigns []Campaign, targets []Target, lists ListProvider) []Campaign {
+	filtered := make([]Campaign, 0, len(campaigns))
+
+	for _, campaign := range campaigns {
+		if SatisfiesConstraints(campaign, targets, lists) {
+			filtered = append(filtered, campaign)
+		}
+	}
+
+	return filtered
+}
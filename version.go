@@ -0,0 +1,94 @@
+package rtb
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseVersion splits a version like "14.2.1" into its numeric components.
+// Anything after the numbers in a component, like "1-beta", is ignored.
+func parseVersion(version string) []int64 {
+	fields := strings.Split(strings.TrimSpace(version), ".")
+	components := make([]int64, 0, len(fields))
+
+	for _, field := range fields {
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+
+		n, err := strconv.ParseInt(field[:end], 10, 64)
+		if err != nil {
+			break
+		}
+
+		components = append(components, n)
+	}
+
+	return components
+}
+
+// CompareVersions returns -1, 0 or 1 when a is older than, the same as, or newer than b.
+// Missing components are zero, so "14" is the same as "14.0.0".
+func CompareVersions(a string, b string) int {
+	av := parseVersion(a)
+	bv := parseVersion(b)
+
+	for i := 0; i < len(av) || i < len(bv); i++ {
+		var x, y int64
+
+		if i < len(av) {
+			x = av[i]
+		}
+		if i < len(bv) {
+			y = bv[i]
+		}
+
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// VersionSatisfies returns whether the version satisfies every comparison in the range.
+// Comparisons are separated by commas and use >=, <=, >, <, = or !=, e.g. ">=14.2,<17".
+func VersionSatisfies(version string, versionRange string) bool {
+	if len(parseVersion(version)) == 0 {
+		return false
+	}
+
+	for _, comparison := range strings.Split(versionRange, ",") {
+		comparison = strings.TrimSpace(comparison)
+
+		operand := strings.TrimLeft(comparison, "<>=! ")
+		operator := strings.TrimSpace(comparison[:len(comparison)-len(operand)])
+
+		result := CompareVersions(version, operand)
+
+		var ok bool
+		switch operator {
+		case ">=":
+			ok = result >= 0
+		case "<=":
+			ok = result <= 0
+		case ">":
+			ok = result > 0
+		case "<":
+			ok = result < 0
+		case "=", "==", "":
+			ok = result == 0
+		case "!=":
+			ok = result != 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package rtb
+
+import (
+	"testing"
+)
+
+// TestCompareVersions ensures versions are compared numerically, component by component
+// Expected result is 14.10 newer than 14.2, and 14 the same as 14.0.0
+func TestCompareVersions(t *testing.T) {
+	if CompareVersions("14.10", "14.2") != 1 {
+		t.Fail()
+	}
+
+	if CompareVersions("14", "14.0.0") != 0 {
+		t.Fail()
+	}
+
+	if CompareVersions("9.3.5", "10") != -1 {
+		t.Fail()
+	}
+}
+
+// TestVersionSatisfies ensures every comparison in a range must hold
+// Expected result is only versions from 14.2 up to but not including 17 satisfy the range
+func TestVersionSatisfies(t *testing.T) {
+	versionRange := ">=14.2,<17"
+
+	if !VersionSatisfies("14.2", versionRange) || !VersionSatisfies("16.7.1", versionRange) {
+		t.Fail()
+	}
+
+	if VersionSatisfies("14.1.9", versionRange) || VersionSatisfies("17.0", versionRange) || VersionSatisfies("", versionRange) {
+		t.Fail()
+	}
+}
+
+// TestDeviceTargeting ensures a request is targeted by its device and connection
+// Expected result is a cellular target as well as the specific generation of the connection
+func TestDeviceTargeting(t *testing.T) {
+	d := &Device{Os: "iOS", Osv: "14.4", Make: "Apple", Model: "iPhone", Devicetype: 4, Carrier: "Rogers", Connectiontype: 6, Language: "en"}
+
+	values := make(map[Target]bool)
+	for _, target := range d.Targeting() {
+		values[target] = true
+	}
+
+	expected := []Target{
+		{Type: OS, Value: "iOS"},
+		{Type: OSVersion, Value: "14.4"},
+		{Type: DeviceMake, Value: "Apple"},
+		{Type: DeviceModel, Value: "iPhone"},
+		{Type: DeviceType, Value: "phone"},
+		{Type: Carrier, Value: "Rogers"},
+		{Type: ConnectionType, Value: "4g"},
+		{Type: ConnectionType, Value: ConnectionTypeCellular},
+		{Type: Language, Value: "en"},
+	}
+
+	for _, target := range expected {
+		if !values[target] {
+			t.Fail()
+		}
+	}
+}